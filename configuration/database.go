@@ -2,41 +2,260 @@ package configuration
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
 	"path"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/zatarain/bookshop/models"
+	"github.com/go-sql-driver/mysql"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/metrics"
+	"github.com/zatarain/bookshop/migrations"
+	"github.com/zatarain/bookshop/tracing"
+	gormysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var Database *gorm.DB
 
+var ErrUnknownMigrationCommand = errors.New("unknown migration command, expected: up, down [steps] or status")
+
+type DatabaseSettings struct {
+	Driver             string
+	DSN                string
+	MaxOpenConnections int
+	MaxIdleConnections int
+	ConnectionLifetime time.Duration
+}
+
+func LoadDatabaseSettings() (*DatabaseSettings, error) {
+	settings := &DatabaseSettings{}
+	location := os.Getenv("DATABASE_URL")
+	if location == "" {
+		// Fallback to the SQLite file relative to the module directory
+		location = "sqlite://" + os.Getenv("DATABASE")
+	}
+
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		return nil, fmt.Errorf("database URL has no driver scheme: %s", location)
+	}
+
+	switch scheme {
+	case "sqlite", "sqlite3":
+		settings.Driver = "sqlite"
+		settings.DSN = rest
+		if !strings.HasPrefix(rest, "/") && !strings.HasPrefix(rest, "file:") {
+			settings.DSN = fmt.Sprintf("%s/%s", path.Dir(os.Getenv("GOMOD")), rest)
+		}
+		settings.DSN = withSQLiteLocking(settings.DSN)
+	case "postgres", "postgresql":
+		settings.Driver = "postgres"
+		settings.DSN = location
+	case "mysql":
+		parsed, exception := url.Parse(location)
+		if exception != nil {
+			return nil, exception
+		}
+
+		password, _ := parsed.User.Password()
+		config := mysql.NewConfig()
+		config.User = parsed.User.Username()
+		config.Passwd = password
+		config.Net = "tcp"
+		config.Addr = parsed.Host
+		config.DBName = strings.TrimPrefix(parsed.Path, "/")
+		config.ParseTime = true
+		config.Params = map[string]string{}
+		for key, values := range parsed.Query() {
+			config.Params[key] = values[0]
+		}
+
+		settings.Driver = "mysql"
+		settings.DSN = config.FormatDSN()
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", scheme)
+	}
+
+	// Connection pool settings are optional
+	var exception error
+	integers := map[string]*int{
+		"DATABASE_MAX_OPEN_CONNECTIONS": &settings.MaxOpenConnections,
+		"DATABASE_MAX_IDLE_CONNECTIONS": &settings.MaxIdleConnections,
+	}
+	for variable, setting := range integers {
+		if value := os.Getenv(variable); value != "" {
+			if *setting, exception = strconv.Atoi(value); exception != nil {
+				return nil, fmt.Errorf("invalid %s: %s", variable, value)
+			}
+		}
+	}
+
+	if value := os.Getenv("DATABASE_CONNECTION_LIFETIME"); value != "" {
+		if settings.ConnectionLifetime, exception = time.ParseDuration(value); exception != nil {
+			return nil, fmt.Errorf("invalid DATABASE_CONNECTION_LIFETIME: %s", value)
+		}
+	}
+
+	return settings, nil
+}
+
+// withSQLiteLocking makes the transactions take the write lock as they begin and wait for it up to 5 seconds, unless the
+// DSN says otherwise, so the concurrent ones queue up instead of failing as the database is locked
+func withSQLiteLocking(dsn string) string {
+	file, query, _ := strings.Cut(dsn, "?")
+	parameters, exception := url.ParseQuery(query)
+	if exception != nil {
+		return dsn
+	}
+
+	for _, parameter := range [][2]string{{"_busy_timeout", "5000"}, {"_txlock", "immediate"}} {
+		if !parameters.Has(parameter[0]) {
+			query = strings.TrimPrefix(query+"&"+parameter[0]+"="+parameter[1], "&")
+		}
+	}
+
+	return file + "?" + query
+}
+
+func (settings *DatabaseSettings) Dialector() gorm.Dialector {
+	switch settings.Driver {
+	case "postgres":
+		return postgres.Open(settings.DSN)
+	case "mysql":
+		return gormysql.Open(settings.DSN)
+	}
+
+	return sqlite.Open(settings.DSN)
+}
+
+func (settings *DatabaseSettings) Configure(connection *sql.DB) {
+	if settings.MaxOpenConnections > 0 {
+		connection.SetMaxOpenConns(settings.MaxOpenConnections)
+	}
+
+	if settings.MaxIdleConnections > 0 {
+		connection.SetMaxIdleConns(settings.MaxIdleConnections)
+	}
+
+	if settings.ConnectionLifetime > 0 {
+		connection.SetConnMaxLifetime(settings.ConnectionLifetime)
+	}
+}
+
 func ConnectToDatabase() *sql.DB {
-	filename := fmt.Sprintf("%s/%s", path.Dir(os.Getenv("GOMOD")), os.Getenv("DATABASE"))
-	log.Println("Database filename: ", filename)
-	dialector := sqlite.Open(filename)
-	database, exception := gorm.Open(dialector, &gorm.Config{})
+	settings, exception := LoadDatabaseSettings()
+	if exception != nil {
+		slog.Error("Failed to read the database settings", "error", exception.Error())
+		os.Exit(1)
+		return nil
+	}
+
+	slog.Info("Connecting to the database", "driver", settings.Driver)
+	database, exception := gorm.Open(settings.Dialector(), &gorm.Config{
+		Logger: logging.NewGorm(200 * time.Millisecond),
+		Plugins: map[string]gorm.Plugin{
+			"metrics": metrics.GormPlugin{},
+			"tracing": tracing.GormPlugin{},
+		},
+	})
 	if exception != nil {
-		log.Panic("Failed to connect to the database.", exception.Error())
+		slog.Error("Failed to connect to the database", "error", exception.Error())
+		os.Exit(1)
 		return nil
 	}
 
 	connection, exception := database.DB()
 	if exception != nil {
-		log.Panic("Failed to get generic SQL connection pointer.", exception.Error())
+		slog.Error("Failed to get generic SQL connection pointer", "error", exception.Error())
+		os.Exit(1)
 		return nil
 	}
 
+	settings.Configure(connection)
 	Database = database
 	return connection
 }
 
+// newMigrator loads the migrations of the database with the settings their scripts read
+func newMigrator() (*migrations.Migrator, error) {
+	migrator, exception := migrations.New(Database)
+	if exception != nil {
+		return nil, exception
+	}
+
+	migrator.Settings.ReservationTime = ReservationTime()
+	return migrator, nil
+}
+
 func MigrateDatabase() {
-	Database.AutoMigrate(
-		&models.Book{},
-		&models.User{},
-	)
+	migrator, exception := newMigrator()
+	if exception != nil {
+		slog.Error("Failed to load the database migrations", "error", exception.Error())
+		os.Exit(1)
+		return
+	}
+
+	applied, exception := migrator.Up()
+	for _, migration := range applied {
+		slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	if exception != nil {
+		slog.Error("Failed to migrate the database", "error", exception.Error())
+		os.Exit(1)
+	}
+}
+
+func RunMigrationCommand(arguments []string, output io.Writer) error {
+	migrator, exception := newMigrator()
+	if exception != nil {
+		return exception
+	}
+
+	if len(arguments) == 0 {
+		return ErrUnknownMigrationCommand
+	}
+
+	switch arguments[0] {
+	case "up":
+		applied, exception := migrator.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(output, "Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		return exception
+	case "down":
+		steps := 1
+		if len(arguments) > 1 {
+			if steps, exception = strconv.Atoi(arguments[1]); exception != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", arguments[1])
+			}
+		}
+
+		reverted, exception := migrator.Down(steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(output, "Reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return exception
+	case "status":
+		statuses, exception := migrator.Status()
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(output, "%04d_%-30s %s\n", status.Version, status.Name, appliedAt)
+		}
+		return exception
+	}
+
+	return ErrUnknownMigrationCommand
 }
@@ -0,0 +1,36 @@
+package configuration
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zatarain/bookshop/health"
+)
+
+var Liveness = health.NewRegistry()
+
+var Readiness = health.NewRegistry()
+
+func RegisterHealthChecks() {
+	Readiness.Register("database", health.Database(Database))
+	Readiness.Register("migrations", health.Migrations(Database))
+
+	// The disk space only matters when the database is a local file
+	settings, exception := LoadDatabaseSettings()
+	if exception != nil || settings.Driver != "sqlite" || strings.HasPrefix(settings.DSN, "file:") {
+		return
+	}
+
+	minimum := uint64(100)
+	if value := os.Getenv("HEALTH_MINIMUM_FREE_DISK_MB"); value != "" {
+		if minimum, exception = strconv.ParseUint(value, 10, 64); exception != nil {
+			slog.Warn("Invalid minimum free disk, using the default", "variable", "HEALTH_MINIMUM_FREE_DISK_MB", "value", value, "default", 100)
+			minimum = 100
+		}
+	}
+
+	filename, _, _ := strings.Cut(settings.DSN, "?")
+	Readiness.Register("disk", health.Disk(filename, minimum*1024*1024))
+}
@@ -0,0 +1,39 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/health"
+)
+
+func TestRegisterHealthChecks(test *testing.T) {
+	assert := assert.New(test)
+
+	// Teardown test suite
+	defer func() { Readiness = health.NewRegistry() }()
+
+	test.Run("Should check the disk space when using a SQLite file", func(test *testing.T) {
+		// Arrange
+		Readiness = health.NewRegistry()
+		test.Setenv("DATABASE_URL", "sqlite://data/test.db?_busy_timeout=5000")
+
+		// Act
+		RegisterHealthChecks()
+
+		// Assert
+		assert.Equal([]string{"database", "disk", "migrations"}, Readiness.Names())
+	})
+
+	test.Run("Should NOT check the disk space when using a database server", func(test *testing.T) {
+		// Arrange
+		Readiness = health.NewRegistry()
+		test.Setenv("DATABASE_URL", "postgres://localhost/bookshop")
+
+		// Act
+		RegisterHealthChecks()
+
+		// Assert
+		assert.Equal([]string{"database", "migrations"}, Readiness.Names())
+	})
+}
@@ -0,0 +1,47 @@
+package configuration
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/zatarain/bookshop/notifications"
+)
+
+// Notifier builds the notifiers of the comma separated NOTIFIERS, only the log by default:
+//   - log: warnings in the logs of the service
+//   - email: emails from NOTIFICATION_EMAIL_FROM to NOTIFICATION_EMAIL_TO, only logged while there is no mail server
+//   - webhook: POST requests to NOTIFICATION_WEBHOOK_URL
+func Notifier() notifications.Notifier {
+	names := listFromEnvironment("NOTIFIERS")
+	if len(names) == 0 {
+		names = []string{"log"}
+	}
+
+	notifiers := notifications.Many{}
+	for _, name := range names {
+		switch name {
+		case "log":
+			notifiers = append(notifiers, &notifications.Log{})
+		case "email":
+			recipients := listFromEnvironment("NOTIFICATION_EMAIL_TO")
+			if len(recipients) == 0 {
+				slog.Warn("The email notifier has no recipients, skipping it")
+				continue
+			}
+
+			notifiers = append(notifiers, &notifications.Email{From: os.Getenv("NOTIFICATION_EMAIL_FROM"), To: recipients})
+		case "webhook":
+			url := os.Getenv("NOTIFICATION_WEBHOOK_URL")
+			if url == "" {
+				slog.Warn("The webhook notifier has no URL, skipping it")
+				continue
+			}
+
+			notifiers = append(notifiers, notifications.NewWebhook(url))
+		default:
+			slog.Warn("Unknown notifier, skipping it", "notifier", name)
+		}
+	}
+
+	return notifiers
+}
@@ -0,0 +1,52 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/notifications"
+)
+
+func TestNotifier(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should log the notifications by default", func(test *testing.T) {
+		// Arrange
+		test.Setenv("NOTIFIERS", "")
+
+		// Act
+		notifier := Notifier()
+
+		// Assert
+		assert.Equal(notifications.Many{&notifications.Log{}}, notifier)
+	})
+
+	test.Run("Should build the notifiers listed", func(test *testing.T) {
+		// Arrange
+		test.Setenv("NOTIFIERS", "log, email,webhook")
+		test.Setenv("NOTIFICATION_EMAIL_FROM", "shop@example.com")
+		test.Setenv("NOTIFICATION_EMAIL_TO", "buyers@example.com, manager@example.com")
+		test.Setenv("NOTIFICATION_WEBHOOK_URL", "http://localhost:9000/hooks")
+
+		// Act
+		notifier := Notifier().(notifications.Many)
+
+		// Assert
+		assert.Len(notifier, 3)
+		assert.Equal(&notifications.Email{From: "shop@example.com", To: []string{"buyers@example.com", "manager@example.com"}}, notifier[1])
+		assert.Equal("http://localhost:9000/hooks", notifier[2].(*notifications.Webhook).URL)
+	})
+
+	test.Run("Should skip the unknown notifiers and the ones without a destination", func(test *testing.T) {
+		// Arrange
+		test.Setenv("NOTIFIERS", "pigeon,email,webhook")
+		test.Setenv("NOTIFICATION_EMAIL_TO", "")
+		test.Setenv("NOTIFICATION_WEBHOOK_URL", "")
+
+		// Act
+		notifier := Notifier()
+
+		// Assert
+		assert.Equal(notifications.Many{}, notifier)
+	})
+}
@@ -0,0 +1,45 @@
+package configuration
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/payments"
+)
+
+// PaymentProvider builds the provider of PAYMENT_PROVIDER, only the local fake gateway so far, or none when it isn't
+// set so the orders can't be paid. Its webhooks are signed with PAYMENT_WEBHOOK_SECRET and delivered to
+// PAYMENT_WEBHOOK_URL after PAYMENT_FAKE_DELAY. The fake keeps its payments in memory, so it is refused in release mode
+func PaymentProvider() payments.PaymentProvider {
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "":
+		slog.Warn("There is no payment provider, the payments are disabled")
+		return nil
+	case "fake":
+		if gin.Mode() == gin.ReleaseMode {
+			log.Panic("The fake payment provider can't take real payments in release mode.")
+		}
+	default:
+		log.Panic("Unknown payment provider.", provider)
+	}
+
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Panic("The payment provider needs the PAYMENT_WEBHOOK_SECRET to sign its webhooks.")
+	}
+
+	delay := durationFromEnvironment("PAYMENT_FAKE_DELAY", 2*time.Second)
+	return payments.NewFake(secret, delay, payments.PostTo(webhookURL()), Lifecycle)
+}
+
+// webhookURL is PAYMENT_WEBHOOK_URL or the webhook route of this server when it isn't set
+func webhookURL() string {
+	if url := os.Getenv("PAYMENT_WEBHOOK_URL"); url != "" {
+		return url
+	}
+
+	return "http://localhost:" + serverPort() + "/v1/payments/webhook"
+}
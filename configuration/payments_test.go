@@ -0,0 +1,118 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/payments"
+)
+
+func TestPaymentProvider(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should disable the payments when there is no provider", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_PROVIDER", "")
+
+		// Act
+		provider := PaymentProvider()
+
+		// Assert
+		assert.Nil(provider)
+	})
+
+	test.Run("Should build the fake provider with the secret of the webhooks", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_PROVIDER", "fake")
+		test.Setenv("PAYMENT_WEBHOOK_SECRET", "dummy-webhook-secret")
+		test.Setenv("SECRET_TOKEN_KEY", "dummy-token-key")
+
+		// Act
+		provider := PaymentProvider()
+
+		// Assert
+		fake, ok := provider.(*payments.Fake)
+		assert.True(ok)
+		assert.Equal("dummy-webhook-secret", fake.Secret)
+	})
+
+	test.Run("Should NOT take real payments with the fake provider in release mode", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_PROVIDER", "fake")
+		test.Setenv("PAYMENT_WEBHOOK_SECRET", "dummy-webhook-secret")
+		mode := gin.Mode()
+		defer gin.SetMode(mode)
+		gin.SetMode(gin.ReleaseMode)
+
+		// Act
+		build := func() { PaymentProvider() }
+
+		// Assert
+		assert.Panics(build)
+	})
+
+	test.Run("Should NOT start without the secret of the webhooks", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_PROVIDER", "fake")
+		test.Setenv("PAYMENT_WEBHOOK_SECRET", "")
+		test.Setenv("SECRET_TOKEN_KEY", "dummy-token-key")
+
+		// Act
+		build := func() { PaymentProvider() }
+
+		// Assert
+		assert.Panics(build)
+	})
+
+	test.Run("Should NOT start with an unknown provider", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_PROVIDER", "unknown")
+		test.Setenv("PAYMENT_WEBHOOK_SECRET", "dummy-webhook-secret")
+
+		// Act
+		build := func() { PaymentProvider() }
+
+		// Assert
+		assert.Panics(build)
+	})
+}
+
+func TestWebhookURL(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should deliver the webhooks to the given URL", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_WEBHOOK_URL", "https://shop.example.com/v1/payments/webhook")
+
+		// Act
+		actual := webhookURL()
+
+		// Assert
+		assert.Equal("https://shop.example.com/v1/payments/webhook", actual)
+	})
+
+	test.Run("Should deliver the webhooks to the port of the server", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_WEBHOOK_URL", "")
+		test.Setenv("PORT", "4321")
+
+		// Act
+		actual := webhookURL()
+
+		// Assert
+		assert.Equal("http://localhost:4321/v1/payments/webhook", actual)
+	})
+
+	test.Run("Should deliver the webhooks to the default port of the server when there is none", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PAYMENT_WEBHOOK_URL", "")
+		test.Setenv("PORT", "")
+
+		// Act
+		actual := webhookURL()
+
+		// Assert
+		assert.Equal("http://localhost:8080/v1/payments/webhook", actual)
+	})
+}
@@ -0,0 +1,40 @@
+package configuration
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/models"
+	"gorm.io/gorm"
+)
+
+// ReservationTime is how long the books of the orders placed are held while they are paid, from RESERVATION_TIME
+func ReservationTime() time.Duration {
+	return durationFromEnvironment("RESERVATION_TIME", 15*time.Minute)
+}
+
+// ScheduleReservations cancels in the background the pending orders whose reservations expired, giving their books
+// back to the stock available, every RESERVATION_SWEEP_INTERVAL
+func ScheduleReservations(lifecycle *lifecycle.Lifecycle, database *gorm.DB) {
+	interval := durationFromEnvironment("RESERVATION_SWEEP_INTERVAL", time.Minute)
+	lifecycle.Go("reservations", func(context context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-context.Done():
+				return
+			case now := <-ticker.C:
+				orders, exception := models.ReleaseExpiredReservations(database.WithContext(context), now)
+				if exception != nil {
+					slog.Error("Failed to release the expired reservations", "error", exception.Error())
+				} else if orders > 0 {
+					slog.Info("Released the expired reservations", "orders", orders)
+				}
+			}
+		}
+	})
+}
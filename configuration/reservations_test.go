@@ -0,0 +1,57 @@
+package configuration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/migrations/migrationstest"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+)
+
+func TestScheduleReservations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should cancel the orders whose reservations expired until draining", func(test *testing.T) {
+		// Arrange
+		test.Setenv("RESERVATION_SWEEP_INTERVAL", "10ms")
+		database := migrationstest.Migrated(test)
+		user := &models.User{Nickname: "dummy-user", Password: "hash"}
+		database.Create(user)
+		database.Create(&models.Book{Title: "Dune", Prices: []models.BookPrice{{Price: money.MustParse("9.99", "GBP")}}, Quantity: 2})
+		lines := []models.OrderLine{{BookID: 1, Quantity: 1}}
+		expired, _ := models.PlaceOrder(database, user, "GBP", lines, time.Now().Add(-time.Minute), 0)
+		held, _ := models.PlaceOrder(database, user, "GBP", lines, time.Now().Add(time.Hour), 0)
+		lifecycle := lifecycle.New()
+
+		// Act
+		ScheduleReservations(lifecycle, database)
+
+		// Assert
+		assert.Eventually(func() bool {
+			book := &models.Book{}
+			database.First(book, 1)
+			return book.Available() == 1
+		}, time.Second, 10*time.Millisecond)
+		lifecycle.Drain()
+		assert.Nil(lifecycle.Wait(context.Background()))
+		database.First(expired, expired.ID)
+		database.First(held, held.ID)
+		assert.Equal(models.OrderCancelled, expired.Status)
+		assert.Equal(models.OrderPending, held.Status)
+	})
+
+	test.Run("Should hold the books for 15 minutes by default", func(test *testing.T) {
+		// Arrange
+		test.Setenv("RESERVATION_TIME", "")
+
+		// Act
+		duration := ReservationTime()
+
+		// Assert
+		assert.Equal(15*time.Minute, duration)
+	})
+}
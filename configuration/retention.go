@@ -0,0 +1,43 @@
+package configuration
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/models"
+	"gorm.io/gorm"
+)
+
+// ScheduleRetention purges in the background the records soft-deleted longer than TRASH_RETENTION ago and the expired
+// idempotency keys, every TRASH_PURGE_INTERVAL
+func ScheduleRetention(lifecycle *lifecycle.Lifecycle, database *gorm.DB) {
+	retention := durationFromEnvironment("TRASH_RETENTION", 30*24*time.Hour)
+	interval := durationFromEnvironment("TRASH_PURGE_INTERVAL", time.Hour)
+	lifecycle.Go("retention", func(context context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-context.Done():
+				return
+			case now := <-ticker.C:
+				books, users, exception := models.PurgeDeleted(database.WithContext(context), now.Add(-retention))
+				if exception != nil {
+					slog.Error("Failed to purge the deleted records", "error", exception.Error())
+				} else if books > 0 || users > 0 {
+					slog.Info("Purged the deleted records", "books", books, "users", users, "retention", retention.String())
+				}
+
+				keys, exception := models.PurgeExpiredIdempotencyKeys(database.WithContext(context), now)
+				if exception != nil {
+					slog.Error("Failed to purge the expired idempotency keys", "error", exception.Error())
+				} else if keys > 0 {
+					slog.Info("Purged the expired idempotency keys", "keys", keys)
+				}
+			}
+		}
+	})
+}
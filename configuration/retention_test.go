@@ -0,0 +1,43 @@
+package configuration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/migrations/migrationstest"
+	"github.com/zatarain/bookshop/models"
+)
+
+func TestScheduleRetention(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should purge the records deleted longer than the retention and the expired keys until draining", func(test *testing.T) {
+		// Arrange
+		test.Setenv("TRASH_RETENTION", "1h")
+		test.Setenv("TRASH_PURGE_INTERVAL", "10ms")
+		database := migrationstest.Migrated(test)
+		database.Create(&models.Book{Title: "Recent"})
+		database.Create(&models.Book{Title: "Expired"})
+		database.Unscoped().Model(&models.Book{}).Where("title = ?", "Recent").Update("deleted_at", time.Now())
+		database.Unscoped().Model(&models.Book{}).Where("title = ?", "Expired").Update("deleted_at", time.Now().Add(-2*time.Hour))
+		database.Create(&models.IdempotencyKey{Key: "expired", Fingerprint: "dummy", ExpiresAt: time.Now().Add(-time.Minute)})
+		database.Create(&models.IdempotencyKey{Key: "open", Fingerprint: "dummy", ExpiresAt: time.Now().Add(time.Hour)})
+		lifecycle := lifecycle.New()
+
+		// Act
+		ScheduleRetention(lifecycle, database)
+
+		// Assert
+		assert.Eventually(func() bool {
+			var books, keys int64
+			database.Unscoped().Model(&models.Book{}).Count(&books)
+			database.Model(&models.IdempotencyKey{}).Count(&keys)
+			return books == 1 && keys == 1
+		}, time.Second, 10*time.Millisecond)
+		lifecycle.Drain()
+		assert.Nil(lifecycle.Wait(context.Background()))
+	})
+}
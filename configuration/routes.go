@@ -2,18 +2,188 @@ package configuration
 
 import (
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/zatarain/bookshop/controllers"
+	"github.com/zatarain/bookshop/metrics"
+	"github.com/zatarain/bookshop/middlewares"
+	"github.com/zatarain/bookshop/openapi"
 )
 
+// Unversioned announces the removal of the aliases kept at the root for the clients of the /v1 end-points
+var Unversioned = middlewares.Deprecation{
+	Since:     time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+	Sunset:    time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+	Successor: "/v1",
+}
+
+// API registers the end-points of one version of the API, so a /v2 group can live next to /v1 with its own controllers
+type API func(router gin.IRouter)
+
+// Handlers gathers the controllers and the middlewares of the end-points of the API
+type Handlers struct {
+	Users      *controllers.UsersController
+	Books      *controllers.BooksController
+	Accounts   *controllers.AccountsController
+	Carts      *controllers.CartsController
+	Orders     *controllers.OrdersController
+	Webhooks   *controllers.WebhooksController
+	Suppliers  *controllers.SuppliersController
+	Purchases  *controllers.PurchasesController
+	Locations  *controllers.LocationsController
+	Idempotent gin.HandlerFunc
+	Payable    gin.HandlerFunc
+}
+
+func V1(handlers *Handlers) API {
+	users, books, accounts := handlers.Users, handlers.Books, handlers.Accounts
+	idempotent, payable := handlers.Idempotent, handlers.Payable
+	carts, orders, webhooks := handlers.Carts, handlers.Orders, handlers.Webhooks
+	suppliers, purchases, locations := handlers.Suppliers, handlers.Purchases, handlers.Locations
+	return func(router gin.IRouter) {
+		router.POST("/signup", idempotent, users.Signup)
+		router.POST("/login", users.Login)
+		router.GET("/books", users.Authorise, users.AdministerTrash, books.Index)
+		router.POST("/books", users.Authorise, idempotent, books.Add)
+		router.GET("/books/:id", users.Authorise, books.View)
+		router.PUT("/books/:id", users.Authorise, books.Edit)
+		router.DELETE("/books/:id", users.Authorise, books.Delete)
+		router.POST("/books/:id/checkout", users.Authorise, idempotent, books.Checkout)
+		router.POST("/books/:id/restore", users.Authorise, users.Administer, books.Restore)
+		router.DELETE("/books/:id/purge", users.Authorise, users.Administer, books.Purge)
+		router.GET("/books/:id/stock", users.Authorise, users.Administer, books.History)
+		router.POST("/books/:id/stock", users.Authorise, users.Administer, idempotent, books.Record)
+		router.POST("/books/:id/transfers", users.Authorise, users.Administer, idempotent, books.Transfer)
+		router.GET("/locations", users.Authorise, locations.Index)
+		router.POST("/locations", users.Authorise, users.Administer, idempotent, locations.Add)
+		router.GET("/locations/:id", users.Authorise, locations.View)
+		router.GET("/stock/audit", users.Authorise, users.Administer, books.Audit)
+		router.POST("/stock/reconcile", users.Authorise, users.Administer, books.Reconcile)
+		router.GET("/inventory/low-stock", users.Authorise, users.Administer, books.LowStock)
+		router.GET("/inventory/outstanding-purchase-orders", users.Authorise, users.Administer, purchases.Outstanding)
+		router.GET("/suppliers", users.Authorise, users.Administer, suppliers.Index)
+		router.POST("/suppliers", users.Authorise, users.Administer, idempotent, suppliers.Add)
+		router.GET("/suppliers/:id", users.Authorise, users.Administer, suppliers.View)
+		router.GET("/purchase-orders", users.Authorise, users.Administer, purchases.Index)
+		router.POST("/purchase-orders", users.Authorise, users.Administer, idempotent, purchases.Add)
+		router.GET("/purchase-orders/:id", users.Authorise, users.Administer, purchases.View)
+		router.POST("/purchase-orders/:id/send", users.Authorise, users.Administer, purchases.Send)
+		router.POST("/purchase-orders/:id/receive", users.Authorise, users.Administer, idempotent, purchases.Receive)
+		router.GET("/users", users.Authorise, users.Administer, accounts.Index)
+		router.DELETE("/users/:id", users.Authorise, users.Administer, accounts.Delete)
+		router.POST("/users/:id/restore", users.Authorise, users.Administer, accounts.Restore)
+		router.DELETE("/users/:id/purge", users.Authorise, users.Administer, accounts.Purge)
+		router.GET("/cart", users.Identify, carts.View)
+		router.POST("/cart/items", users.Identify, idempotent, carts.Add)
+		router.PUT("/cart/items/:book_id", users.Identify, carts.Update)
+		router.DELETE("/cart/items/:book_id", users.Identify, carts.Remove)
+		router.POST("/cart/checkout", users.Authorise, payable, idempotent, carts.Checkout)
+		router.GET("/me/orders", users.Authorise, orders.Mine)
+		router.GET("/orders", users.Authorise, users.Administer, orders.Index)
+		router.GET("/orders/:id", users.Authorise, orders.View)
+		router.POST("/orders/:id/pay", users.Authorise, payable, idempotent, orders.Pay)
+		router.POST("/orders/:id/cancel", users.Authorise, orders.Cancel)
+		router.PUT("/orders/:id/status", users.Authorise, users.Administer, orders.Move)
+		router.POST("/payments/webhook", payable, webhooks.Receive)
+	}
+}
+
+// listFromEnvironment reads the comma separated items of the variable, skipping the blank ones
+func listFromEnvironment(variable string) []string {
+	items := []string{}
+	for _, item := range strings.Split(os.Getenv(variable), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// administrators reads the comma separated nicknames of the ADMINISTRATORS variable
+func administrators() []string {
+	return listFromEnvironment("ADMINISTRATORS")
+}
+
 func Setup(server gin.IRouter) {
+	staff := administrators()
+	provider, reservation, notifier := PaymentProvider(), ReservationTime(), Notifier()
+	carts := &controllers.CartsController{
+		Database:    Database,
+		Payments:    provider,
+		Reservation: reservation,
+		Notifier:    notifier,
+		Lifecycle:   Lifecycle,
+	}
 	users := &controllers.UsersController{
 		Database:       Database,
 		SecretTokenKey: os.Getenv("SECRET_TOKEN_KEY"),
+		Administrators: staff,
+		Carts:          carts,
+	}
+	books := &controllers.BooksController{
+		Database:    Database,
+		Reservation: reservation,
+		Notifier:    notifier,
+		Lifecycle:   Lifecycle,
+	}
+	accounts := &controllers.AccountsController{
+		Database: Database,
+	}
+	orders := &controllers.OrdersController{
+		Database:       Database,
+		Administrators: staff,
+		Payments:       provider,
+	}
+	webhooks := &controllers.WebhooksController{
+		Database: Database,
+		Payments: provider,
+	}
+	suppliers := &controllers.SuppliersController{
+		Database: Database,
 	}
-	server.HEAD("/health", controllers.HealthCheck)
-	server.POST("/signup", users.Signup)
-	server.POST("/login", users.Login)
-	server.GET("/books", users.Authorise, controllers.GetBooks)
+	purchases := &controllers.PurchasesController{
+		Database: Database,
+	}
+	locations := &controllers.LocationsController{
+		Database: Database,
+	}
+	health := &controllers.HealthController{
+		Lifecycle: Lifecycle,
+		Liveness:  Liveness,
+		Readiness: Readiness,
+	}
+	exporter := &controllers.MetricsController{
+		Gatherer: metrics.Registry,
+		Token:    os.Getenv("METRICS_TOKEN"),
+	}
+	docs := &controllers.DocsController{
+		Specification: openapi.Specification,
+		Documentation: openapi.Documentation,
+	}
+	RegisterHealthChecks()
+	server.HEAD("/health", health.Check)
+	server.GET("/health/live", health.Live)
+	server.GET("/health/ready", health.Ready)
+	server.GET("/metrics", exporter.Expose)
+	server.GET("/openapi.json", docs.Specify)
+	server.GET("/docs", docs.Document)
+
+	v1 := V1(&Handlers{
+		Users:      users,
+		Books:      books,
+		Accounts:   accounts,
+		Carts:      carts,
+		Orders:     orders,
+		Webhooks:   webhooks,
+		Suppliers:  suppliers,
+		Purchases:  purchases,
+		Locations:  locations,
+		Idempotent: middlewares.Idempotency(Database, durationFromEnvironment("IDEMPOTENCY_WINDOW", 24*time.Hour), controllers.CartCookie),
+		Payable:    controllers.Payable(provider),
+	})
+	v1(server.Group("/v1"))
+	v1(server.Group("", middlewares.Deprecated(Unversioned)))
 }
@@ -1,10 +1,19 @@
 package configuration
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/controllers"
+	"github.com/zatarain/bookshop/middlewares"
 	"github.com/zatarain/bookshop/mocks"
+	"github.com/zatarain/bookshop/openapi"
 )
 
 func TestSetup(test *testing.T) {
@@ -12,11 +21,14 @@ func TestSetup(test *testing.T) {
 		// Arrange
 		server := new(mocks.MockedEngine)
 		endPointHandler := mock.AnythingOfType("gin.HandlerFunc")
-		autorisationHandler := mock.AnythingOfType("gin.HandlerFunc")
 		server.On("HEAD", "/health", endPointHandler).Return(server)
-		server.On("POST", "/signup", endPointHandler).Return(server)
-		server.On("POST", "/login", endPointHandler).Return(server)
-		server.On("GET", "/books", autorisationHandler, endPointHandler).Return(server)
+		server.On("GET", "/health/live", endPointHandler).Return(server)
+		server.On("GET", "/health/ready", endPointHandler).Return(server)
+		server.On("GET", "/metrics", endPointHandler).Return(server)
+		server.On("GET", "/openapi.json", endPointHandler).Return(server)
+		server.On("GET", "/docs", endPointHandler).Return(server)
+		server.On("Group", "/v1").Return(gin.New().Group("/v1"))
+		server.On("Group", "", mock.AnythingOfType("gin.HandlerFunc")).Return(gin.New().Group(""))
 
 		// Act
 		Setup(server)
@@ -24,4 +36,84 @@ func TestSetup(test *testing.T) {
 		// Assert
 		server.AssertExpectations(test)
 	})
+	test.Run("Should document every end-point in the OpenAPI specification and nothing else", func(test *testing.T) {
+		// Arrange
+		gin.SetMode(gin.TestMode)
+		server := gin.New()
+		routes := []string{}
+		documented, exception := openapi.Operations(openapi.Specification)
+		require.Nil(test, exception)
+
+		expected := append([]string{}, documented...)
+		for _, operation := range documented {
+			method, path, _ := strings.Cut(operation, " ")
+			if alias, versioned := strings.CutPrefix(path, "/v1/"); versioned {
+				expected = append(expected, method+" /"+alias)
+			}
+		}
+
+		// Act
+		Setup(server)
+		for _, route := range server.Routes() {
+			routes = append(routes, route.Method+" "+openapi.Route(route.Path))
+		}
+
+		// Assert
+		assert.ElementsMatch(test, expected, routes)
+	})
+
+	test.Run("Should only deprecate the unversioned aliases", func(test *testing.T) {
+		// Arrange
+		gin.SetMode(gin.TestMode)
+		server := gin.New()
+		Setup(server)
+		alias, _ := http.NewRequest(http.MethodPost, "/login", strings.NewReader("{"))
+		versioned, _ := http.NewRequest(http.MethodPost, "/v1/login", strings.NewReader("{"))
+		deprecated := httptest.NewRecorder()
+		current := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(deprecated, alias)
+		server.ServeHTTP(current, versioned)
+
+		// Assert
+		assert.Equal(test, "@1792368000", deprecated.Header().Get("Deprecation"))
+		assert.Equal(test, "Mon, 19 Apr 2027 00:00:00 GMT", deprecated.Header().Get("Sunset"))
+		assert.Equal(test, `</v1/login>; rel="successor-version"`, deprecated.Header().Get("Link"))
+		assert.Empty(test, current.Header().Get("Deprecation"))
+		assert.Empty(test, current.Header().Get("Sunset"))
+	})
+}
+
+func TestVersions(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+
+	test.Run("Should serve a new version next to /v1 with other controllers", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		server.Use(middlewares.Problems())
+		users := &controllers.UsersController{SecretTokenKey: "dummy-secret"}
+		books := &controllers.BooksController{}
+		var v2 API = func(router gin.IRouter) {
+			router.GET("/books", func(context *gin.Context) {
+				context.String(http.StatusOK, "v2")
+			})
+		}
+		V1(&Handlers{Users: users, Books: books})(server.Group("/v1"))
+		v2(server.Group("/v2"))
+		first, _ := http.NewRequest(http.MethodGet, "/v1/books", nil)
+		second, _ := http.NewRequest(http.MethodGet, "/v2/books", nil)
+		v1Recorder := httptest.NewRecorder()
+		v2Recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(v1Recorder, first)
+		server.ServeHTTP(v2Recorder, second)
+
+		// Assert
+		assert.Equal(http.StatusUnauthorized, v1Recorder.Code)
+		assert.Equal(http.StatusOK, v2Recorder.Code)
+		assert.Equal("v2", v2Recorder.Body.String())
+	})
 }
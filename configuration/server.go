@@ -0,0 +1,102 @@
+package configuration
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zatarain/bookshop/lifecycle"
+)
+
+var Lifecycle = lifecycle.New()
+
+type Server struct {
+	HTTP            *http.Server
+	Lifecycle       *lifecycle.Lifecycle
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration
+}
+
+func durationFromEnvironment(variable string, fallback time.Duration) time.Duration {
+	value := os.Getenv(variable)
+	if value == "" {
+		return fallback
+	}
+
+	duration, exception := time.ParseDuration(value)
+	if exception != nil {
+		slog.Warn("Invalid duration, using the default", "variable", variable, "value", value, "default", fallback.String())
+		return fallback
+	}
+
+	return duration
+}
+
+// serverPort is the port of PORT where the server listens, 8080 by default
+func serverPort() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return port
+	}
+
+	return "8080"
+}
+
+func NewServer(handler http.Handler) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              ":" + serverPort(),
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		Lifecycle:       Lifecycle,
+		DrainDelay:      durationFromEnvironment("SHUTDOWN_DRAIN_DELAY", 0),
+		ShutdownTimeout: durationFromEnvironment("SHUTDOWN_TIMEOUT", 15*time.Second),
+	}
+}
+
+func (server *Server) Run() error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	failure := make(chan error, 1)
+	go func() {
+		slog.Info("Listening and serving HTTP", "address", server.HTTP.Addr)
+		if exception := server.HTTP.ListenAndServe(); !errors.Is(exception, http.ErrServerClosed) {
+			failure <- exception
+		}
+	}()
+
+	select {
+	case exception := <-failure:
+		return exception
+	case received := <-stop:
+		slog.Info("Shutting down the server", "signal", received.String())
+	}
+
+	return server.Shutdown()
+}
+
+func (server *Server) Shutdown() error {
+	// Report not ready and give the load balancer some time to notice it
+	server.Lifecycle.Withdraw()
+	time.Sleep(server.DrainDelay)
+
+	deadline, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for the in-flight requests, which can still start workers, then stop the
+	// workers and wait for them
+	exception := server.HTTP.Shutdown(deadline)
+	server.Lifecycle.Drain()
+	if waiting := server.Lifecycle.Wait(deadline); exception == nil {
+		exception = waiting
+	}
+
+	return exception
+}
@@ -0,0 +1,197 @@
+package configuration
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/lifecycle"
+)
+
+func freeAddress(test *testing.T) string {
+	listener, exception := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(test, exception)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func waitUntilListening(test *testing.T, address string) {
+	for attempt := 0; attempt < 100; attempt++ {
+		if connection, exception := net.Dial("tcp", address); exception == nil {
+			connection.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.Fatalf("Server never started listening on %s", address)
+}
+
+func TestNewServer(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should read the port and timeouts from the environment", func(test *testing.T) {
+		// Arrange
+		test.Setenv("PORT", "4321")
+		test.Setenv("SHUTDOWN_TIMEOUT", "3s")
+		test.Setenv("SHUTDOWN_DRAIN_DELAY", "invalid")
+
+		// Act
+		server := NewServer(http.NotFoundHandler())
+
+		// Assert
+		assert.Equal(":4321", server.HTTP.Addr)
+		assert.Equal(3*time.Second, server.ShutdownTimeout)
+		assert.Equal(time.Duration(0), server.DrainDelay)
+		assert.Equal(Lifecycle, server.Lifecycle)
+	})
+}
+
+func TestServerRun(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should return the error when unable to listen", func(test *testing.T) {
+		// Arrange
+		occupied, _ := net.Listen("tcp", "127.0.0.1:0")
+		defer occupied.Close()
+		server := &Server{
+			HTTP:      &http.Server{Addr: occupied.Addr().String()},
+			Lifecycle: lifecycle.New(),
+		}
+
+		// Act
+		exception := server.Run()
+
+		// Assert
+		assert.ErrorContains(exception, "address already in use")
+	})
+
+	test.Run("Should shutdown gracefully when receiving a termination signal", func(test *testing.T) {
+		// Arrange
+		address := freeAddress(test)
+		server := &Server{
+			HTTP:            &http.Server{Addr: address, Handler: http.NotFoundHandler()},
+			Lifecycle:       lifecycle.New(),
+			ShutdownTimeout: time.Second,
+		}
+		finished := make(chan error)
+		go func() { finished <- server.Run() }()
+		waitUntilListening(test, address)
+
+		// Act
+		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+
+		// Assert
+		select {
+		case exception := <-finished:
+			assert.Nil(exception)
+			assert.True(server.Lifecycle.Draining())
+		case <-time.After(5 * time.Second):
+			assert.Fail("The server did not stop after the signal")
+		}
+	})
+}
+
+func TestServerShutdown(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should finish in-flight requests and workers before returning", func(test *testing.T) {
+		// Arrange
+		address := freeAddress(test)
+		started := make(chan struct{})
+		handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			writer.Write([]byte("Finished"))
+		})
+		server := &Server{
+			HTTP:            &http.Server{Addr: address, Handler: handler},
+			Lifecycle:       lifecycle.New(),
+			ShutdownTimeout: 5 * time.Second,
+		}
+		workerStopped := false
+		server.Lifecycle.Go("dummy", func(context context.Context) {
+			<-context.Done()
+			workerStopped = true
+		})
+		go server.HTTP.ListenAndServe()
+		waitUntilListening(test, address)
+		responses := make(chan *http.Response)
+		go func() {
+			response, _ := http.Get("http://" + address + "/slow")
+			responses <- response
+		}()
+		<-started
+
+		// Act
+		exception := server.Shutdown()
+
+		// Assert
+		response := <-responses
+		assert.Nil(exception)
+		assert.True(workerStopped)
+		require.NotNil(test, response)
+		assert.Equal(http.StatusOK, response.StatusCode)
+	})
+
+	test.Run("Should let the in-flight requests start workers before stopping them", func(test *testing.T) {
+		// Arrange
+		address := freeAddress(test)
+		started := make(chan struct{})
+		server := &Server{
+			HTTP:            &http.Server{Addr: address},
+			Lifecycle:       lifecycle.New(),
+			ShutdownTimeout: 5 * time.Second,
+		}
+		workerRan := false
+		server.HTTP.Handler = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			server.Lifecycle.Go("notification", func(context context.Context) {
+				workerRan = context.Err() == nil
+			})
+			writer.Write([]byte("Finished"))
+		})
+		go server.HTTP.ListenAndServe()
+		waitUntilListening(test, address)
+		responses := make(chan *http.Response)
+		go func() {
+			response, _ := http.Get("http://" + address + "/notify")
+			responses <- response
+		}()
+		<-started
+
+		// Act
+		exception := server.Shutdown()
+
+		// Assert
+		response := <-responses
+		assert.Nil(exception)
+		assert.True(workerRan)
+		assert.True(server.Lifecycle.Draining())
+		require.NotNil(test, response)
+		assert.Equal(http.StatusOK, response.StatusCode)
+	})
+
+	test.Run("Should fail when in-flight work exceeds the timeout", func(test *testing.T) {
+		// Arrange
+		server := &Server{
+			HTTP:            &http.Server{},
+			Lifecycle:       lifecycle.New(),
+			ShutdownTimeout: 10 * time.Millisecond,
+		}
+		release := make(chan struct{})
+		defer close(release)
+		server.Lifecycle.Go("stubborn", func(context.Context) { <-release })
+
+		// Act
+		exception := server.Shutdown()
+
+		// Assert
+		assert.ErrorIs(exception, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"gorm.io/gorm"
+)
+
+// AccountsController lets the administrators manage the users, including the soft-deleted ones
+type AccountsController struct {
+	Database *gorm.DB
+}
+
+var ErrUserNotFound = problems.ErrNotFound.WithDetail("The user was not found")
+
+func (accounts *AccountsController) database(context *gin.Context) *gorm.DB {
+	return accounts.Database.WithContext(context.Request.Context())
+}
+
+// find looks for the user of the path, soft-deleted or not
+func (accounts *AccountsController) find(context *gin.Context) *models.User {
+	identifier, exception := strconv.Atoi(context.Param("id"))
+	if exception != nil {
+		problems.Abort(context, ErrUserNotFound.Wrap(exception))
+		return nil
+	}
+
+	user := &models.User{}
+	if exception := accounts.database(context).Unscoped().First(user, identifier).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrUserNotFound.Wrap(exception))
+		return nil
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return nil
+	}
+
+	return user
+}
+
+func (accounts *AccountsController) Index(context *gin.Context) {
+	database, exception := models.Deleted(accounts.database(context), context.Query("deleted"))
+	if exception != nil {
+		problems.Abort(context, deletedFilterProblem(exception))
+		return
+	}
+
+	records := []models.User{}
+	if exception := database.Order("id").Find(&records).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	response := make([]UserResponse, len(records))
+	for index := range records {
+		response[index] = NewUserResponse(&records[index])
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+func (accounts *AccountsController) Delete(context *gin.Context) {
+	user := accounts.find(context)
+	if user == nil {
+		return
+	}
+
+	if exception := accounts.database(context).Delete(user).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+func (accounts *AccountsController) Restore(context *gin.Context) {
+	user := accounts.find(context)
+	if user == nil {
+		return
+	}
+
+	if user.DeletedAt.Valid {
+		if exception := user.Restore(accounts.database(context)); exception != nil {
+			problems.Abort(context, exception)
+			return
+		}
+
+		user.DeletedAt = gorm.DeletedAt{}
+	}
+
+	context.JSON(http.StatusOK, NewUserResponse(user))
+}
+
+func (accounts *AccountsController) Purge(context *gin.Context) {
+	user := accounts.find(context)
+	if user == nil {
+		return
+	}
+
+	if exception := user.Purge(accounts.database(context)); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
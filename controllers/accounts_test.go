@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/models"
+	"gorm.io/gorm"
+)
+
+func setupAccounts(test *testing.T) (*gin.Engine, *gorm.DB) {
+	server, database := setupServer(test)
+
+	users := &UsersController{Database: database, SecretTokenKey: "dummy-secret"}
+	accounts := &AccountsController{Database: database}
+	server.POST("/signup", users.Signup)
+	server.POST("/login", users.Login)
+	server.GET("/books", users.Authorise, func(context *gin.Context) {
+		context.Status(http.StatusNoContent)
+	})
+	server.GET("/users", accounts.Index)
+	server.DELETE("/users/:id", accounts.Delete)
+	server.POST("/users/:id/restore", accounts.Restore)
+	server.DELETE("/users/:id/purge", accounts.Purge)
+	return server, database
+}
+
+func TestAccounts(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupAccounts(test)
+	credentials := `{"nickname": "dummy-user", "password": "top-secret"}`
+	require.Equal(test, http.StatusCreated, serve(server, http.MethodPost, "/signup", credentials).Code)
+	require.Equal(test, http.StatusCreated, serve(server, http.MethodPost, "/signup", `{"nickname": "other-user", "password": "top-secret"}`).Code)
+	login := serve(server, http.MethodPost, "/login", credentials)
+	cookie := login.Result().Cookies()[0]
+
+	test.Run("Should soft-delete the user", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodDelete, "/users/1", "")
+
+		// Assert
+		var count int64
+		database.Unscoped().Model(&models.User{}).Where("id = ?", 1).Count(&count)
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.Equal(int64(1), count)
+	})
+
+	test.Run("Should NOT let a deleted user log in", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/login", credentials)
+
+		// Assert
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"invalid_credentials"`)
+	})
+
+	test.Run("Should NOT authorise the sessions of a deleted user", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/books", "", "Cookie", cookie.String())
+
+		// Assert
+		assert.Equal(http.StatusUnauthorized, recorder.Code)
+	})
+
+	test.Run("Should list only the deleted users", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/users?deleted=only", "")
+
+		// Assert
+		var response []UserResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		require.Len(test, response, 1)
+		assert.Equal("dummy-user", response[0].Nickname)
+		assert.NotNil(response[0].DeletedAt)
+		assert.NotContains(recorder.Body.String(), "password")
+	})
+
+	test.Run("Should list the active users by default", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/users", "")
+
+		// Assert
+		var response []UserResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		require.Len(test, response, 1)
+		assert.Equal("other-user", response[0].Nickname)
+	})
+
+	test.Run("Should reject an unknown filter of deleted users", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/users?deleted=yes", "")
+
+		// Assert
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+
+	test.Run("Should restore the user and let them log in again", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/users/1/restore", "")
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.NotContains(recorder.Body.String(), "deleted_at")
+		assert.Equal(http.StatusOK, serve(server, http.MethodPost, "/login", credentials).Code)
+	})
+
+	test.Run("Should purge the user permanently", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodDelete, "/users/1/purge", "")
+
+		// Assert
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.Equal(http.StatusNotFound, serve(server, http.MethodPost, "/users/1/restore", "").Code)
+		assert.Equal(http.StatusCreated, serve(server, http.MethodPost, "/signup", credentials).Code)
+	})
+
+	for _, path := range []string{"/users/7", "/users/seven"} {
+		test.Run("Should NOT find the user "+path, func(test *testing.T) {
+			// Act
+			recorder := serve(server, http.MethodDelete, path, "")
+
+			// Assert
+			assert.Equal(http.StatusNotFound, recorder.Code)
+			assert.Contains(recorder.Body.String(), "The user was not found")
+		})
+	}
+}
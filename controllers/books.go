@@ -1,11 +1,376 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/metrics"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/notifications"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
 )
 
-func GetBooks(context *gin.Context) {
-	context.JSON(http.StatusOK, gin.H{"message": "hello"})
+type BookInput struct {
+	Title         string        `json:"title" binding:"required,notblank,max=30"`
+	Author        string        `json:"author" binding:"required,notblank,max=30"`
+	Prices        []money.Money `json:"prices" binding:"required,min=1,unique=Currency,dive,gt=0"`
+	Quantity      int           `json:"quantity" binding:"gte=0,lte=99999"`
+	Version       uint          `json:"version,omitempty"`
+	ReorderPoint  int           `json:"reorder_point" binding:"gte=0,lte=99999"`
+	ReorderTarget int           `json:"reorder_target" binding:"gte=0,lte=99999,gtefield=ReorderPoint"`
+}
+
+type BookResponse struct {
+	ID            uint                 `json:"id"`
+	Title         string               `json:"title"`
+	Author        string               `json:"author"`
+	Prices        []money.Money        `json:"prices"`
+	Quantity      int                  `json:"quantity"`
+	Reserved      int                  `json:"reserved"`
+	Available     int                  `json:"available"`
+	Locations     []StockLevelResponse `json:"locations"`
+	ReorderPoint  int                  `json:"reorder_point"`
+	ReorderTarget int                  `json:"reorder_target"`
+	Version       uint                 `json:"version"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	DeletedAt     *time.Time           `json:"deleted_at,omitempty"`
+}
+
+// BooksController manages the catalogue, the copies bought with a checkout are reserved for the Reservation time while
+// the order is paid and the Notifier is told about the books running low
+type BooksController struct {
+	Database    *gorm.DB
+	Reservation time.Duration
+	Notifier    notifications.Notifier
+	Lifecycle   *lifecycle.Lifecycle
+}
+
+var ErrBookNotFound = problems.ErrNotFound.WithDetail("The book was not found")
+
+func (input *BookInput) Apply(book *models.Book) {
+	book.Title = input.Title
+	book.Author = input.Author
+	book.Quantity = input.Quantity
+	book.ReorderPoint = input.ReorderPoint
+	book.ReorderTarget = input.ReorderTarget
+	book.Prices = make([]models.BookPrice, len(input.Prices))
+	for index, price := range input.Prices {
+		book.Prices[index] = models.BookPrice{BookID: book.ID, Price: price}
+	}
+}
+
+func NewBookResponse(book *models.Book) BookResponse {
+	prices := make([]money.Money, len(book.Prices))
+	for index, price := range book.Prices {
+		prices[index] = price.Price
+	}
+
+	return BookResponse{
+		ID:            book.ID,
+		Title:         book.Title,
+		Author:        book.Author,
+		Prices:        prices,
+		Quantity:      book.Quantity,
+		Reserved:      book.Reserved,
+		Available:     book.Available(),
+		Locations:     NewStockLevelResponses(book.Levels),
+		ReorderPoint:  book.ReorderPoint,
+		ReorderTarget: book.ReorderTarget,
+		Version:       book.Version,
+		CreatedAt:     book.CreatedAt,
+		UpdatedAt:     book.UpdatedAt,
+		DeletedAt:     deletedAt(book.DeletedAt),
+	}
+}
+
+func (books *BooksController) database(context *gin.Context) *gorm.DB {
+	return books.Database.WithContext(context.Request.Context())
+}
+
+func withPrices(database *gorm.DB) *gorm.DB {
+	return database.Preload("Prices", func(database *gorm.DB) *gorm.DB {
+		return database.Order("id")
+	})
+}
+
+// withStock loads the prices of the books along with their copies at every location
+func withStock(database *gorm.DB) *gorm.DB {
+	return withPrices(database).Preload("Levels", func(database *gorm.DB) *gorm.DB {
+		return database.Order("location_id")
+	})
+}
+
+func (books *BooksController) find(context *gin.Context) *models.Book {
+	return books.findIn(context, books.database(context))
+}
+
+// findIn looks for the book of the path in the given scope, e.g. the unscoped database to find the soft-deleted ones
+func (books *BooksController) findIn(context *gin.Context, database *gorm.DB) *models.Book {
+	identifier, exception := strconv.ParseUint(context.Param("id"), 10, 64)
+	if exception != nil {
+		problems.Abort(context, ErrBookNotFound.Wrap(exception))
+		return nil
+	}
+
+	book := &models.Book{}
+	if exception := withStock(database).First(book, identifier).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrBookNotFound.Wrap(exception))
+		return nil
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return nil
+	}
+
+	return book
+}
+
+// reload reads the book again, so the answer and its ETag show what was stored with the precision of the database
+func (books *BooksController) reload(context *gin.Context, book *models.Book) bool {
+	identifier := book.ID
+	*book = models.Book{}
+	if exception := withStock(books.database(context)).First(book, identifier).Error; exception != nil {
+		problems.Abort(context, exception)
+		return false
+	}
+
+	return true
+}
+
+// precondition rejects the changes made from a stale copy of the book, i.e. when If-Match doesn't match its ETag
+func precondition(context *gin.Context, book *models.Book) bool {
+	header := context.GetHeader("If-Match")
+	if header != "" && !matches(header, ETag(book), false) {
+		problems.Abort(context, problems.ErrPreconditionFailed.WithDetail("The book was modified since it was read"))
+		return false
+	}
+
+	return true
+}
+
+// conflict answers the current representation of a book which was modified by someone else
+func (books *BooksController) conflict(context *gin.Context, book *models.Book, cause error) {
+	if !books.reload(context, book) {
+		return
+	}
+
+	context.Header("ETag", ETag(book))
+	problems.Abort(context, problems.ErrVersionConflict.
+		WithDetail("The book was modified by someone else, this is its current version").
+		WithCurrent(NewBookResponse(book)).
+		Wrap(cause))
+}
+
+func respond(context *gin.Context, status int, book *models.Book) {
+	context.Header("ETag", ETag(book))
+	context.JSON(status, NewBookResponse(book))
+}
+
+// Index lists the books of the catalogue, optionally only the ones with copies available at a location
+func (books *BooksController) Index(context *gin.Context) {
+	database, exception := models.Deleted(books.database(context), context.Query("deleted"))
+	if exception != nil {
+		problems.Abort(context, deletedFilterProblem(exception))
+		return
+	}
+
+	location, ok := preferredLocation(context, books.database(context))
+	if !ok {
+		return
+	} else if location != 0 {
+		database = models.AvailableAt(database, location)
+	}
+
+	records := []models.Book{}
+	if exception := withStock(database).Order("id").Find(&records).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	response := make([]BookResponse, len(records))
+	for index := range records {
+		response[index] = NewBookResponse(&records[index])
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+func (books *BooksController) View(context *gin.Context) {
+	book := books.find(context)
+	if book == nil {
+		return
+	}
+
+	// The clients polling the book get an empty answer while it doesn't change
+	if matches(context.GetHeader("If-None-Match"), ETag(book), true) {
+		context.Header("ETag", ETag(book))
+		context.Status(http.StatusNotModified)
+		return
+	}
+
+	respond(context, http.StatusOK, book)
+}
+
+func (books *BooksController) Add(context *gin.Context) {
+	var input BookInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	book := &models.Book{}
+	input.Apply(book)
+	if exception := book.Create(books.database(context), authorised(context)); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	if !books.reload(context, book) {
+		return
+	}
+
+	context.Header("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(context.Request.URL.Path, "/"), book.ID))
+	respond(context, http.StatusCreated, book)
+}
+
+func (books *BooksController) Edit(context *gin.Context) {
+	book := books.find(context)
+	if book == nil || !precondition(context, book) {
+		return
+	}
+
+	var input BookInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	// The changes are made on the version read by the client when it tells it, otherwise on the one just read
+	input.Apply(book)
+	if input.Version != 0 {
+		book.Version = input.Version
+	}
+
+	if exception := book.Update(books.database(context), authorised(context)); errors.Is(exception, models.ErrStaleBook) {
+		books.conflict(context, book, exception)
+		return
+	} else if exception != nil {
+		problems.Abort(context, orderProblem(exception))
+		return
+	}
+
+	if books.reload(context, book) {
+		respond(context, http.StatusOK, book)
+	}
+}
+
+func (books *BooksController) Delete(context *gin.Context) {
+	book := books.find(context)
+	if book == nil || !precondition(context, book) {
+		return
+	}
+
+	if exception := book.Remove(books.database(context)); errors.Is(exception, models.ErrStaleBook) {
+		books.conflict(context, book, exception)
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// Checkout places an order of one copy of the book for the authorised user, preferably from the location of the query,
+// and answers the book with the copies left
+func (books *BooksController) Checkout(context *gin.Context) {
+	currency, ok := currency(context)
+	if !ok {
+		return
+	}
+
+	location, ok := preferredLocation(context, books.database(context))
+	if !ok {
+		return
+	}
+
+	user := authorised(context)
+	if user == nil {
+		problems.Abort(context, problems.ErrUnauthorised)
+		return
+	}
+
+	book := books.find(context)
+	if book == nil {
+		metrics.Checkouts.WithLabelValues("not_found").Inc()
+		return
+	}
+
+	// The order only reserves the copy when there is one available, so concurrent checkouts can't oversell
+	lines := []models.OrderLine{{BookID: book.ID, Quantity: 1}}
+	order, exception := models.PlaceOrder(books.database(context), user, currency, lines, time.Now().Add(books.Reservation), location)
+	if errors.Is(exception, models.ErrInsufficientStock) {
+		metrics.Checkouts.WithLabelValues("out_of_stock").Inc()
+		problems.Abort(context, problems.ErrOutOfStock.Wrap(exception))
+		return
+	} else if exception != nil {
+		metrics.Checkouts.WithLabelValues("error").Inc()
+		problems.Abort(context, orderProblem(exception))
+		return
+	}
+
+	if !books.reload(context, book) {
+		return
+	}
+
+	metrics.Checkouts.WithLabelValues("success").Inc()
+	if book.Available() == 0 {
+		metrics.StockOuts.Inc()
+	}
+
+	alertLowStock(context, books.Lifecycle, books.Notifier, order)
+
+	context.Header("Location", orderLocation(context, "/books/:id/checkout", order))
+	respond(context, http.StatusOK, book)
+}
+
+func (books *BooksController) Restore(context *gin.Context) {
+	book := books.findIn(context, books.database(context).Unscoped())
+	if book == nil {
+		return
+	}
+
+	if book.DeletedAt.Valid {
+		if exception := book.Restore(books.database(context)); exception != nil {
+			problems.Abort(context, exception)
+			return
+		}
+	}
+
+	if books.reload(context, book) {
+		respond(context, http.StatusOK, book)
+	}
+}
+
+func (books *BooksController) Purge(context *gin.Context) {
+	book := books.findIn(context, books.database(context).Unscoped())
+	if book == nil {
+		return
+	}
+
+	if exception := book.Purge(books.database(context)); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
 }
@@ -1,28 +1,579 @@
 package controllers
 
 import (
-	"testing"
-
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/metrics"
+	"github.com/zatarain/bookshop/middlewares"
+	"github.com/zatarain/bookshop/migrations/migrationstest"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
 )
 
-func TestGetBooks(test *testing.T) {
-	assert := assert.New(test)
+func setupBooks(test *testing.T) (*gin.Engine, *gorm.DB) {
+	server, database := setupServer(test)
+
+	books := &BooksController{Database: database}
+	server.GET("/books", books.Index)
+	server.POST("/books", books.Add)
+	server.GET("/books/:id", books.View)
+	server.PUT("/books/:id", books.Edit)
+	server.DELETE("/books/:id", books.Delete)
+	server.POST("/books/:id/checkout", func(context *gin.Context) {
+		context.Set("user", &models.User{ID: 1, Nickname: "dummy-user"})
+	}, books.Checkout)
+	server.POST("/v1/books", books.Add)
+	server.POST("/books/:id/restore", books.Restore)
+	server.DELETE("/books/:id/purge", books.Purge)
+	return server, database
+}
+
+// setupServer gives an engine answering the problems along with a database with every migration applied, so the tests
+// only add their own data and routes
+func setupServer(test *testing.T) (*gin.Engine, *gorm.DB) {
 	gin.SetMode(gin.TestMode)
 	server := gin.New()
-	server.HEAD("/books", GetBooks)
-	recorder := httptest.NewRecorder()
+	server.Use(middlewares.Problems())
+	return server, migrationstest.Migrated(test)
+}
+
+// as attaches the user to every request instead of logging in
+func as(user *models.User) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Set("user", user)
+	}
+}
 
-	request, exception := http.NewRequest(http.MethodHead, "/books", nil)
-	assert.Nil(exception)
+func gbp(amount string) []models.BookPrice {
+	return []models.BookPrice{{Price: money.MustParse(amount, "GBP")}}
+}
 
-	// Perform the request
+// serve sends the request with the headers given as name and value pairs
+func serve(server *gin.Engine, method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(method, path, strings.NewReader(body))
+	for index := 0; index+1 < len(headers); index += 2 {
+		request.Header.Set(headers[index], headers[index+1])
+	}
+
+	recorder := httptest.NewRecorder()
 	server.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestBookInput(test *testing.T) {
+	assert := assert.New(test)
+
+	testcases := []struct {
+		description string
+		body        string
+		expected    []problems.FieldError
+	}{
+		{
+			description: "Should accept a valid book",
+			body:        `{"title": "The Hobbit", "author": "J. R. R. Tolkien", "prices": [{"amount": "12.50", "currency": "GBP"}], "quantity": 0}`,
+			expected:    nil,
+		},
+		{
+			description: "Should NOT accept a book without title nor author",
+			body:        `{"title": " ", "prices": [{"amount": "12.50", "currency": "GBP"}], "quantity": 1}`,
+			expected: []problems.FieldError{
+				{Field: "title", Rule: "notblank", Message: "must not be blank"},
+				{Field: "author", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			description: "Should NOT accept texts longer than 30 characters",
+			body:        `{"title": "` + strings.Repeat("a", 31) + `", "author": "` + strings.Repeat("b", 31) + `", "prices": [{"amount": "1", "currency": "GBP"}]}`,
+			expected: []problems.FieldError{
+				{Field: "title", Rule: "max", Message: "must be at most 30 characters long"},
+				{Field: "author", Rule: "max", Message: "must be at most 30 characters long"},
+			},
+		},
+		{
+			description: "Should NOT accept a free book nor negative quantities",
+			body:        `{"title": "The Hobbit", "author": "J. R. R. Tolkien", "prices": [{"amount": "0", "currency": "GBP"}], "quantity": -1}`,
+			expected: []problems.FieldError{
+				{Field: "prices[0]", Rule: "gt", Message: "must be greater than 0"},
+				{Field: "quantity", Rule: "gte", Message: "must be greater than or equal to 0"},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			var input BookInput
+
+			// Act
+			exception := validation.BindJSON(strings.NewReader(testcase.body), &input)
+
+			// Assert
+			if testcase.expected == nil {
+				assert.Nil(exception)
+				return
+			}
+			assert.Equal(testcase.expected, problems.From(exception).Errors)
+		})
+	}
+}
+
+func TestBooksIndex(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupBooks(test)
+
+	test.Run("Should list an empty catalogue", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/books", "")
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.JSONEq("[]", recorder.Body.String())
+	})
+
+	test.Run("Should list the books which are NOT deleted", func(test *testing.T) {
+		// Arrange
+		database.Create(&models.Book{Title: "The Hobbit", Author: "J. R. R. Tolkien", Prices: gbp("12.5"), Quantity: 3})
+		database.Create(&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 1})
+		deleted := &models.Book{Title: "Lost", Author: "Nobody", Prices: gbp("1"), Quantity: 1}
+		database.Create(deleted)
+		database.Delete(deleted)
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/books", "")
+
+		// Assert
+		var books []BookResponse
+		json.Unmarshal(recorder.Body.Bytes(), &books)
+		assert.Equal(http.StatusOK, recorder.Code)
+		require.Len(test, books, 2)
+		assert.Equal("The Hobbit", books[0].Title)
+		assert.Equal("Dune", books[1].Title)
+		assert.NotContains(recorder.Body.String(), "deleted")
+	})
+}
+
+func TestBooksView(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupBooks(test)
+	book := &models.Book{Title: "The Hobbit", Author: "J. R. R. Tolkien", Prices: gbp("12.5"), Quantity: 3}
+	database.Create(book)
+
+	test.Run("Should show the details of the book", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/books/1", "")
+
+		// Assert
+		var response BookResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(book.ID, response.ID)
+		assert.Equal("The Hobbit", response.Title)
+		assert.Equal("J. R. R. Tolkien", response.Author)
+		assert.Equal([]money.Money{money.MustParse("12.50", "GBP")}, response.Prices)
+		assert.Equal(3, response.Quantity)
+	})
+
+	test.Run("Should answer not modified while the book doesn't change", func(test *testing.T) {
+		// Arrange
+		etag := serve(server, http.MethodGet, "/books/1", "").Header().Get("ETag")
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/books/1", "", "If-None-Match", `"stale", W/`+etag)
+
+		// Assert
+		assert.Equal(ETag(book), etag)
+		assert.Equal(http.StatusNotModified, recorder.Code)
+		assert.Equal(etag, recorder.Header().Get("ETag"))
+		assert.Empty(recorder.Body.String())
+	})
+
+	test.Run("Should answer the book when it changed", func(test *testing.T) {
+		// Arrange
+		etag := serve(server, http.MethodGet, "/books/1", "").Header().Get("ETag")
+		database.Model(book).Updates(map[string]any{"quantity": 2, "version": gorm.Expr("version + 1")})
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/books/1", "", "If-None-Match", etag)
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.NotEqual(etag, recorder.Header().Get("ETag"))
+		assert.Contains(recorder.Body.String(), `"quantity":2`)
+	})
+
+	for _, path := range []string{"/books/7", "/books/seven"} {
+		test.Run("Should NOT find the book "+path, func(test *testing.T) {
+			// Act
+			recorder := serve(server, http.MethodGet, path, "")
+
+			// Assert
+			assert.Equal(http.StatusNotFound, recorder.Code)
+			assert.Contains(recorder.Body.String(), "The book was not found")
+		})
+	}
+}
+
+func TestBooksAdd(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupBooks(test)
+
+	test.Run("Should add the book to the catalogue", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/books", `{"title": "Dune", "author": "Frank Herbert", "prices": [{"amount": "9.99", "currency": "GBP"}], "quantity": 2}`)
+
+		// Assert
+		var response BookResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		stored := &models.Book{}
+		database.First(stored, response.ID)
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("/books/1", recorder.Header().Get("Location"))
+		assert.Equal("Dune", stored.Title)
+		assert.Equal(2, stored.Quantity)
+		assert.False(response.CreatedAt.IsZero())
+	})
+
+	test.Run("Should locate the new book under the same version of the API", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/v1/books", `{"title": "Emma", "author": "Jane Austen", "prices": [{"amount": "7.50", "currency": "GBP"}], "quantity": 1}`)
+
+		// Assert
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("/v1/books/2", recorder.Header().Get("Location"))
+	})
+
+	test.Run("Should NOT add an invalid book", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/books", `{"title": "", "author": "Frank Herbert", "prices": [{"amount": "9.99", "currency": "GBP"}], "id": 7}`)
+
+		// Assert
+		var count int64
+		database.Model(&models.Book{}).Count(&count)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"validation_failed"`)
+		assert.Equal(int64(2), count)
+	})
+}
+
+func TestBooksEdit(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupBooks(test)
+	book := &models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 2}
+	database.Create(book)
+
+	test.Run("Should replace the information of the book", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/1", `{"title": "Dune Messiah", "author": "Frank Herbert", "prices": [{"amount": "11.00", "currency": "EUR"}], "quantity": 5}`)
+
+		// Assert
+		stored := &models.Book{}
+		database.Preload("Prices").First(stored, book.ID)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"title":"Dune Messiah"`)
+		assert.Equal("Dune Messiah", stored.Title)
+		require.Len(test, stored.Prices, 1)
+		assert.Equal(money.MustParse("11", "EUR"), stored.Prices[0].Price)
+		assert.Equal(5, stored.Quantity)
+		assert.Equal(book.CreatedAt.Unix(), stored.CreatedAt.Unix())
+	})
+
+	test.Run("Should edit the book when it wasn't modified since it was read", func(test *testing.T) {
+		// Arrange
+		etag := serve(server, http.MethodGet, "/books/1", "").Header().Get("ETag")
+
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/1", `{"title": "Dune Messiah", "author": "Frank Herbert", "prices": [{"amount": "12.00", "currency": "EUR"}], "quantity": 4}`, "If-Match", etag)
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.NotEqual(etag, recorder.Header().Get("ETag"))
+		assert.Equal(recorder.Header().Get("ETag"), serve(server, http.MethodGet, "/books/1", "").Header().Get("ETag"))
+	})
+
+	test.Run("Should NOT overwrite the changes made since the book was read", func(test *testing.T) {
+		// Arrange
+		etag := serve(server, http.MethodGet, "/books/1", "").Header().Get("ETag")
+		serve(server, http.MethodPut, "/books/1", `{"title": "Dune Messiah", "author": "Frank Herbert", "prices": [{"amount": "11.00", "currency": "EUR"}], "quantity": 5}`)
+
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/1", `{"title": "Children of Dune", "author": "Frank Herbert", "prices": [{"amount": "13.00", "currency": "EUR"}], "quantity": 1}`, "If-Match", etag)
+
+		// Assert
+		stored := &models.Book{}
+		database.First(stored, book.ID)
+		assert.Equal(http.StatusPreconditionFailed, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"precondition_failed"`)
+		assert.Equal("Dune Messiah", stored.Title)
+	})
+
+	test.Run("Should NOT edit a book with invalid information", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/1", `{"title": "Dune", "author": "Frank Herbert", "prices": [{"amount": "-1", "currency": "GBP"}]}`)
+
+		// Assert
+		stored := &models.Book{}
+		database.First(stored, book.ID)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Equal("Dune Messiah", stored.Title)
+	})
+
+	test.Run("Should answer the current book when the edit was made on a stale version", func(test *testing.T) {
+		// Arrange
+		stored := &models.Book{}
+		database.First(stored, book.ID)
+
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/1", `{"title": "Children of Dune", "author": "Frank Herbert", "prices": [{"amount": "13.00", "currency": "EUR"}], "quantity": 1, "version": 1}`)
+
+		// Assert
+		var problem struct {
+			Code    string       `json:"code"`
+			Current BookResponse `json:"current"`
+		}
+		json.Unmarshal(recorder.Body.Bytes(), &problem)
+		assert.Equal(http.StatusConflict, recorder.Code)
+		assert.Equal("version_conflict", problem.Code)
+		assert.Equal("Dune Messiah", problem.Current.Title)
+		assert.Equal(stored.Version, problem.Current.Version)
+		assert.Equal(ETag(stored), recorder.Header().Get("ETag"))
+	})
+
+	test.Run("Should edit the book at the version read by the client", func(test *testing.T) {
+		// Arrange
+		stored := &models.Book{}
+		database.First(stored, book.ID)
+		body := fmt.Sprintf(`{"title": "Children of Dune", "author": "Frank Herbert", "prices": [{"amount": "13.00", "currency": "EUR"}], "quantity": 1, "version": %d}`, stored.Version)
+
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/1", body)
+
+		// Assert
+		var response BookResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal("Children of Dune", response.Title)
+		assert.Equal(stored.Version+1, response.Version)
+	})
+
+	test.Run("Should NOT edit a book which doesn't exist", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/7", `{"title": "Dune", "author": "Frank Herbert", "prices": [{"amount": "1", "currency": "GBP"}]}`)
+
+		// Assert
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestBooksDelete(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupBooks(test)
+	database.Create(&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 2})
+
+	test.Run("Should NOT remove a book modified since it was read", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodDelete, "/books/1", "", "If-Match", `"1-0"`)
+
+		// Assert
+		assert.Equal(http.StatusPreconditionFailed, recorder.Code)
+		assert.Equal(http.StatusOK, serve(server, http.MethodGet, "/books/1", "").Code)
+	})
+
+	test.Run("Should remove the book from the catalogue", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodDelete, "/books/1", "")
+
+		// Assert
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.Equal(http.StatusNotFound, serve(server, http.MethodGet, "/books/1", "").Code)
+	})
+
+	test.Run("Should NOT remove a book which doesn't exist", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodDelete, "/books/1", "")
+
+		// Assert
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestBooksCheckout(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupBooks(test)
+	book := &models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 2}
+	database.Create(book)
+
+	test.Run("Should reserve one copy of the book", func(test *testing.T) {
+		// Arrange
+		successes := testutil.ToFloat64(metrics.Checkouts.WithLabelValues("success"))
+		stockOuts := testutil.ToFloat64(metrics.StockOuts)
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/1/checkout", "")
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"quantity":2,"reserved":1,"available":1`)
+		assert.Contains(recorder.Body.String(), `"version":2`)
+		assert.Equal(`"1-2"`, recorder.Header().Get("ETag"))
+		assert.Equal(successes+1, testutil.ToFloat64(metrics.Checkouts.WithLabelValues("success")))
+		assert.Equal(stockOuts, testutil.ToFloat64(metrics.StockOuts))
+	})
+
+	test.Run("Should record the order of the copy with its price", func(test *testing.T) {
+		// Act
+		order := &models.Order{}
+		database.Preload("Lines").Last(order)
+
+		// Assert
+		assert.Equal(1, order.UserID)
+		assert.Equal(models.OrderPending, order.Status)
+		assert.Equal(money.MustParse("9.99", "GBP"), order.Total)
+		require.Len(test, order.Lines, 1)
+		assert.Equal("Dune", order.Lines[0].Title)
+		assert.Equal(1, order.Lines[0].Quantity)
+	})
+
+	test.Run("Should count the stock out when reserving the last copy", func(test *testing.T) {
+		// Arrange
+		stockOuts := testutil.ToFloat64(metrics.StockOuts)
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/1/checkout", "")
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"available":0`)
+		assert.Equal("/orders/2", recorder.Header().Get("Location"))
+		assert.Equal(stockOuts+1, testutil.ToFloat64(metrics.StockOuts))
+	})
+
+	test.Run("Should NOT take a copy when the book is out of stock", func(test *testing.T) {
+		// Arrange
+		failures := testutil.ToFloat64(metrics.Checkouts.WithLabelValues("out_of_stock"))
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/1/checkout", "")
+
+		// Assert
+		stored := &models.Book{}
+		database.First(stored, book.ID)
+		assert.Equal(http.StatusConflict, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"out_of_stock"`)
+		assert.Equal(0, stored.Available())
+		assert.Equal(failures+1, testutil.ToFloat64(metrics.Checkouts.WithLabelValues("out_of_stock")))
+	})
+
+	test.Run("Should NOT take a copy of a book which doesn't exist", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/7/checkout", "")
+
+		// Assert
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+
+	test.Run("Should NOT take a copy of a book without price in the currency", func(test *testing.T) {
+		// Arrange
+		database.Create(&models.Book{Title: "Emma", Author: "Jane Austen", Prices: gbp("5.50"), Quantity: 1})
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/2/checkout?currency=EUR", "")
+
+		// Assert
+		stored := &models.Book{}
+		database.First(stored, 2)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"rule":"priced"`)
+		assert.Equal(1, stored.Quantity)
+	})
+}
+
+func TestBooksTrash(test *testing.T) {
+	assert := assert.New(test)
+	server, database := setupBooks(test)
+	database.Create(&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 2})
+	database.Create(&models.Book{Title: "Emma", Author: "Jane Austen", Prices: gbp("7.50"), Quantity: 1})
+	serve(server, http.MethodDelete, "/books/2", "")
+
+	test.Run("Should list only the deleted books", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/books?deleted=only", "")
+
+		// Assert
+		var response []BookResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		require.Len(test, response, 1)
+		assert.Equal("Emma", response[0].Title)
+		assert.NotNil(response[0].DeletedAt)
+	})
+
+	test.Run("Should list the deleted books along with the others", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/books?deleted=include", "")
+
+		// Assert
+		var response []BookResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		require.Len(test, response, 2)
+		assert.Nil(response[0].DeletedAt)
+		assert.NotNil(response[1].DeletedAt)
+	})
+
+	test.Run("Should reject an unknown filter of deleted books", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodGet, "/books?deleted=everything", "")
+
+		// Assert
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"field":"deleted"`)
+	})
+
+	test.Run("Should restore a deleted book", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/2/restore", "")
+
+		// Assert
+		var response BookResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Nil(response.DeletedAt)
+		assert.Equal(uint(2), response.Version)
+		assert.Equal(http.StatusOK, serve(server, http.MethodGet, "/books/2", "").Code)
+	})
+
+	test.Run("Should NOT restore a book which doesn't exist", func(test *testing.T) {
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/7/restore", "")
+
+		// Assert
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+
+	test.Run("Should purge a book permanently", func(test *testing.T) {
+		// Arrange
+		serve(server, http.MethodDelete, "/books/1", "")
+
+		// Act
+		recorder := serve(server, http.MethodDelete, "/books/1/purge", "")
 
-	// Check to see if the response was what you expected
-	assert.Equal(http.StatusOK, recorder.Code)
+		// Assert
+		var count int64
+		database.Unscoped().Model(&models.Book{}).Where("id = ?", 1).Count(&count)
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.Zero(count)
+		assert.Equal(http.StatusNotFound, serve(server, http.MethodPost, "/books/1/restore", "").Code)
+	})
 }
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/notifications"
+	"github.com/zatarain/bookshop/payments"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
+)
+
+// The cookie keeping the token of the cart of the anonymous users until they log in
+const CartCookie = "Cart"
+
+// The prices are shown in the original currency of the shop unless the client asks for another one
+const DefaultCurrency = "GBP"
+
+type CartItemInput struct {
+	BookID   uint `json:"book_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,gte=1,lte=99"`
+}
+
+type CartQuantityInput struct {
+	Quantity int `json:"quantity" binding:"required,gte=1,lte=99"`
+}
+
+type CartItemResponse struct {
+	BookID    uint         `json:"book_id"`
+	Title     string       `json:"title"`
+	Author    string       `json:"author"`
+	Quantity  int          `json:"quantity"`
+	Stock     int          `json:"stock"`
+	Price     *money.Money `json:"price,omitempty"`
+	Subtotal  *money.Money `json:"subtotal,omitempty"`
+	Available bool         `json:"available"`
+	Issue     string       `json:"issue,omitempty"`
+}
+
+type CartResponse struct {
+	Currency  string             `json:"currency"`
+	Items     []CartItemResponse `json:"items"`
+	Total     money.Money        `json:"total"`
+	Available bool               `json:"available"`
+}
+
+// CartsController keeps the cart of the authorised users and of the anonymous ones by the token of their cookie, the
+// books of the orders placed at checkout are reserved for the Reservation time while they are paid and the Notifier is
+// told about the books running low
+type CartsController struct {
+	Database    *gorm.DB
+	Payments    payments.PaymentProvider
+	Reservation time.Duration
+	Notifier    notifications.Notifier
+	Lifecycle   *lifecycle.Lifecycle
+}
+
+var ErrCartItemNotFound = problems.ErrNotFound.WithDetail("The book is not in the cart")
+
+func (carts *CartsController) database(context *gin.Context) *gorm.DB {
+	return carts.Database.WithContext(context.Request.Context())
+}
+
+// NewCartItemResponse shows the item with the live price and stock of its book, flagging why it can't be bought
+func NewCartItemResponse(item *models.CartItem, currency string) CartItemResponse {
+	response := CartItemResponse{
+		BookID:   item.BookID,
+		Title:    item.Book.Title,
+		Author:   item.Book.Author,
+		Quantity: item.Quantity,
+		Stock:    item.Book.Available(),
+	}
+
+	price, priced := item.Book.PriceIn(currency)
+	if priced {
+		subtotal, _ := price.Multiply(int64(item.Quantity))
+		response.Price, response.Subtotal = &price, &subtotal
+	}
+
+	switch {
+	case item.Book.ID == 0 || item.Book.DeletedAt.Valid:
+		response.Issue = "unavailable"
+	case !priced:
+		response.Issue = "no_price"
+	case response.Stock == 0:
+		response.Issue = "out_of_stock"
+	case response.Stock < item.Quantity:
+		response.Issue = "insufficient_stock"
+	}
+
+	response.Available = response.Issue == ""
+	return response
+}
+
+// NewCartResponse adds up the subtotals of the items that can be bought
+func NewCartResponse(cart *models.Cart, currency string) CartResponse {
+	response := CartResponse{
+		Currency:  currency,
+		Items:     make([]CartItemResponse, len(cart.Items)),
+		Total:     money.Money{Currency: currency},
+		Available: true,
+	}
+
+	for index := range cart.Items {
+		item := NewCartItemResponse(&cart.Items[index], currency)
+		response.Items[index] = item
+		response.Available = response.Available && item.Available
+		if item.Available {
+			response.Total, _ = response.Total.Add(*item.Subtotal)
+		}
+	}
+
+	return response
+}
+
+// cart gives the cart of the authorised user or the anonymous one of the cookie, which is only created when asked to
+func (carts *CartsController) cart(context *gin.Context, create bool) (*models.Cart, error) {
+	database := carts.database(context)
+	if user := authorised(context); user != nil {
+		return models.CartOf(database, user)
+	}
+
+	if token, exception := context.Cookie(CartCookie); exception == nil {
+		cart, exception := models.AnonymousCart(database, token)
+		if !errors.Is(exception, gorm.ErrRecordNotFound) {
+			return cart, exception
+		}
+	}
+
+	if !create {
+		return nil, nil
+	}
+
+	cart, exception := models.NewCart(database)
+	if exception != nil {
+		return nil, exception
+	}
+
+	context.SetSameSite(http.SameSiteLaxMode)
+	context.SetCookie(CartCookie, cart.Token, 30*24*60*60, "/", "", false, true)
+	return cart, nil
+}
+
+func (carts *CartsController) bookID(context *gin.Context) (uint, bool) {
+	identifier, exception := strconv.ParseUint(context.Param("book_id"), 10, 64)
+	if exception != nil {
+		problems.Abort(context, ErrCartItemNotFound.Wrap(exception))
+		return 0, false
+	}
+
+	return uint(identifier), true
+}
+
+// currency reads the currency of the prices asked by the client
+func currency(context *gin.Context) (string, bool) {
+	currency := context.DefaultQuery("currency", DefaultCurrency)
+	if _, exception := money.Exponent(currency); exception != nil {
+		problem := problems.ErrValidationFailed.Wrap(exception)
+		problem.Errors = []problems.FieldError{{Field: "currency", Rule: "iso4217", Message: "must be a supported ISO 4217 currency"}}
+		problems.Abort(context, problem)
+		return "", false
+	}
+
+	return currency, true
+}
+
+func (carts *CartsController) show(context *gin.Context, cart *models.Cart, currency string) {
+	if exception := cart.WithBooks(carts.database(context)); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(http.StatusOK, NewCartResponse(cart, currency))
+}
+
+func (carts *CartsController) View(context *gin.Context) {
+	currency, ok := currency(context)
+	if !ok {
+		return
+	}
+
+	cart, exception := carts.cart(context, false)
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	// The anonymous users without a cart yet see an empty one
+	if cart == nil {
+		context.JSON(http.StatusOK, NewCartResponse(&models.Cart{}, currency))
+		return
+	}
+
+	carts.show(context, cart, currency)
+}
+
+func (carts *CartsController) Add(context *gin.Context) {
+	currency, ok := currency(context)
+	if !ok {
+		return
+	}
+
+	var input CartItemInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	// Only the books of the catalogue can be added, although they may run out of stock afterwards
+	if exception := carts.database(context).First(&models.Book{}, input.BookID).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrBookNotFound.Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	cart, exception := carts.cart(context, true)
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	if exception := cart.Add(carts.database(context), input.BookID, input.Quantity); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	carts.show(context, cart, currency)
+}
+
+func (carts *CartsController) Update(context *gin.Context) {
+	currency, ok := currency(context)
+	if !ok {
+		return
+	}
+
+	identifier, ok := carts.bookID(context)
+	if !ok {
+		return
+	}
+
+	var input CartQuantityInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	cart, exception := carts.cart(context, false)
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	if cart == nil {
+		problems.Abort(context, ErrCartItemNotFound)
+		return
+	}
+
+	if exception := cart.SetQuantity(carts.database(context), identifier, input.Quantity); errors.Is(exception, models.ErrCartItemNotFound) {
+		problems.Abort(context, ErrCartItemNotFound.Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	carts.show(context, cart, currency)
+}
+
+func (carts *CartsController) Remove(context *gin.Context) {
+	identifier, ok := carts.bookID(context)
+	if !ok {
+		return
+	}
+
+	cart, exception := carts.cart(context, false)
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	if cart == nil {
+		problems.Abort(context, ErrCartItemNotFound)
+		return
+	}
+
+	if exception := cart.Remove(carts.database(context), identifier); errors.Is(exception, models.ErrCartItemNotFound) {
+		problems.Abort(context, ErrCartItemNotFound.Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// Checkout places an order with the books of the cart of the authorised user, preferably from the location of the
+// query, which is emptied, and pays it with the payment method. A declined order is kept pending, so it can be paid
+// again with another method
+func (carts *CartsController) Checkout(context *gin.Context) {
+	currency, ok := currency(context)
+	if !ok {
+		return
+	}
+
+	location, ok := preferredLocation(context, carts.database(context))
+	if !ok {
+		return
+	}
+
+	var input PaymentInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	user := authorised(context)
+	if user == nil {
+		problems.Abort(context, problems.ErrUnauthorised)
+		return
+	}
+
+	cart, exception := models.CartOf(carts.database(context), user)
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	order, exception := cart.Checkout(carts.database(context), user, currency, time.Now().Add(carts.Reservation), location)
+	if exception != nil {
+		problems.Abort(context, orderProblem(exception))
+		return
+	}
+
+	alertLowStock(context, carts.Lifecycle, carts.Notifier, order)
+	context.Header("Location", orderLocation(context, "/cart/checkout", order))
+	pay(context, carts.database(context), carts.Payments, order, input.PaymentMethod, http.StatusCreated)
+}
+
+// Merge moves the anonymous cart of the cookie into the cart of the user who just logged in
+func (carts *CartsController) Merge(context *gin.Context, user *models.User) error {
+	token, exception := context.Cookie(CartCookie)
+	if exception != nil {
+		return nil
+	}
+
+	// The cookie is dropped either way, the cart it points to belongs to the user from now on or doesn't exist
+	context.SetCookie(CartCookie, "", -1, "/", "", false, true)
+	database := carts.database(context)
+	anonymous, exception := models.AnonymousCart(database, token)
+	if errors.Is(exception, gorm.ErrRecordNotFound) {
+		return nil
+	} else if exception != nil {
+		return exception
+	}
+
+	cart, exception := models.CartOf(database, user)
+	if exception != nil {
+		return exception
+	}
+
+	return cart.Merge(database, anonymous)
+}
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/payments"
+	"gorm.io/gorm"
+)
+
+func setupCarts(test *testing.T) (*gin.Engine, *gorm.DB) {
+	server, database := setupServer(test)
+
+	price := func(amount string) []models.BookPrice {
+		return []models.BookPrice{{Price: money.MustParse(amount, "GBP")}}
+	}
+	require.Nil(test, database.Create(&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: price("9.99"), Quantity: 5}).Error)
+	require.Nil(test, database.Create(&models.Book{Title: "Emma", Author: "Jane Austen", Prices: price("5.50"), Quantity: 1}).Error)
+
+	carts := &CartsController{Database: database, Payments: payments.NewFake("dummy-secret", -1, nil, nil)}
+	users := &UsersController{Database: database, SecretTokenKey: "dummy-secret", Carts: carts}
+	server.POST("/signup", users.Signup)
+	server.POST("/login", users.Login)
+	server.GET("/cart", users.Identify, carts.View)
+	server.POST("/cart/items", users.Identify, carts.Add)
+	server.PUT("/cart/items/:book_id", users.Identify, carts.Update)
+	server.DELETE("/cart/items/:book_id", users.Identify, carts.Remove)
+	server.POST("/cart/checkout", users.Authorise, carts.Checkout)
+	return server, database
+}
+
+func cookieOf(recorder *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func cartOf(test *testing.T, body []byte) CartResponse {
+	cart := CartResponse{}
+	require.Nil(test, json.Unmarshal(body, &cart))
+	return cart
+}
+
+func TestCarts(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should show an empty cart to a new visitor", func(test *testing.T) {
+		// Arrange
+		server, _ := setupCarts(test)
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/cart", "")
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.JSONEq(`{"currency": "GBP", "items": [], "total": {"amount": "0.00", "currency": "GBP"}, "available": true}`, recorder.Body.String())
+		assert.Nil(cookieOf(recorder, CartCookie))
+	})
+
+	test.Run("Should keep the cart of an anonymous visitor by its cookie", func(test *testing.T) {
+		// Arrange
+		server, _ := setupCarts(test)
+		first := serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 2}`)
+		cookie := cookieOf(first, CartCookie)
+		require.NotNil(test, cookie)
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/cart/items", `{"book_id": 2, "quantity": 1}`, "Cookie", cookie.String())
+
+		// Assert
+		cart := cartOf(test, recorder.Body.Bytes())
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.True(cookie.HttpOnly)
+		assert.Nil(cookieOf(recorder, CartCookie))
+		require.Len(test, cart.Items, 2)
+		assert.Equal(money.MustParse("19.98", "GBP"), *cart.Items[0].Subtotal)
+		assert.Equal(money.MustParse("25.48", "GBP"), cart.Total)
+		assert.True(cart.Available)
+	})
+
+	test.Run("Should change the quantity and remove the books of the cart", func(test *testing.T) {
+		// Arrange
+		server, _ := setupCarts(test)
+		cookie := cookieOf(serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 1}`), CartCookie).String()
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 2, "quantity": 1}`, "Cookie", cookie)
+
+		// Act
+		update := serve(server, http.MethodPut, "/cart/items/1", `{"quantity": 3}`, "Cookie", cookie)
+		removal := serve(server, http.MethodDelete, "/cart/items/2", "", "Cookie", cookie)
+		recorder := serve(server, http.MethodGet, "/cart", "", "Cookie", cookie)
+
+		// Assert
+		cart := cartOf(test, recorder.Body.Bytes())
+		assert.Equal(http.StatusOK, update.Code)
+		assert.Equal(http.StatusNoContent, removal.Code)
+		require.Len(test, cart.Items, 1)
+		assert.Equal(3, cart.Items[0].Quantity)
+		assert.Equal(money.MustParse("29.97", "GBP"), cart.Total)
+	})
+
+	test.Run("Should flag the items which became unavailable", func(test *testing.T) {
+		// Arrange
+		server, database := setupCarts(test)
+		require.Nil(test, database.Create(&models.Book{Title: "Ulysses", Author: "James Joyce", Quantity: 3}).Error)
+		cookie := cookieOf(serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 2}`), CartCookie).String()
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 2, "quantity": 1}`, "Cookie", cookie)
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 3, "quantity": 1}`, "Cookie", cookie)
+		database.Model(&models.Book{ID: 1}).Update("quantity", 1)
+		database.Delete(&models.Book{}, 2)
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/cart", "", "Cookie", cookie)
+
+		// Assert
+		cart := cartOf(test, recorder.Body.Bytes())
+		require.Len(test, cart.Items, 3)
+		assert.Equal("insufficient_stock", cart.Items[0].Issue)
+		assert.Equal(1, cart.Items[0].Stock)
+		assert.Equal("unavailable", cart.Items[1].Issue)
+		assert.Equal("no_price", cart.Items[2].Issue)
+		assert.False(cart.Available)
+		assert.True(cart.Total.IsZero())
+	})
+
+	test.Run("Should show the prices in the currency asked", func(test *testing.T) {
+		// Arrange
+		server, _ := setupCarts(test)
+		cookie := cookieOf(serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 1}`), CartCookie).String()
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/cart?currency=EUR", "", "Cookie", cookie)
+		unknown := serve(server, http.MethodGet, "/cart?currency=XYZ", "", "Cookie", cookie)
+
+		// Assert
+		cart := cartOf(test, recorder.Body.Bytes())
+		assert.Equal("EUR", cart.Currency)
+		assert.Equal("no_price", cart.Items[0].Issue)
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Contains(unknown.Body.String(), `"field":"currency"`)
+	})
+
+	test.Run("Should NOT add a book out of the catalogue", func(test *testing.T) {
+		// Arrange
+		server, _ := setupCarts(test)
+
+		// Act
+		missing := serve(server, http.MethodPost, "/cart/items", `{"book_id": 99, "quantity": 1}`)
+		invalid := serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 0}`)
+
+		// Assert
+		assert.Equal(http.StatusNotFound, missing.Code)
+		assert.Equal(http.StatusBadRequest, invalid.Code)
+		assert.Nil(cookieOf(missing, CartCookie))
+	})
+
+	test.Run("Should NOT change the books which are not in the cart", func(test *testing.T) {
+		// Arrange
+		server, _ := setupCarts(test)
+
+		// Act
+		update := serve(server, http.MethodPut, "/cart/items/1", `{"quantity": 3}`)
+		removal := serve(server, http.MethodDelete, "/cart/items/1", "")
+
+		// Assert
+		assert.Equal(http.StatusNotFound, update.Code)
+		assert.Equal(http.StatusNotFound, removal.Code)
+	})
+
+	test.Run("Should merge the anonymous cart into the cart of the user on login", func(test *testing.T) {
+		// Arrange
+		server, database := setupCarts(test)
+		credentials := `{"nickname": "dummy-user", "password": "top-secret"}`
+		serve(server, http.MethodPost, "/signup", credentials)
+		session := cookieOf(serve(server, http.MethodPost, "/login", credentials), "Authorisation").String()
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 1}`, "Cookie", session)
+		anonymous := cookieOf(serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 2}`), CartCookie).String()
+
+		// Act
+		login := serve(server, http.MethodPost, "/login", credentials, "Cookie", anonymous)
+		session = cookieOf(login, "Authorisation").String()
+		recorder := serve(server, http.MethodGet, "/cart", "", "Cookie", session)
+
+		// Assert
+		var carts int64
+		database.Model(&models.Cart{}).Count(&carts)
+		cart := cartOf(test, recorder.Body.Bytes())
+		assert.Equal(http.StatusOK, login.Code)
+		assert.Equal(-1, cookieOf(login, CartCookie).MaxAge)
+		require.Len(test, cart.Items, 1)
+		assert.Equal(3, cart.Items[0].Quantity)
+		assert.Equal(int64(1), carts)
+	})
+	test.Run("Should place an order with the books of the cart", func(test *testing.T) {
+		// Arrange
+		server, database := setupCarts(test)
+		credentials := `{"nickname": "dummy-user", "password": "top-secret"}`
+		serve(server, http.MethodPost, "/signup", credentials)
+		session := cookieOf(serve(server, http.MethodPost, "/login", credentials), "Authorisation").String()
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 2}`, "Cookie", session)
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 2, "quantity": 1}`, "Cookie", session)
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/cart/checkout", `{"payment_method": "fake_approve"}`, "Cookie", session)
+		empty := serve(server, http.MethodPost, "/cart/checkout", `{"payment_method": "fake_approve"}`, "Cookie", session)
+
+		// Assert
+		order := OrderResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &order)
+		stock := []int{}
+		database.Model(&models.Book{}).Order("id").Pluck("quantity", &stock)
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("/orders/1", recorder.Header().Get("Location"))
+		assert.Equal(money.MustParse("25.48", "GBP"), order.Total)
+		assert.Equal(models.OrderPaid, order.Status)
+		assert.Len(order.Lines, 2)
+		require.Len(test, order.Payments, 1)
+		assert.Equal(payments.Captured, order.Payments[0].Status)
+		assert.Equal([]int{3, 0}, stock)
+		assert.Equal(http.StatusBadRequest, empty.Code)
+	})
+
+	test.Run("Should keep the order pending when the payment is declined", func(test *testing.T) {
+		// Arrange
+		server, database := setupCarts(test)
+		credentials := `{"nickname": "dummy-user", "password": "top-secret"}`
+		serve(server, http.MethodPost, "/signup", credentials)
+		session := cookieOf(serve(server, http.MethodPost, "/login", credentials), "Authorisation").String()
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 1, "quantity": 1}`, "Cookie", session)
+
+		// Act
+		missing := serve(server, http.MethodPost, "/cart/checkout", "", "Cookie", session)
+		recorder := serve(server, http.MethodPost, "/cart/checkout", `{"payment_method": "fake_decline"}`, "Cookie", session)
+
+		// Assert
+		order := &models.Order{}
+		database.Preload("Payments").First(order)
+		assert.Equal(http.StatusBadRequest, missing.Code)
+		assert.Equal(http.StatusPaymentRequired, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"payment_declined"`)
+		assert.Equal("/orders/1", recorder.Header().Get("Location"))
+		assert.Equal(models.OrderPending, order.Status)
+		require.Len(test, order.Payments, 1)
+		assert.Equal(payments.Declined, order.Payments[0].Status)
+	})
+
+	test.Run("Should keep the cart when the books can't be bought", func(test *testing.T) {
+		// Arrange
+		server, _ := setupCarts(test)
+		credentials := `{"nickname": "dummy-user", "password": "top-secret"}`
+		serve(server, http.MethodPost, "/signup", credentials)
+		session := cookieOf(serve(server, http.MethodPost, "/login", credentials), "Authorisation").String()
+		serve(server, http.MethodPost, "/cart/items", `{"book_id": 2, "quantity": 2}`, "Cookie", session)
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/cart/checkout", `{"payment_method": "fake_approve"}`, "Cookie", session)
+		anonymous := serve(server, http.MethodPost, "/cart/checkout", `{"payment_method": "fake_approve"}`)
+
+		// Assert
+		cart := cartOf(test, serve(server, http.MethodGet, "/cart", "", "Cookie", session).Body.Bytes())
+		assert.Equal(http.StatusConflict, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"out_of_stock"`)
+		assert.Contains(recorder.Body.String(), `Emma`)
+		assert.Len(cart.Items, 1)
+		assert.Equal(http.StatusUnauthorized, anonymous.Code)
+	})
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DocsController struct {
+	Specification []byte
+	Documentation []byte
+}
+
+func (controller *DocsController) Specify(context *gin.Context) {
+	context.Data(http.StatusOK, "application/json", controller.Specification)
+}
+
+func (controller *DocsController) Document(context *gin.Context) {
+	context.Data(http.StatusOK, "text/html; charset=utf-8", controller.Documentation)
+}
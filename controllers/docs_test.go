@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocs(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	docs := &DocsController{
+		Specification: []byte(`{"openapi": "3.1.0"}`),
+		Documentation: []byte("<html></html>"),
+	}
+	server.GET("/openapi.json", docs.Specify)
+	server.GET("/docs", docs.Document)
+
+	testcases := []struct {
+		description string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"Should serve the OpenAPI specification", "/openapi.json", "application/json", `{"openapi": "3.1.0"}`},
+		{"Should serve the documentation page", "/docs", "text/html; charset=utf-8", "<html></html>"},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			request, _ := http.NewRequest(http.MethodGet, testcase.path, nil)
+			recorder := httptest.NewRecorder()
+
+			// Act
+			server.ServeHTTP(recorder, request)
+
+			// Assert
+			assert.Equal(http.StatusOK, recorder.Code)
+			assert.Equal(testcase.contentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(testcase.body, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zatarain/bookshop/models"
+)
+
+// ETag is the strong validator of a book, it changes with its version every time the book is updated
+func ETag(book *models.Book) string {
+	return fmt.Sprintf(`"%d-%d"`, book.ID, book.Version)
+}
+
+// matches tells whether the ETag is listed in an If-Match or If-None-Match header, the weak
+// validators (W/"...") only match with the weak comparison used by If-None-Match
+func matches(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
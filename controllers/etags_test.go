@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/models"
+)
+
+func TestETag(test *testing.T) {
+	assert := assert.New(test)
+	book := &models.Book{ID: 7, Version: 3}
+
+	test.Run("Should derive the ETag from the identifier and the version", func(test *testing.T) {
+		assert.Equal(`"7-3"`, ETag(book))
+	})
+
+	testcases := []struct {
+		description string
+		header      string
+		weak        bool
+		expected    bool
+	}{
+		{"Should match the same ETag", `"7-3"`, false, true},
+		{"Should match any ETag", `*`, false, true},
+		{"Should match an ETag of the list", `"7-2", "7-3"`, false, true},
+		{"Should match a weak ETag with the weak comparison", `W/"7-3"`, true, true},
+		{"Should NOT match a weak ETag with the strong comparison", `W/"7-3"`, false, false},
+		{"Should NOT match another ETag", `"7-2"`, true, false},
+		{"Should NOT match without ETags", ``, true, false},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			assert.Equal(testcase.expected, matches(testcase.header, ETag(book), testcase.weak))
+		})
+	}
+}
@@ -4,8 +4,50 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/health"
 )
 
-func HealthCheck(context *gin.Context) {
+type Drainer interface {
+	Draining() bool
+}
+
+type HealthController struct {
+	Lifecycle Drainer
+	Liveness  *health.Registry
+	Readiness *health.Registry
+}
+
+func (controller *HealthController) draining() bool {
+	return controller.Lifecycle != nil && controller.Lifecycle.Draining()
+}
+
+func (controller *HealthController) Check(context *gin.Context) {
+	if controller.draining() {
+		context.String(http.StatusServiceUnavailable, "Draining, bye!")
+		return
+	}
+
 	context.String(http.StatusOK, "OK, go!")
 }
+
+func respondWithReport(context *gin.Context, report health.Report) {
+	if report.Status != health.StatusUp {
+		context.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	context.JSON(http.StatusOK, report)
+}
+
+func (controller *HealthController) Live(context *gin.Context) {
+	respondWithReport(context, controller.Liveness.Run(context.Request.Context()))
+}
+
+func (controller *HealthController) Ready(context *gin.Context) {
+	report := controller.Readiness.Run(context.Request.Context())
+	if controller.draining() {
+		report.Status = "draining"
+	}
+
+	respondWithReport(context, report)
+}
@@ -1,6 +1,9 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"net/http"
@@ -9,22 +12,148 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/health"
+	"github.com/zatarain/bookshop/lifecycle"
 )
 
 func TestHealth(test *testing.T) {
 	assert := assert.New(test)
 	require := require.New(test)
 	gin.SetMode(gin.TestMode)
-	server := gin.New()
-	server.HEAD("/health", HealthCheck)
-	recorder := httptest.NewRecorder()
 
-	request, exception := http.NewRequest(http.MethodHead, "/health", nil)
-	require.Nil(exception)
+	test.Run("Should report OK while serving", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		health := &HealthController{Lifecycle: lifecycle.New()}
+		server.HEAD("/health", health.Check)
+		recorder := httptest.NewRecorder()
+		request, exception := http.NewRequest(http.MethodHead, "/health", nil)
+		require.Nil(exception)
 
-	// Perform the request
-	server.ServeHTTP(recorder, request)
+		// Act
+		server.ServeHTTP(recorder, request)
 
-	// Check to see if the response was what you expected
-	assert.Equal(http.StatusOK, recorder.Code)
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+	})
+
+	test.Run("Should report service unavailable while draining", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		application := lifecycle.New()
+		health := &HealthController{Lifecycle: application}
+		server.GET("/health", health.Check)
+		recorder := httptest.NewRecorder()
+		request, exception := http.NewRequest(http.MethodGet, "/health", nil)
+		require.Nil(exception)
+		application.Drain()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal("Draining, bye!", recorder.Body.String())
+	})
+}
+
+func TestLiveAndReady(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	healthy := health.CheckerFunc(func(context.Context) (health.Details, error) {
+		return health.Details{"pending": 0}, nil
+	})
+	broken := health.CheckerFunc(func(context.Context) (health.Details, error) {
+		return nil, errors.New("Database file is gone")
+	})
+
+	perform := func(controller *HealthController, path string) (*httptest.ResponseRecorder, health.Report) {
+		server := gin.New()
+		server.GET("/health/live", controller.Live)
+		server.GET("/health/ready", controller.Ready)
+		request, _ := http.NewRequest(http.MethodGet, path, nil)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+
+		var report health.Report
+		json.Unmarshal(recorder.Body.Bytes(), &report)
+		return recorder, report
+	}
+
+	test.Run("Should be alive even when a dependency is broken", func(test *testing.T) {
+		// Arrange
+		readiness := health.NewRegistry()
+		readiness.Register("database", broken)
+		controller := &HealthController{
+			Lifecycle: lifecycle.New(),
+			Liveness:  health.NewRegistry(),
+			Readiness: readiness,
+		}
+
+		// Act
+		recorder, report := perform(controller, "/health/live")
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(health.StatusUp, report.Status)
+		assert.NotEmpty(report.Build.Version)
+		assert.Empty(report.Components)
+	})
+
+	test.Run("Should be ready when every dependency is up", func(test *testing.T) {
+		// Arrange
+		readiness := health.NewRegistry()
+		readiness.Register("migrations", healthy)
+		controller := &HealthController{
+			Lifecycle: lifecycle.New(),
+			Liveness:  health.NewRegistry(),
+			Readiness: readiness,
+		}
+
+		// Act
+		recorder, report := perform(controller, "/health/ready")
+
+		// Assert
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(health.StatusUp, report.Status)
+		assert.Equal(health.StatusUp, report.Components["migrations"].Status)
+	})
+
+	test.Run("Should NOT be ready when a dependency is down", func(test *testing.T) {
+		// Arrange
+		readiness := health.NewRegistry()
+		readiness.Register("migrations", healthy)
+		readiness.Register("database", broken)
+		controller := &HealthController{
+			Lifecycle: lifecycle.New(),
+			Liveness:  health.NewRegistry(),
+			Readiness: readiness,
+		}
+
+		// Act
+		recorder, report := perform(controller, "/health/ready")
+
+		// Assert
+		assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(health.StatusDown, report.Status)
+		assert.Equal("Database file is gone", report.Components["database"].Error)
+	})
+
+	test.Run("Should NOT be ready while draining", func(test *testing.T) {
+		// Arrange
+		application := lifecycle.New()
+		controller := &HealthController{
+			Lifecycle: application,
+			Liveness:  health.NewRegistry(),
+			Readiness: health.NewRegistry(),
+		}
+		application.Drain()
+
+		// Act
+		recorder, report := perform(controller, "/health/ready")
+
+		// Assert
+		assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal("draining", report.Status)
+	})
 }
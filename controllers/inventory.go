@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/metrics"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/notifications"
+	"github.com/zatarain/bookshop/problems"
+)
+
+type LowStockResponse struct {
+	BookID        uint   `json:"book_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	Available     int    `json:"available"`
+	ReorderPoint  int    `json:"reorder_point"`
+	ReorderTarget int    `json:"reorder_target"`
+	Reorder       int    `json:"reorder"`
+}
+
+func NewLowStockResponse(low *models.LowStock) LowStockResponse {
+	return LowStockResponse{
+		BookID:        low.BookID,
+		Title:         low.Title,
+		Author:        low.Author,
+		Available:     low.Available,
+		ReorderPoint:  low.ReorderPoint,
+		ReorderTarget: low.ReorderTarget,
+		Reorder:       low.Reorder(),
+	}
+}
+
+// notify tells the notifier about the event as a background worker of the lifecycle, so the request doesn't wait for
+// it but the shutdown does, and logs when it fails
+func notify(lifecycle *lifecycle.Lifecycle, parent context.Context, notifier notifications.Notifier, event notifications.Event) {
+	background := context.WithoutCancel(parent)
+	lifecycle.Go("notification", func(context.Context) {
+		if exception := notifier.Notify(background, event); exception != nil {
+			logging.FromContext(background).Error("Failed to notify the event", "event", event.Type, "error", exception.Error())
+		}
+	})
+}
+
+// alertLowStock emits an event for every book the order took below its reorder point
+func alertLowStock(context *gin.Context, lifecycle *lifecycle.Lifecycle, notifier notifications.Notifier, order *models.Order) {
+	for index := range order.LowStock {
+		low := NewLowStockResponse(&order.LowStock[index])
+		metrics.LowStockEvents.Inc()
+		if notifier == nil {
+			continue
+		}
+
+		notify(lifecycle, context.Request.Context(), notifier, notifications.Event{
+			Type:      notifications.LowStock,
+			Subject:   fmt.Sprintf("%q is running low, %d copies available", low.Title, low.Available),
+			Data:      low,
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+}
+
+// LowStock reports the books the buyers should reorder, i.e. the ones whose available copies are below their reorder
+// point
+func (books *BooksController) LowStock(context *gin.Context) {
+	report, exception := models.LowStockReport(books.database(context))
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	response := make([]LowStockResponse, len(report))
+	for index := range report {
+		response[index] = NewLowStockResponse(&report[index])
+	}
+
+	context.JSON(http.StatusOK, response)
+}
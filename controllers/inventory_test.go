@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/metrics"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/notifications"
+	"github.com/zatarain/bookshop/payments"
+	"gorm.io/gorm"
+)
+
+func setupInventory(test *testing.T) (*gin.Engine, *gorm.DB, *notifications.Recorder, *lifecycle.Lifecycle) {
+	server, database := setupServer(test)
+
+	buyer := &models.User{Nickname: "buyer", Password: "hash"}
+	require.Nil(test, database.Create(buyer).Error)
+	require.Nil(test, database.Create(&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 4, ReorderPoint: 3, ReorderTarget: 10}).Error)
+	require.Nil(test, database.Create(&models.Book{Title: "Emma", Author: "Jane Austen", Prices: gbp("5.50"), Quantity: 2}).Error)
+
+	recorder := &notifications.Recorder{}
+	lifecycle := lifecycle.New()
+	books := &BooksController{Database: database, Notifier: recorder, Lifecycle: lifecycle}
+	carts := &CartsController{Database: database, Payments: payments.NewFake("dummy-secret", -1, nil, nil), Notifier: recorder, Lifecycle: lifecycle}
+	server.Use(as(buyer))
+	server.PUT("/books/:id", books.Edit)
+	server.POST("/books/:id/checkout", books.Checkout)
+	server.POST("/cart/checkout", carts.Checkout)
+	server.GET("/inventory/low-stock", books.LowStock)
+	return server, database, recorder, lifecycle
+}
+
+func TestLowStock(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should notify once the checkout taking the book below its reorder point", func(test *testing.T) {
+		// Arrange
+		server, _, recorder, lifecycle := setupInventory(test)
+		events := testutil.ToFloat64(metrics.LowStockEvents)
+
+		// Act
+		first := serve(server, http.MethodPost, "/books/1/checkout?currency=GBP", "")
+		second := serve(server, http.MethodPost, "/books/1/checkout?currency=GBP", "")
+		unwatched := serve(server, http.MethodPost, "/books/2/checkout?currency=GBP", "")
+
+		// Assert
+		assert.Equal(http.StatusOK, first.Code)
+		assert.Equal(http.StatusOK, second.Code)
+		assert.Equal(http.StatusOK, unwatched.Code)
+		assert.Equal(events+1, testutil.ToFloat64(metrics.LowStockEvents))
+		lifecycle.Drain()
+		require.Nil(test, lifecycle.Wait(context.Background()))
+		require.Len(test, recorder.Events(), 1)
+		event := recorder.Events()[0]
+		assert.Equal(notifications.LowStock, event.Type)
+		assert.Equal(`"Dune" is running low, 2 copies available`, event.Subject)
+		assert.Equal(LowStockResponse{BookID: 1, Title: "Dune", Author: "Frank Herbert", Available: 2, ReorderPoint: 3, ReorderTarget: 10, Reorder: 8}, event.Data)
+	})
+
+	test.Run("Should notify the books of the cart taken below their reorder point", func(test *testing.T) {
+		// Arrange
+		server, database, recorder, lifecycle := setupInventory(test)
+		cart, exception := models.CartOf(database, &models.User{ID: 1})
+		require.Nil(test, exception)
+		require.Nil(test, cart.Add(database, 1, 2))
+
+		// Act
+		checkout := serve(server, http.MethodPost, "/cart/checkout?currency=GBP", `{"payment_method": "fake_approve"}`)
+
+		// Assert
+		assert.Equal(http.StatusCreated, checkout.Code)
+		lifecycle.Drain()
+		require.Nil(test, lifecycle.Wait(context.Background()))
+		require.Len(test, recorder.Events(), 1)
+		assert.Equal(2, recorder.Events()[0].Data.(LowStockResponse).Available)
+	})
+
+	test.Run("Should report the books to reorder", func(test *testing.T) {
+		// Arrange
+		server, _, _, _ := setupInventory(test)
+		serve(server, http.MethodPut, "/books/2", `{"title": "Emma", "author": "Jane Austen", "prices": [{"amount": "5.50", "currency": "GBP"}], "quantity": 2, "reorder_point": 5, "reorder_target": 12}`)
+		serve(server, http.MethodPost, "/books/1/checkout?currency=GBP", "")
+		serve(server, http.MethodPost, "/books/1/checkout?currency=GBP", "")
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/inventory/low-stock", "")
+
+		// Assert
+		report := []LowStockResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &report)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal([]LowStockResponse{
+			{BookID: 2, Title: "Emma", Author: "Jane Austen", Available: 2, ReorderPoint: 5, ReorderTarget: 12, Reorder: 10},
+			{BookID: 1, Title: "Dune", Author: "Frank Herbert", Available: 2, ReorderPoint: 3, ReorderTarget: 10, Reorder: 8},
+		}, report)
+	})
+
+	test.Run("Should NOT take a reorder target below the reorder point", func(test *testing.T) {
+		// Arrange
+		server, _, _, _ := setupInventory(test)
+
+		// Act
+		recorder := serve(server, http.MethodPut, "/books/1", `{"title": "Dune", "author": "Frank Herbert", "prices": [{"amount": "9.99", "currency": "GBP"}], "quantity": 4, "reorder_point": 5, "reorder_target": 2}`)
+
+		// Assert
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"field":"reorder_target"`)
+		assert.Contains(recorder.Body.String(), "must be greater than or equal to reorder_point")
+	})
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
+)
+
+type LocationInput struct {
+	Name string `json:"name" binding:"required,notblank,max=64"`
+}
+
+type LocationResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type StockLevelResponse struct {
+	LocationID uint `json:"location_id"`
+	Quantity   int  `json:"quantity"`
+	Reserved   int  `json:"reserved"`
+	Available  int  `json:"available"`
+}
+
+type TransferInput struct {
+	FromLocationID uint   `json:"from_location_id" binding:"required"`
+	ToLocationID   uint   `json:"to_location_id" binding:"required,nefield=FromLocationID"`
+	Quantity       int    `json:"quantity" binding:"required,gte=1,lte=99999"`
+	Reason         string `json:"reason" binding:"max=255"`
+}
+
+// LocationsController keeps the shops and warehouses where the copies of the books are, the oldest one is the primary
+// location
+type LocationsController struct {
+	Database *gorm.DB
+}
+
+var ErrLocationNotFound = problems.ErrNotFound.WithDetail("The location was not found")
+
+func NewLocationResponse(location *models.Location) LocationResponse {
+	return LocationResponse{
+		ID:        location.ID,
+		Name:      location.Name,
+		CreatedAt: location.CreatedAt,
+		UpdatedAt: location.UpdatedAt,
+	}
+}
+
+func NewStockLevelResponses(levels []models.StockLevel) []StockLevelResponse {
+	response := make([]StockLevelResponse, len(levels))
+	for index := range levels {
+		response[index] = StockLevelResponse{
+			LocationID: levels[index].LocationID,
+			Quantity:   levels[index].Quantity,
+			Reserved:   levels[index].Reserved,
+			Available:  levels[index].Available(),
+		}
+	}
+
+	return response
+}
+
+// unknownLocation is the problem of a field naming a location which doesn't exist
+func unknownLocation(exception error, fields ...string) error {
+	problem := problems.ErrValidationFailed.WithDetail("The location doesn't exist").Wrap(exception)
+	for _, field := range fields {
+		problem.Errors = append(problem.Errors, problems.FieldError{Field: field, Rule: "exists", Message: "must be a location"})
+	}
+
+	return problem
+}
+
+// preferredLocation reads the location_id of the query, which is zero when there is none
+func preferredLocation(context *gin.Context, database *gorm.DB) (uint, bool) {
+	query := context.Query("location_id")
+	if query == "" {
+		return 0, true
+	}
+
+	identifier, exception := strconv.ParseUint(query, 10, 64)
+	if exception == nil && identifier > 0 {
+		_, exception = models.FindLocation(database, uint(identifier))
+	} else if exception == nil {
+		exception = models.ErrLocationUnavailable
+	}
+
+	if exception != nil {
+		problems.Abort(context, unknownLocation(exception, "location_id"))
+		return 0, false
+	}
+
+	return uint(identifier), true
+}
+
+func (locations *LocationsController) database(context *gin.Context) *gorm.DB {
+	return locations.Database.WithContext(context.Request.Context())
+}
+
+// Index lists the locations, the primary one first
+func (locations *LocationsController) Index(context *gin.Context) {
+	records := []models.Location{}
+	if exception := locations.database(context).Order("id").Find(&records).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	response := make([]LocationResponse, len(records))
+	for index := range records {
+		response[index] = NewLocationResponse(&records[index])
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+func (locations *LocationsController) View(context *gin.Context) {
+	identifier, exception := strconv.ParseUint(context.Param("id"), 10, 64)
+	if exception != nil {
+		problems.Abort(context, ErrLocationNotFound.Wrap(exception))
+		return
+	}
+
+	location := &models.Location{}
+	if exception := locations.database(context).First(location, identifier).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrLocationNotFound.Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(http.StatusOK, NewLocationResponse(location))
+}
+
+func (locations *LocationsController) Add(context *gin.Context) {
+	var input LocationInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	location := &models.Location{Name: strings.TrimSpace(input.Name)}
+	if exception := locations.database(context).Create(location).Error; exception != nil && problems.Duplicated(exception) {
+		problems.Abort(context, problems.ErrConflict.WithDetail("There is already a location with that name").Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Header("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(context.Request.URL.Path, "/"), location.ID))
+	context.JSON(http.StatusCreated, NewLocationResponse(location))
+}
+
+// Transfer moves copies of a book available at a location to another one for the staff and answers the book with its
+// copies at every location
+func (books *BooksController) Transfer(context *gin.Context) {
+	book := books.find(context)
+	if book == nil {
+		return
+	}
+
+	var input TransferInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	unknown := []string{}
+	fields := []struct {
+		name       string
+		identifier uint
+	}{{"from_location_id", input.FromLocationID}, {"to_location_id", input.ToLocationID}}
+	for _, field := range fields {
+		if _, exception := models.FindLocation(books.database(context), field.identifier); errors.Is(exception, models.ErrLocationUnavailable) {
+			unknown = append(unknown, field.name)
+		} else if exception != nil {
+			problems.Abort(context, exception)
+			return
+		}
+	}
+
+	if len(unknown) > 0 {
+		problems.Abort(context, unknownLocation(models.ErrLocationUnavailable, unknown...))
+		return
+	}
+
+	exception := book.Transfer(books.database(context), authorised(context), input.FromLocationID, input.ToLocationID, input.Quantity, input.Reason)
+	if errors.Is(exception, models.ErrLocationUnavailable) {
+		problems.Abort(context, unknownLocation(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, orderProblem(exception))
+		return
+	}
+
+	if books.reload(context, book) {
+		respond(context, http.StatusOK, book)
+	}
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/models"
+	"gorm.io/gorm"
+)
+
+func setupLocations(test *testing.T) (*gin.Engine, *gorm.DB) {
+	server, database := setupServer(test)
+
+	staff := &models.User{Nickname: "staff", Password: "hash"}
+	require.Nil(test, database.Create(staff).Error)
+	require.Nil(test, (&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 3}).Create(database, staff))
+	require.Nil(test, (&models.Book{Title: "Emma", Author: "Jane Austen", Prices: gbp("5.50"), Quantity: 1}).Create(database, staff))
+
+	books := &BooksController{Database: database}
+	locations := &LocationsController{Database: database}
+	server.Use(as(staff))
+	server.GET("/books", books.Index)
+	server.POST("/books/:id/checkout", books.Checkout)
+	server.POST("/books/:id/transfers", books.Transfer)
+	server.GET("/locations", locations.Index)
+	server.POST("/locations", locations.Add)
+	server.GET("/locations/:id", locations.View)
+	return server, database
+}
+
+func bookOf(test *testing.T, body []byte) BookResponse {
+	book := BookResponse{}
+	require.Nil(test, json.Unmarshal(body, &book))
+	return book
+}
+
+func TestLocations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should add the locations and list them after the main shop", func(test *testing.T) {
+		// Arrange
+		server, _ := setupLocations(test)
+
+		// Act
+		added := serve(server, http.MethodPost, "/locations", `{"name": " Warehouse "}`)
+		duplicated := serve(server, http.MethodPost, "/locations", `{"name": "Warehouse"}`)
+		blank := serve(server, http.MethodPost, "/locations", `{"name": " "}`)
+		recorder := serve(server, http.MethodGet, "/locations", "")
+		found := serve(server, http.MethodGet, "/locations/2", "")
+		missing := serve(server, http.MethodGet, "/locations/9", "")
+
+		// Assert
+		list := []LocationResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &list)
+		assert.Equal(http.StatusCreated, added.Code)
+		assert.Equal("/locations/2", added.Header().Get("Location"))
+		assert.Equal(http.StatusConflict, duplicated.Code)
+		assert.Equal(http.StatusBadRequest, blank.Code)
+		require.Len(test, list, 2)
+		assert.Equal("Main shop", list[0].Name)
+		assert.Equal("Warehouse", list[1].Name)
+		assert.Equal(http.StatusOK, found.Code)
+		assert.Equal(http.StatusNotFound, missing.Code)
+	})
+
+	test.Run("Should transfer the copies of a book between the locations", func(test *testing.T) {
+		// Arrange
+		server, _ := setupLocations(test)
+		serve(server, http.MethodPost, "/locations", `{"name": "Branch"}`)
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/books/1/transfers", `{"from_location_id": 1, "to_location_id": 2, "quantity": 2, "reason": "Opening"}`)
+
+		// Assert
+		book := bookOf(test, recorder.Body.Bytes())
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.NotEmpty(recorder.Header().Get("ETag"))
+		assert.Equal(3, book.Quantity)
+		assert.Equal([]StockLevelResponse{
+			{LocationID: 1, Quantity: 1, Available: 1},
+			{LocationID: 2, Quantity: 2, Available: 2},
+		}, book.Locations)
+	})
+
+	test.Run("Should NOT transfer to the same or unknown locations nor more copies than available", func(test *testing.T) {
+		// Arrange
+		server, database := setupLocations(test)
+		serve(server, http.MethodPost, "/locations", `{"name": "Branch"}`)
+
+		// Act
+		same := serve(server, http.MethodPost, "/books/1/transfers", `{"from_location_id": 1, "to_location_id": 1, "quantity": 1}`)
+		unknown := serve(server, http.MethodPost, "/books/1/transfers", `{"from_location_id": 8, "to_location_id": 9, "quantity": 1}`)
+		excess := serve(server, http.MethodPost, "/books/1/transfers", `{"from_location_id": 1, "to_location_id": 2, "quantity": 4}`)
+
+		// Assert
+		levels, _ := models.StockLevels(database, &models.Book{ID: 1})
+		assert.Equal(http.StatusBadRequest, same.Code)
+		assert.Contains(same.Body.String(), `"field":"to_location_id"`)
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Contains(unknown.Body.String(), `"field":"from_location_id"`)
+		assert.Contains(unknown.Body.String(), `"field":"to_location_id"`)
+		assert.Equal(http.StatusConflict, excess.Code)
+		require.Len(test, levels, 1)
+		assert.Equal(3, levels[0].Quantity)
+	})
+
+	test.Run("Should check out from the preferred location falling back to the other ones", func(test *testing.T) {
+		// Arrange
+		server, database := setupLocations(test)
+		serve(server, http.MethodPost, "/locations", `{"name": "Branch"}`)
+		serve(server, http.MethodPost, "/books/2/transfers", `{"from_location_id": 1, "to_location_id": 2, "quantity": 1}`)
+
+		// Act
+		preferred := serve(server, http.MethodPost, "/books/1/checkout?location_id=2", "")
+		unknown := serve(server, http.MethodPost, "/books/1/checkout?location_id=9", "")
+		branch := serve(server, http.MethodPost, "/books/2/checkout?location_id=1", "")
+
+		// Assert
+		reservations := []models.Reservation{}
+		database.Order("id").Find(&reservations)
+		assert.Equal(http.StatusOK, preferred.Code)
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Contains(unknown.Body.String(), `"field":"location_id"`)
+		assert.Equal(http.StatusOK, branch.Code)
+		require.Len(test, reservations, 2)
+		assert.Equal(uint(1), reservations[0].LocationID)
+		assert.Equal(uint(2), reservations[1].LocationID)
+	})
+
+	test.Run("Should list the books available at a location", func(test *testing.T) {
+		// Arrange
+		server, _ := setupLocations(test)
+		serve(server, http.MethodPost, "/locations", `{"name": "Branch"}`)
+		serve(server, http.MethodPost, "/books/2/transfers", `{"from_location_id": 1, "to_location_id": 2, "quantity": 1}`)
+
+		// Act
+		primary := serve(server, http.MethodGet, "/books?location_id=1", "")
+		branch := serve(server, http.MethodGet, "/books?location_id=2", "")
+		invalid := serve(server, http.MethodGet, "/books?location_id=nope", "")
+
+		// Assert
+		list := func(body []byte) []uint {
+			books := []BookResponse{}
+			json.Unmarshal(body, &books)
+			identifiers := []uint{}
+			for _, book := range books {
+				identifiers = append(identifiers, book.ID)
+			}
+
+			return identifiers
+		}
+		assert.Equal([]uint{1}, list(primary.Body.Bytes()))
+		assert.Equal([]uint{2}, list(branch.Body.Bytes()))
+		assert.Equal(http.StatusBadRequest, invalid.Code)
+	})
+}
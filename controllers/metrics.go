@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zatarain/bookshop/problems"
+)
+
+type MetricsController struct {
+	Gatherer prometheus.Gatherer
+	Token    string
+}
+
+func (controller *MetricsController) Expose(context *gin.Context) {
+	// The end-point is public unless a token was configured
+	expected := []byte("Bearer " + controller.Token)
+	given := []byte(context.GetHeader("Authorization"))
+	if controller.Token != "" && subtle.ConstantTimeCompare(expected, given) != 1 {
+		context.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+		problems.Abort(context, problems.ErrUnauthorised.WithDetail("A valid metrics token is required"))
+		return
+	}
+
+	promhttp.HandlerFor(controller.Gatherer, promhttp.HandlerOpts{}).ServeHTTP(context.Writer, context.Request)
+}
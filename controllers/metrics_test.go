@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/middlewares"
+)
+
+func TestExpose(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "dummy_total", Help: "Dummy counter."})
+	registry.MustRegister(counter)
+	counter.Inc()
+
+	testcases := []struct {
+		description   string
+		token         string
+		authorisation string
+		status        int
+	}{
+		{"Should expose the metrics when no token is configured", "", "", http.StatusOK},
+		{"Should expose the metrics with the right token", "scrape-me", "Bearer scrape-me", http.StatusOK},
+		{"Should NOT expose the metrics without token", "scrape-me", "", http.StatusUnauthorized},
+		{"Should NOT expose the metrics with a wrong token", "scrape-me", "Bearer guess", http.StatusUnauthorized},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			server := gin.New()
+			server.Use(middlewares.Problems())
+			exporter := &MetricsController{Gatherer: registry, Token: testcase.token}
+			server.GET("/metrics", exporter.Expose)
+			request, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+			request.Header.Set("Authorization", testcase.authorisation)
+			recorder := httptest.NewRecorder()
+
+			// Act
+			server.ServeHTTP(recorder, request)
+
+			// Assert
+			assert.Equal(testcase.status, recorder.Code)
+			if testcase.status == http.StatusOK {
+				assert.Contains(recorder.Body.String(), "dummy_total 1")
+			} else {
+				assert.NotContains(recorder.Body.String(), "dummy_total")
+				assert.Equal(`Bearer realm="metrics"`, recorder.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/payments"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
+)
+
+type OrderStatusInput struct {
+	Status string `json:"status" binding:"required,oneof=pending paid shipped delivered cancelled"`
+}
+
+type OrderLineResponse struct {
+	BookID    uint        `json:"book_id"`
+	Title     string      `json:"title"`
+	Author    string      `json:"author"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"`
+	Subtotal  money.Money `json:"subtotal"`
+}
+
+type OrderResponse struct {
+	ID            uint                `json:"id"`
+	UserID        int                 `json:"user_id"`
+	Status        string              `json:"status"`
+	Total         money.Money         `json:"total"`
+	Lines         []OrderLineResponse `json:"lines"`
+	Payments      []PaymentResponse   `json:"payments"`
+	ReservedUntil *time.Time          `json:"reserved_until,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// OrdersController shows the users their orders and lets the staff, i.e. the administrators, move them through their
+// lifecycle, the orders are paid and refunded through the payment provider
+type OrdersController struct {
+	Database       *gorm.DB
+	Administrators []string
+	Payments       payments.PaymentProvider
+}
+
+var ErrOrderNotFound = problems.ErrNotFound.WithDetail("The order was not found")
+
+func NewOrderResponse(order *models.Order) OrderResponse {
+	lines := make([]OrderLineResponse, len(order.Lines))
+	for index, line := range order.Lines {
+		lines[index] = OrderLineResponse{
+			BookID:    line.BookID,
+			Title:     line.Title,
+			Author:    line.Author,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Subtotal:  line.Subtotal(),
+		}
+	}
+
+	charges := make([]PaymentResponse, len(order.Payments))
+	for index := range order.Payments {
+		charges[index] = NewPaymentResponse(&order.Payments[index])
+	}
+
+	// The books of a pending order are held until its first reservation expires
+	var reserved *time.Time
+	for index := range order.Reservations {
+		if expiry := order.Reservations[index].ExpiresAt; reserved == nil || expiry.Before(*reserved) {
+			reserved = &expiry
+		}
+	}
+
+	return OrderResponse{
+		ID:            order.ID,
+		UserID:        order.UserID,
+		Status:        order.Status,
+		Total:         order.Total,
+		Lines:         lines,
+		Payments:      charges,
+		ReservedUntil: reserved,
+		CreatedAt:     order.CreatedAt,
+		UpdatedAt:     order.UpdatedAt,
+	}
+}
+
+// orderProblem maps the errors placing or moving an order to the problems answered to the client
+func orderProblem(exception error) error {
+	detail := exception.Error()
+	detail = strings.ToUpper(detail[:1]) + detail[1:]
+	switch {
+	case errors.Is(exception, models.ErrBookUnavailable), errors.Is(exception, models.ErrInsufficientStock):
+		return problems.ErrOutOfStock.WithDetail(detail).Wrap(exception)
+	case errors.Is(exception, models.ErrBookNotPriced):
+		problem := problems.ErrValidationFailed.WithDetail(detail).Wrap(exception)
+		problem.Errors = []problems.FieldError{{Field: "currency", Rule: "priced", Message: "must be a currency the books are priced in"}}
+		return problem
+	case errors.Is(exception, models.ErrEmptyOrder):
+		return problems.ErrValidationFailed.WithDetail("The cart is empty").Wrap(exception)
+	case errors.Is(exception, models.ErrInvalidTransition):
+		return problems.ErrInvalidTransition.WithDetail(detail).Wrap(exception)
+	}
+
+	return exception
+}
+
+// orderLocation gives the path of the order within the version of the API of the given route, e.g. /v1/orders/1
+func orderLocation(context *gin.Context, route string, order *models.Order) string {
+	return fmt.Sprintf("%s/orders/%d", strings.TrimSuffix(context.FullPath(), route), order.ID)
+}
+
+func (orders *OrdersController) database(context *gin.Context) *gorm.DB {
+	return orders.Database.WithContext(context.Request.Context())
+}
+
+func (orders *OrdersController) staff(user *models.User) bool {
+	return user != nil && slices.Contains(orders.Administrators, user.Nickname)
+}
+
+func withDetails(database *gorm.DB) *gorm.DB {
+	byID := func(database *gorm.DB) *gorm.DB {
+		return database.Order("id")
+	}
+
+	return database.Preload("Lines", byID).Preload("Payments", byID).Preload("Reservations")
+}
+
+// find looks for the order of the path, the users only find their own orders unless they are staff
+func (orders *OrdersController) find(context *gin.Context) *models.Order {
+	identifier, exception := strconv.ParseUint(context.Param("id"), 10, 64)
+	if exception != nil {
+		problems.Abort(context, ErrOrderNotFound.Wrap(exception))
+		return nil
+	}
+
+	order := &models.Order{}
+	if exception := withDetails(orders.database(context)).First(order, identifier).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrOrderNotFound.Wrap(exception))
+		return nil
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return nil
+	}
+
+	if user := authorised(context); user == nil || (order.UserID != user.ID && !orders.staff(user)) {
+		problems.Abort(context, ErrOrderNotFound)
+		return nil
+	}
+
+	return order
+}
+
+// list answers the orders of the scope, newest first, optionally filtered by their status
+func (orders *OrdersController) list(context *gin.Context, database *gorm.DB) {
+	if status := context.Query("status"); status != "" {
+		database = database.Where("status = ?", status)
+	}
+
+	records := []models.Order{}
+	if exception := withDetails(database).Order("id DESC").Find(&records).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	response := make([]OrderResponse, len(records))
+	for index := range records {
+		response[index] = NewOrderResponse(&records[index])
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+// Mine lists the orders of the authorised user
+func (orders *OrdersController) Mine(context *gin.Context) {
+	user := authorised(context)
+	if user == nil {
+		problems.Abort(context, problems.ErrUnauthorised)
+		return
+	}
+
+	orders.list(context, orders.database(context).Where("user_id = ?", user.ID))
+}
+
+// Index lists the orders of every user for the staff
+func (orders *OrdersController) Index(context *gin.Context) {
+	orders.list(context, orders.database(context))
+}
+
+func (orders *OrdersController) View(context *gin.Context) {
+	if order := orders.find(context); order != nil {
+		context.JSON(http.StatusOK, NewOrderResponse(order))
+	}
+}
+
+// Pay charges a pending order of the user with the payment method, e.g. after a declined payment at checkout
+func (orders *OrdersController) Pay(context *gin.Context) {
+	order := orders.find(context)
+	if order == nil {
+		return
+	}
+
+	if order.UserID != authorised(context).ID {
+		problems.Abort(context, problems.ErrForbidden.WithDetail("Only the user of the order can pay it"))
+		return
+	}
+
+	var input PaymentInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	pay(context, orders.database(context), orders.Payments, order, input.PaymentMethod, http.StatusOK)
+}
+
+// captured tells whether the order has payments taken from the customer which weren't refunded
+func captured(order *models.Order) bool {
+	return slices.ContainsFunc(order.Payments, func(payment models.Payment) bool {
+		return payment.Status == payments.Captured
+	})
+}
+
+// Cancel lets the users cancel their orders while they are not shipped, putting the copies back in the stock and
+// refunding what they paid
+func (orders *OrdersController) Cancel(context *gin.Context) {
+	orders.move(context, models.OrderCancelled)
+}
+
+// Move changes the status of any order for the staff
+func (orders *OrdersController) Move(context *gin.Context) {
+	var input OrderStatusInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	orders.move(context, input.Status)
+}
+
+func (orders *OrdersController) move(context *gin.Context, status string) {
+	order := orders.find(context)
+	if order == nil {
+		return
+	}
+
+	// The order is cancelled before its payments are refunded, so it is never left paid with the money given back. When
+	// the provider fails to refund them, cancelling the order again retries the refund
+	if status != models.OrderCancelled || order.Status != models.OrderCancelled || !captured(order) {
+		if exception := order.MoveTo(orders.database(context), status); exception != nil {
+			problems.Abort(context, orderProblem(exception))
+			return
+		}
+	}
+
+	if status == models.OrderCancelled {
+		if exception := refund(context, orders.database(context), orders.Payments, order); exception != nil {
+			problems.Abort(context, exception)
+			return
+		}
+	}
+
+	if order = reloadOrder(context, orders.database(context), order); order == nil {
+		return
+	}
+
+	context.JSON(http.StatusOK, NewOrderResponse(order))
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/payments"
+	"gorm.io/gorm"
+)
+
+func setupOrders(test *testing.T) (*gin.Engine, *gorm.DB, *payments.Fake) {
+	server, database := setupServer(test)
+
+	require.Nil(test, database.Create(&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 5}).Error)
+	for _, nickname := range []string{"alice", "bob", "staff"} {
+		require.Nil(test, database.Create(&models.User{Nickname: nickname, Password: "hash"}).Error)
+	}
+
+	// The tests tell who is calling with the X-User header instead of logging in
+	identify := func(context *gin.Context) {
+		user := &models.User{}
+		if database.First(user, "nickname = ?", context.GetHeader("X-User")).Error == nil {
+			context.Set("user", user)
+		}
+	}
+	// The payments pending at the fake gateway wait for the tests to confirm them
+	fake := payments.NewFake("dummy-secret", -1, nil, nil)
+	users := &UsersController{Database: database, Administrators: []string{"staff"}}
+	orders := &OrdersController{Database: database, Administrators: []string{"staff"}, Payments: fake}
+	webhooks := &WebhooksController{Database: database, Payments: fake}
+	server.Use(identify)
+	server.GET("/me/orders", orders.Mine)
+	server.GET("/orders", users.Administer, orders.Index)
+	server.GET("/orders/:id", orders.View)
+	server.POST("/orders/:id/pay", orders.Pay)
+	server.POST("/orders/:id/cancel", orders.Cancel)
+	server.PUT("/orders/:id/status", users.Administer, orders.Move)
+	server.POST("/payments/webhook", webhooks.Receive)
+	fake.Deliver = func(body []byte, signature string) error {
+		if recorder := serve(server, http.MethodPost, "/payments/webhook", string(body), payments.SignatureHeader, signature); recorder.Code != http.StatusNoContent {
+			return fmt.Errorf("the webhook was answered with %d", recorder.Code)
+		}
+
+		return nil
+	}
+
+	return server, database, fake
+}
+
+func place(test *testing.T, database *gorm.DB, nickname string, quantity int) *models.Order {
+	user := &models.User{}
+	require.Nil(test, database.First(user, "nickname = ?", nickname).Error)
+	order, exception := models.PlaceOrder(database, user, "GBP", []models.OrderLine{{BookID: 1, Quantity: quantity}}, time.Now().Add(time.Hour), 0)
+	require.Nil(test, exception)
+	return order
+}
+
+func TestOrders(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should list the orders of the user, newest first", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 1)
+		place(test, database, "bob", 1)
+		place(test, database, "alice", 2)
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/me/orders", "", "X-User", "alice")
+
+		// Assert
+		var response []OrderResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		require.Len(test, response, 2)
+		assert.Equal(uint(3), response[0].ID)
+		assert.NotNil(response[0].ReservedUntil)
+		assert.Equal(money.MustParse("19.98", "GBP"), response[0].Total)
+		assert.Equal(money.MustParse("9.99", "GBP"), response[0].Lines[0].UnitPrice)
+		assert.Equal(uint(1), response[1].ID)
+	})
+
+	test.Run("Should NOT list the orders to an anonymous user", func(test *testing.T) {
+		// Arrange
+		server, _, _ := setupOrders(test)
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/me/orders", "")
+
+		// Assert
+		assert.Equal(http.StatusUnauthorized, recorder.Code)
+	})
+
+	test.Run("Should only show an order to its user and the staff", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 1)
+
+		// Act
+		owner := serve(server, http.MethodGet, "/orders/1", "", "X-User", "alice")
+		other := serve(server, http.MethodGet, "/orders/1", "", "X-User", "bob")
+		staff := serve(server, http.MethodGet, "/orders/1", "", "X-User", "staff")
+
+		// Assert
+		assert.Equal(http.StatusOK, owner.Code)
+		assert.Contains(owner.Body.String(), `"status":"pending"`)
+		assert.Equal(http.StatusNotFound, other.Code)
+		assert.Equal(http.StatusOK, staff.Code)
+	})
+
+	test.Run("Should let the user cancel the order and restock its copies", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 2)
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/orders/1/cancel", "", "X-User", "alice")
+
+		// Assert
+		book := &models.Book{}
+		database.First(book, 1)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"status":"cancelled"`)
+		assert.Equal(5, book.Quantity)
+	})
+
+	test.Run("Should NOT cancel an order shipped", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		order := place(test, database, "alice", 1)
+		require.Nil(test, order.MoveTo(database, models.OrderPaid))
+		require.Nil(test, order.MoveTo(database, models.OrderShipped))
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/orders/1/cancel", "", "X-User", "alice")
+
+		// Assert
+		assert.Equal(http.StatusConflict, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"invalid_transition"`)
+	})
+
+	test.Run("Should let the staff move the orders through the lifecycle", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 1)
+		place(test, database, "bob", 1)
+
+		// Act
+		paid := serve(server, http.MethodPut, "/orders/2/status", `{"status": "paid"}`, "X-User", "staff")
+		unknown := serve(server, http.MethodPut, "/orders/2/status", `{"status": "lost"}`, "X-User", "staff")
+		customer := serve(server, http.MethodPut, "/orders/1/status", `{"status": "paid"}`, "X-User", "alice")
+		recorder := serve(server, http.MethodGet, "/orders?status=paid", "", "X-User", "staff")
+
+		// Assert
+		var response []OrderResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, paid.Code)
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Equal(http.StatusForbidden, customer.Code)
+		require.Len(test, response, 1)
+		assert.Equal(uint(2), response[0].ID)
+	})
+}
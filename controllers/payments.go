@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/payments"
+	"github.com/zatarain/bookshop/problems"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentInput struct {
+	PaymentMethod string `json:"payment_method" binding:"required,max=64"`
+}
+
+type PaymentResponse struct {
+	Provider  string          `json:"provider"`
+	Reference string          `json:"reference"`
+	Status    payments.Status `json:"status"`
+	Amount    money.Money     `json:"amount"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// WebhooksController receives the payments confirmed asynchronously by the payment provider
+type WebhooksController struct {
+	Database *gorm.DB
+	Payments payments.PaymentProvider
+}
+
+var ErrPaymentNotFound = problems.ErrNotFound.WithDetail("The payment was not found")
+
+func NewPaymentResponse(payment *models.Payment) PaymentResponse {
+	return PaymentResponse{
+		Provider:  payment.Provider,
+		Reference: payment.Reference,
+		Status:    payment.Status,
+		Amount:    payment.Amount,
+		CreatedAt: payment.CreatedAt,
+		UpdatedAt: payment.UpdatedAt,
+	}
+}
+
+// Payable answers that the payments are disabled when there is no provider to take them
+func Payable(provider payments.PaymentProvider) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if provider == nil {
+			problems.Abort(context, problems.ErrPaymentsDisabled)
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// charge asks the provider for the total of the pending order and records the payment, the order is paid at once when
+// the provider approves it or later by the webhook when it is still pending
+func charge(context *gin.Context, database *gorm.DB, provider payments.PaymentProvider, order *models.Order, method string) error {
+	if order.Status != models.OrderPending {
+		return problems.ErrInvalidTransition.WithDetail("Only the pending orders can be paid")
+	}
+
+	// Claim the payment with the order locked before reaching the provider, so only one is authorised at once. The claim
+	// has a reference of its own until the provider gives one, as they are unique
+	payment := &models.Payment{
+		OrderID:   order.ID,
+		Provider:  provider.Name(),
+		Reference: fmt.Sprintf("claim-%d", order.ID),
+		Status:    payments.Pending,
+		Amount:    order.Total,
+	}
+	exception := database.Transaction(func(transaction *gorm.DB) error {
+		locked := transaction.Clauses(clause.Locking{Strength: "UPDATE"}).Where("status = ?", models.OrderPending)
+		if exception := locked.First(&models.Order{}, order.ID).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+			return problems.ErrInvalidTransition.WithDetail("Only the pending orders can be paid")
+		} else if exception != nil {
+			return exception
+		}
+
+		waiting := transaction.Where("order_id = ? AND status IN ?", order.ID, []payments.Status{payments.Pending, payments.Authorised})
+		if exception := waiting.First(&models.Payment{}).Error; exception == nil {
+			return problems.ErrConflict.WithDetail("The order has a payment waiting for confirmation")
+		} else if !errors.Is(exception, gorm.ErrRecordNotFound) {
+			return exception
+		}
+
+		return transaction.Create(payment).Error
+	})
+	if exception != nil {
+		return exception
+	}
+
+	authorisation, exception := provider.Authorise(context.Request.Context(), payments.Charge{
+		OrderID: order.ID,
+		Amount:  order.Total,
+		Method:  method,
+	})
+	if exception != nil {
+		// Give the claim up, so the order can be paid again
+		if released := database.Delete(payment).Error; released != nil {
+			return released
+		}
+	}
+
+	if errors.Is(exception, payments.ErrUnknownMethod) {
+		problem := problems.ErrValidationFailed.WithDetail("The payment method is not supported").Wrap(exception)
+		problem.Errors = []problems.FieldError{{Field: "payment_method", Rule: "supported", Message: "must be a method of the payment provider"}}
+		return problem
+	} else if exception != nil {
+		return problems.ErrPaymentFailed.Wrap(exception)
+	}
+
+	payment.Reference = authorisation.Reference
+	payment.Status = authorisation.Status
+	authorised := map[string]any{"reference": payment.Reference, "status": payment.Status}
+	if exception := database.Model(payment).Updates(authorised).Error; exception != nil {
+		return exception
+	}
+
+	switch payment.Status {
+	case payments.Declined:
+		return problems.ErrPaymentDeclined
+	case payments.Authorised:
+		return settle(context, database, provider, order, payment)
+	}
+
+	return nil
+}
+
+// settle captures the authorised payment and moves its order to paid, the payment is refunded when the order was
+// cancelled meanwhile, e.g. because its reservation expired
+func settle(context *gin.Context, database *gorm.DB, provider payments.PaymentProvider, order *models.Order, payment *models.Payment) error {
+	if exception := provider.Capture(context.Request.Context(), payment.Reference, payment.Amount); exception != nil {
+		return problems.ErrPaymentFailed.Wrap(exception)
+	}
+
+	if exception := database.Model(payment).Update("status", payments.Captured).Error; exception != nil {
+		return exception
+	}
+
+	if exception := order.MoveTo(database, models.OrderPaid); errors.Is(exception, models.ErrInvalidTransition) {
+		if refunded := refund(context, database, provider, order); refunded != nil {
+			return refunded
+		}
+
+		return orderProblem(exception)
+	} else if exception != nil {
+		return exception
+	}
+
+	return nil
+}
+
+// refund gives back the payments captured for the order through the provider
+func refund(context *gin.Context, database *gorm.DB, provider payments.PaymentProvider, order *models.Order) error {
+	captured := []models.Payment{}
+	exception := database.Where("order_id = ? AND status = ?", order.ID, payments.Captured).Find(&captured).Error
+	if exception != nil {
+		return exception
+	}
+
+	for index := range captured {
+		payment := &captured[index]
+		if provider == nil {
+			return problems.ErrPaymentsDisabled
+		} else if payment.Provider != provider.Name() {
+			return problems.ErrPaymentFailed.WithDetail("The payment was taken by another provider")
+		}
+
+		if exception := provider.Refund(context.Request.Context(), payment.Reference, payment.Amount); exception != nil {
+			return problems.ErrPaymentFailed.Wrap(exception)
+		}
+
+		if exception := database.Model(payment).Update("status", payments.Refunded).Error; exception != nil {
+			return exception
+		}
+	}
+
+	return nil
+}
+
+// void releases the authorisation of a payment which won't be captured through the provider
+func void(context *gin.Context, database *gorm.DB, provider payments.PaymentProvider, payment *models.Payment) error {
+	if exception := provider.Void(context.Request.Context(), payment.Reference); exception != nil {
+		return problems.ErrPaymentFailed.Wrap(exception)
+	}
+
+	return database.Model(payment).Update("status", payments.Voided).Error
+}
+
+// Receive settles the pending payments with the signed events of the provider, the events of the payments already
+// settled are acknowledged without doing anything, so the provider can deliver them more than once
+func (webhooks *WebhooksController) Receive(context *gin.Context) {
+	body, exception := io.ReadAll(context.Request.Body)
+	if exception != nil {
+		problems.Abort(context, problems.ErrMalformedRequest.Wrap(exception))
+		return
+	}
+
+	event, exception := webhooks.Payments.VerifyWebhook(context.Request.Header, body)
+	if errors.Is(exception, payments.ErrInvalidSignature) {
+		problems.Abort(context, problems.ErrUnauthorised.WithDetail("The signature of the webhook is not valid").Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, problems.ErrMalformedRequest.Wrap(exception))
+		return
+	}
+
+	database := webhooks.Database.WithContext(context.Request.Context())
+	payment := &models.Payment{}
+	exception = database.First(payment, "provider = ? AND reference = ?", webhooks.Payments.Name(), event.Reference).Error
+	if errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrPaymentNotFound.Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	// The payments left authorised are the ones whose void failed, the deliveries of their event try it again
+	redelivered := payment.Status == payments.Authorised && event.Status == payments.Authorised
+	if !redelivered && (payment.Status != payments.Pending || (event.Status != payments.Authorised && event.Status != payments.Declined)) {
+		context.Status(http.StatusNoContent)
+		return
+	}
+
+	// Only one delivery of the event settles the payment, the others find it no longer pending
+	if !redelivered {
+		update := database.Model(payment).Where("status = ?", payments.Pending).Update("status", event.Status)
+		if update.Error != nil {
+			problems.Abort(context, update.Error)
+			return
+		} else if update.RowsAffected == 0 || event.Status == payments.Declined {
+			context.Status(http.StatusNoContent)
+			return
+		}
+	}
+
+	order := &models.Order{}
+	if exception := database.First(order, payment.OrderID).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	// The orders cancelled while their payment was pending are left unpaid, their authorisation is voided so nothing
+	// is held on the account of the customer
+	if order.Status != models.OrderPending {
+		if exception := void(context, database, webhooks.Payments, payment); exception != nil {
+			problems.Abort(context, exception)
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+		return
+	} else if redelivered {
+		context.Status(http.StatusNoContent)
+		return
+	}
+
+	if exception := settle(context, database, webhooks.Payments, order, payment); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// reloadOrder reads again the order with its lines and payments, answering the problem when it can't
+func reloadOrder(context *gin.Context, database *gorm.DB, order *models.Order) *models.Order {
+	reloaded := &models.Order{}
+	if exception := withDetails(database).First(reloaded, order.ID).Error; exception != nil {
+		problems.Abort(context, exception)
+		return nil
+	}
+
+	return reloaded
+}
+
+// pay charges the order and answers it with its payments, as accepted while the provider is still confirming them
+func pay(context *gin.Context, database *gorm.DB, provider payments.PaymentProvider, order *models.Order, method string, status int) {
+	if exception := charge(context, database, provider, order, method); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	if order = reloadOrder(context, database, order); order == nil {
+		return
+	}
+
+	if order.Status == models.OrderPending {
+		status = http.StatusAccepted
+	}
+
+	context.JSON(status, NewOrderResponse(order))
+}
@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/middlewares"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/payments"
+)
+
+// slowProvider takes its time to authorise, so the concurrent payments overlap
+type slowProvider struct {
+	*payments.Fake
+}
+
+func (provider slowProvider) Authorise(context context.Context, charge payments.Charge) (payments.Authorisation, error) {
+	time.Sleep(20 * time.Millisecond)
+	return provider.Fake.Authorise(context, charge)
+}
+
+func TestPayments(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should let the user pay a pending order", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 1)
+		place(test, database, "alice", 1)
+
+		// Act
+		other := serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_approve"}`, "X-User", "bob")
+		unknown := serve(server, http.MethodPost, "/orders/2/pay", `{"payment_method": "cash"}`, "X-User", "alice")
+		recorder := serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_approve"}`, "X-User", "alice")
+		again := serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_approve"}`, "X-User", "alice")
+
+		// Assert
+		order := OrderResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &order)
+		assert.Equal(http.StatusNotFound, other.Code)
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Contains(unknown.Body.String(), `"field":"payment_method"`)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(models.OrderPaid, order.Status)
+		require.Len(test, order.Payments, 1)
+		assert.Equal("fake", order.Payments[0].Provider)
+		assert.Equal(payments.Captured, order.Payments[0].Status)
+		assert.Equal(order.Total, order.Payments[0].Amount)
+		assert.Equal(http.StatusConflict, again.Code)
+		assert.Contains(again.Body.String(), `"code":"invalid_transition"`)
+	})
+
+	test.Run("Should pay the order when the webhook confirms the delayed payment", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		place(test, database, "alice", 1)
+		recorder := serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_delayed"}`, "X-User", "alice")
+		waiting := serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_approve"}`, "X-User", "alice")
+		accepted := OrderResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &accepted)
+		require.Len(test, accepted.Payments, 1)
+
+		// Act
+		exception := fake.Confirm(accepted.Payments[0].Reference)
+
+		// Assert
+		order := &models.Order{}
+		database.Preload("Payments").First(order, 1)
+		assert.Nil(exception)
+		assert.Equal(http.StatusAccepted, recorder.Code)
+		assert.Equal(models.OrderPending, accepted.Status)
+		assert.Equal(payments.Pending, accepted.Payments[0].Status)
+		assert.Equal(http.StatusConflict, waiting.Code)
+		assert.Equal(models.OrderPaid, order.Status)
+		assert.Equal(payments.Captured, order.Payments[0].Status)
+	})
+
+	test.Run("Should authorise only one payment when the order is paid concurrently", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		place(test, database, "alice", 1)
+		orders := &OrdersController{Database: database, Payments: slowProvider{fake}}
+		server.POST("/slow/orders/:id/pay", orders.Pay)
+		codes := make(chan int, 10)
+		var group sync.WaitGroup
+
+		// Act
+		for attempt := 0; attempt < 10; attempt++ {
+			group.Add(1)
+			go func() {
+				defer group.Done()
+				codes <- serve(server, http.MethodPost, "/slow/orders/1/pay", `{"payment_method": "fake_delayed"}`, "X-User", "alice").Code
+			}()
+		}
+		group.Wait()
+		close(codes)
+
+		// Assert
+		counts := map[int]int{}
+		for code := range codes {
+			counts[code]++
+		}
+
+		var authorised int64
+		database.Model(&models.Payment{}).Where("order_id = ?", 1).Count(&authorised)
+		assert.Equal(map[int]int{http.StatusAccepted: 1, http.StatusConflict: 9}, counts)
+		assert.Equal(int64(1), authorised)
+	})
+
+	test.Run("Should authorise the payments of different orders concurrently", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		place(test, database, "alice", 1)
+		place(test, database, "alice", 1)
+		orders := &OrdersController{Database: database, Payments: slowProvider{fake}}
+		server.POST("/slow/orders/:id/pay", orders.Pay)
+		codes := make(chan int, 2)
+		var group sync.WaitGroup
+
+		// Act
+		for _, path := range []string{"/slow/orders/1/pay", "/slow/orders/2/pay"} {
+			group.Add(1)
+			go func(path string) {
+				defer group.Done()
+				codes <- serve(server, http.MethodPost, path, `{"payment_method": "fake_delayed"}`, "X-User", "alice").Code
+			}(path)
+		}
+		group.Wait()
+		close(codes)
+
+		// Assert
+		for code := range codes {
+			assert.Equal(http.StatusAccepted, code)
+		}
+	})
+
+	test.Run("Should let the order be paid again when the provider failed to authorise", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 1)
+		unknown := serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "cash"}`, "X-User", "alice")
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_approve"}`, "X-User", "alice")
+
+		// Assert
+		order := &models.Order{}
+		database.Preload("Payments").First(order, 1)
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(models.OrderPaid, order.Status)
+		require.Len(test, order.Payments, 1)
+		assert.Equal(payments.Captured, order.Payments[0].Status)
+	})
+
+	test.Run("Should keep the order pending when the webhook declines the delayed payment", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		place(test, database, "alice", 1)
+		serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_delayed_decline"}`, "X-User", "alice")
+		payment := &models.Payment{}
+		require.Nil(test, database.First(payment).Error)
+
+		// Act
+		exception := fake.Confirm(payment.Reference)
+
+		// Assert
+		order := &models.Order{}
+		database.Preload("Payments").First(order, 1)
+		assert.Nil(exception)
+		assert.Equal(models.OrderPending, order.Status)
+		assert.Equal(payments.Declined, order.Payments[0].Status)
+	})
+
+	test.Run("Should acknowledge the webhooks delivered more than once", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		place(test, database, "alice", 1)
+		serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_delayed"}`, "X-User", "alice")
+		payment := &models.Payment{}
+		require.Nil(test, database.First(payment).Error)
+		var delivered []byte
+		fake.Deliver = func(body []byte, signature string) error {
+			delivered = body
+			return nil
+		}
+		require.Nil(test, fake.Confirm(payment.Reference))
+		signature := payments.Sign("dummy-secret", time.Now(), delivered)
+
+		// Act
+		first := serve(server, http.MethodPost, "/payments/webhook", string(delivered), payments.SignatureHeader, signature)
+		second := serve(server, http.MethodPost, "/payments/webhook", string(delivered), payments.SignatureHeader, signature)
+
+		// Assert
+		order := &models.Order{}
+		database.First(order, 1)
+		assert.Equal(http.StatusNoContent, first.Code)
+		assert.Equal(http.StatusNoContent, second.Code)
+		assert.Equal(models.OrderPaid, order.Status)
+	})
+
+	test.Run("Should void a delayed payment when the reservation of the order expired", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		place(test, database, "alice", 1)
+		serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_delayed"}`, "X-User", "alice")
+		payment := &models.Payment{}
+		require.Nil(test, database.First(payment).Error)
+		_, exception := models.ReleaseExpiredReservations(database, time.Now().Add(2*time.Hour))
+		require.Nil(test, exception)
+
+		// Act
+		exception = fake.Confirm(payment.Reference)
+
+		// Assert
+		order := &models.Order{}
+		database.Preload("Payments").First(order, 1)
+		assert.Nil(exception)
+		assert.Equal(models.OrderCancelled, order.Status)
+		assert.Equal(payments.Voided, order.Payments[0].Status)
+	})
+
+	test.Run("Should void a delayed payment authorised after the order was cancelled", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		defer monkey.UnpatchAll()
+		place(test, database, "alice", 1)
+		serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_delayed"}`, "X-User", "alice")
+		cancelled := serve(server, http.MethodPost, "/orders/1/cancel", "", "X-User", "alice")
+		payment := &models.Payment{}
+		require.Nil(test, database.First(payment).Error)
+		monkey.PatchInstanceMethod(reflect.TypeOf(fake), "Void", func(*payments.Fake, context.Context, string) error {
+			return errors.New("gateway timeout")
+		})
+		body := `{"id": "event_2", "type": "payment.authorised", "reference": "` + payment.Reference + `", "status": "authorised"}`
+
+		// Act
+		failed := fake.Confirm(payment.Reference)
+		monkey.UnpatchInstanceMethod(reflect.TypeOf(fake), "Void")
+		redelivered := serve(server, http.MethodPost, "/payments/webhook", body, payments.SignatureHeader, payments.Sign("dummy-secret", time.Now(), []byte(body)))
+
+		// Assert
+		order := &models.Order{}
+		database.Preload("Payments").First(order, 1)
+		assert.Equal(http.StatusOK, cancelled.Code)
+		assert.Error(failed)
+		assert.Equal(http.StatusNoContent, redelivered.Code)
+		assert.Equal(models.OrderCancelled, order.Status)
+		assert.Equal(payments.Voided, order.Payments[0].Status)
+	})
+
+	test.Run("Should NOT accept the webhooks without a valid signature", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 1)
+		serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_delayed"}`, "X-User", "alice")
+		payment := &models.Payment{}
+		require.Nil(test, database.First(payment).Error)
+		body := `{"id": "event_2", "type": "payment.authorised", "reference": "` + payment.Reference + `", "status": "authorised"}`
+
+		// Act
+		unsigned := serve(server, http.MethodPost, "/payments/webhook", body)
+		forged := serve(server, http.MethodPost, "/payments/webhook", body, payments.SignatureHeader, payments.Sign("guessed", time.Now(), []byte(body)))
+		expired := serve(server, http.MethodPost, "/payments/webhook", body, payments.SignatureHeader, payments.Sign("dummy-secret", time.Now().Add(-time.Hour), []byte(body)))
+
+		// Assert
+		order := &models.Order{}
+		database.First(order, 1)
+		assert.Equal(http.StatusUnauthorized, unsigned.Code)
+		assert.Equal(http.StatusUnauthorized, forged.Code)
+		assert.Equal(http.StatusUnauthorized, expired.Code)
+		assert.Equal(models.OrderPending, order.Status)
+	})
+
+	test.Run("Should refund the payment when a paid order is cancelled", func(test *testing.T) {
+		// Arrange
+		server, database, _ := setupOrders(test)
+		place(test, database, "alice", 2)
+		serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_approve"}`, "X-User", "alice")
+
+		// Act
+		recorder := serve(server, http.MethodPost, "/orders/1/cancel", "", "X-User", "alice")
+
+		// Assert
+		order := OrderResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &order)
+		book := &models.Book{}
+		database.First(book, 1)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(models.OrderCancelled, order.Status)
+		require.Len(test, order.Payments, 1)
+		assert.Equal(payments.Refunded, order.Payments[0].Status)
+		assert.Equal(5, book.Quantity)
+	})
+	test.Run("Should keep the order cancelled and retry the refund the provider failed", func(test *testing.T) {
+		// Arrange
+		server, database, fake := setupOrders(test)
+		defer monkey.UnpatchAll()
+		place(test, database, "alice", 2)
+		serve(server, http.MethodPost, "/orders/1/pay", `{"payment_method": "fake_approve"}`, "X-User", "alice")
+		monkey.PatchInstanceMethod(reflect.TypeOf(fake), "Refund", func(*payments.Fake, context.Context, string, money.Money) error {
+			return errors.New("gateway timeout")
+		})
+
+		// Act
+		failed := serve(server, http.MethodPost, "/orders/1/cancel", "", "X-User", "alice")
+		cancelled := &models.Order{}
+		database.Preload("Payments").First(cancelled, 1)
+		monkey.UnpatchAll()
+		retried := serve(server, http.MethodPost, "/orders/1/cancel", "", "X-User", "alice")
+		again := serve(server, http.MethodPost, "/orders/1/cancel", "", "X-User", "alice")
+
+		// Assert
+		order := OrderResponse{}
+		json.Unmarshal(retried.Body.Bytes(), &order)
+		book := &models.Book{}
+		database.First(book, 1)
+		assert.Equal(http.StatusBadGateway, failed.Code)
+		assert.Equal(models.OrderCancelled, cancelled.Status)
+		assert.Equal(payments.Captured, cancelled.Payments[0].Status)
+		assert.Equal(http.StatusOK, retried.Code)
+		assert.Equal(models.OrderCancelled, order.Status)
+		require.Len(test, order.Payments, 1)
+		assert.Equal(payments.Refunded, order.Payments[0].Status)
+		assert.Equal(http.StatusConflict, again.Code)
+		assert.Equal(5, book.Quantity)
+	})
+}
+
+func TestPayable(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+
+	testcases := []struct {
+		description string
+		provider    payments.PaymentProvider
+		expected    int
+	}{
+		{"Should take the payments when there is a provider", payments.NewFake("dummy-secret", -1, nil, nil), http.StatusNoContent},
+		{"Should answer that the payments are disabled without a provider", nil, http.StatusServiceUnavailable},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			server := gin.New()
+			server.Use(middlewares.Problems())
+			server.POST("/orders/:id/pay", Payable(testcase.provider), func(context *gin.Context) {
+				context.Status(http.StatusNoContent)
+			})
+			request, _ := http.NewRequest(http.MethodPost, "/orders/1/pay", nil)
+			recorder := httptest.NewRecorder()
+
+			// Act
+			server.ServeHTTP(recorder, request)
+
+			// Assert
+			assert.Equal(testcase.expected, recorder.Code)
+		})
+	}
+}
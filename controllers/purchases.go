@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
+)
+
+type PurchaseOrderLineInput struct {
+	BookID   uint `json:"book_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,gte=1,lte=99999"`
+}
+
+type PurchaseOrderInput struct {
+	SupplierID uint                     `json:"supplier_id" binding:"required"`
+	Lines      []PurchaseOrderLineInput `json:"lines" binding:"required,min=1,dive"`
+}
+
+type ReceiptInput struct {
+	LocationID uint                     `json:"location_id"`
+	Lines      []PurchaseOrderLineInput `json:"lines" binding:"required,min=1,dive"`
+}
+
+type PurchaseOrderLineResponse struct {
+	BookID      uint `json:"book_id"`
+	Quantity    int  `json:"quantity"`
+	Received    int  `json:"received"`
+	Outstanding int  `json:"outstanding"`
+}
+
+type PurchaseOrderResponse struct {
+	ID          uint                        `json:"id"`
+	SupplierID  uint                        `json:"supplier_id"`
+	UserID      *int                        `json:"user_id,omitempty"`
+	Status      string                      `json:"status"`
+	Lines       []PurchaseOrderLineResponse `json:"lines"`
+	Outstanding int                         `json:"outstanding"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+}
+
+type BacklogResponse struct {
+	Supplier    SupplierResponse        `json:"supplier"`
+	Orders      []PurchaseOrderResponse `json:"orders"`
+	Outstanding int                     `json:"outstanding"`
+}
+
+// PurchasesController lets the staff restock the books with purchase orders to the suppliers, whose copies are brought
+// into the stock as they are received
+type PurchasesController struct {
+	Database *gorm.DB
+}
+
+var ErrPurchaseOrderNotFound = problems.ErrNotFound.WithDetail("The purchase order was not found")
+
+func purchaseLines(inputs []PurchaseOrderLineInput) []models.PurchaseOrderLine {
+	lines := make([]models.PurchaseOrderLine, len(inputs))
+	for index, input := range inputs {
+		lines[index] = models.PurchaseOrderLine{BookID: input.BookID, Quantity: input.Quantity}
+	}
+
+	return lines
+}
+
+func NewPurchaseOrderResponse(purchase *models.PurchaseOrder) PurchaseOrderResponse {
+	lines := make([]PurchaseOrderLineResponse, len(purchase.Lines))
+	for index, line := range purchase.Lines {
+		lines[index] = PurchaseOrderLineResponse{
+			BookID:      line.BookID,
+			Quantity:    line.Quantity,
+			Received:    line.Received,
+			Outstanding: line.Outstanding(),
+		}
+	}
+
+	return PurchaseOrderResponse{
+		ID:          purchase.ID,
+		SupplierID:  purchase.SupplierID,
+		UserID:      purchase.UserID,
+		Status:      purchase.Status,
+		Lines:       lines,
+		Outstanding: purchase.Outstanding(),
+		CreatedAt:   purchase.CreatedAt,
+		UpdatedAt:   purchase.UpdatedAt,
+	}
+}
+
+func NewBacklogResponses(backlogs []models.Backlog) []BacklogResponse {
+	response := make([]BacklogResponse, len(backlogs))
+	for index := range backlogs {
+		response[index].Supplier = NewSupplierResponse(&backlogs[index].Supplier)
+		response[index].Orders = make([]PurchaseOrderResponse, len(backlogs[index].Orders))
+		for position := range backlogs[index].Orders {
+			response[index].Orders[position] = NewPurchaseOrderResponse(&backlogs[index].Orders[position])
+			response[index].Outstanding += response[index].Orders[position].Outstanding
+		}
+	}
+
+	return response
+}
+
+// purchaseProblem maps the errors placing or receiving a purchase order to the problems answered to the client
+func purchaseProblem(exception error) error {
+	detail := exception.Error()
+	detail = strings.ToUpper(detail[:1]) + detail[1:]
+	invalid := func(field string, rule string, message string) error {
+		problem := problems.ErrValidationFailed.WithDetail(detail).Wrap(exception)
+		problem.Errors = []problems.FieldError{{Field: field, Rule: rule, Message: message}}
+		return problem
+	}
+
+	switch {
+	case errors.Is(exception, models.ErrSupplierUnavailable):
+		return invalid("supplier_id", "exists", "must be a supplier")
+	case errors.Is(exception, models.ErrBookUnavailable):
+		return invalid("lines", "exists", "must be books of the catalogue")
+	case errors.Is(exception, models.ErrBookNotOrdered):
+		return invalid("lines", "ordered", "must be books of the purchase order")
+	case errors.Is(exception, models.ErrExcessReceipt):
+		return invalid("lines", "outstanding", "must not be more copies than the ones outstanding")
+	case errors.Is(exception, models.ErrLocationUnavailable):
+		return invalid("location_id", "exists", "must be a location")
+	case errors.Is(exception, models.ErrInvalidTransition):
+		return problems.ErrInvalidTransition.WithDetail(detail).Wrap(exception)
+	}
+
+	return exception
+}
+
+func (purchases *PurchasesController) database(context *gin.Context) *gorm.DB {
+	return purchases.Database.WithContext(context.Request.Context())
+}
+
+func withLines(database *gorm.DB) *gorm.DB {
+	return database.Preload("Lines", func(database *gorm.DB) *gorm.DB {
+		return database.Order("id")
+	})
+}
+
+func (purchases *PurchasesController) find(context *gin.Context) *models.PurchaseOrder {
+	identifier, exception := strconv.ParseUint(context.Param("id"), 10, 64)
+	if exception != nil {
+		problems.Abort(context, ErrPurchaseOrderNotFound.Wrap(exception))
+		return nil
+	}
+
+	purchase := &models.PurchaseOrder{}
+	if exception := withLines(purchases.database(context)).First(purchase, identifier).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrPurchaseOrderNotFound.Wrap(exception))
+		return nil
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return nil
+	}
+
+	return purchase
+}
+
+// respond reads the purchase order again and answers it with the given status
+func (purchases *PurchasesController) respond(context *gin.Context, status int, purchase *models.PurchaseOrder) {
+	reloaded := &models.PurchaseOrder{}
+	if exception := withLines(purchases.database(context)).First(reloaded, purchase.ID).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(status, NewPurchaseOrderResponse(reloaded))
+}
+
+// Index lists the purchase orders newest first, optionally filtered by their status or supplier
+func (purchases *PurchasesController) Index(context *gin.Context) {
+	database := purchases.database(context)
+	if status := context.Query("status"); status != "" {
+		database = database.Where("status = ?", status)
+	}
+
+	if supplier := context.Query("supplier_id"); supplier != "" {
+		database = database.Where("supplier_id = ?", supplier)
+	}
+
+	records := []models.PurchaseOrder{}
+	if exception := withLines(database).Order("id DESC").Find(&records).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	response := make([]PurchaseOrderResponse, len(records))
+	for index := range records {
+		response[index] = NewPurchaseOrderResponse(&records[index])
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+func (purchases *PurchasesController) View(context *gin.Context) {
+	if purchase := purchases.find(context); purchase != nil {
+		context.JSON(http.StatusOK, NewPurchaseOrderResponse(purchase))
+	}
+}
+
+// Add drafts a purchase order to a supplier for the staff
+func (purchases *PurchasesController) Add(context *gin.Context) {
+	var input PurchaseOrderInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	purchase, exception := models.PlacePurchaseOrder(purchases.database(context), input.SupplierID, authorised(context), purchaseLines(input.Lines))
+	if exception != nil {
+		problems.Abort(context, purchaseProblem(exception))
+		return
+	}
+
+	context.Header("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(context.Request.URL.Path, "/"), purchase.ID))
+	purchases.respond(context, http.StatusCreated, purchase)
+}
+
+// Send tells a draft was sent to its supplier, so its copies can be received
+func (purchases *PurchasesController) Send(context *gin.Context) {
+	purchase := purchases.find(context)
+	if purchase == nil {
+		return
+	}
+
+	if exception := purchase.Send(purchases.database(context)); exception != nil {
+		problems.Abort(context, purchaseProblem(exception))
+		return
+	}
+
+	purchases.respond(context, http.StatusOK, purchase)
+}
+
+// Receive brings into the stock of a location, the primary one by default, the copies of a delivery of the supplier
+func (purchases *PurchasesController) Receive(context *gin.Context) {
+	purchase := purchases.find(context)
+	if purchase == nil {
+		return
+	}
+
+	var input ReceiptInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	if exception := purchase.Receive(purchases.database(context), authorised(context), input.LocationID, purchaseLines(input.Lines)); exception != nil {
+		problems.Abort(context, purchaseProblem(exception))
+		return
+	}
+
+	purchases.respond(context, http.StatusOK, purchase)
+}
+
+// Outstanding reports the purchase orders still waiting for copies by supplier
+func (purchases *PurchasesController) Outstanding(context *gin.Context) {
+	backlogs, exception := models.OutstandingPurchaseOrders(purchases.database(context))
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(http.StatusOK, NewBacklogResponses(backlogs))
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/models"
+	"gorm.io/gorm"
+)
+
+func setupPurchases(test *testing.T) (*gin.Engine, *gorm.DB) {
+	server, database := setupServer(test)
+
+	buyer := &models.User{Nickname: "buyer", Password: "hash"}
+	require.Nil(test, database.Create(buyer).Error)
+	require.Nil(test, (&models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 1}).Create(database, buyer))
+	require.Nil(test, (&models.Book{Title: "Emma", Author: "Jane Austen", Prices: gbp("5.50"), Quantity: 0}).Create(database, buyer))
+	require.Nil(test, database.Create(&models.Supplier{Name: "Penguin"}).Error)
+	require.Nil(test, database.Create(&models.Supplier{Name: "Faber"}).Error)
+
+	purchases := &PurchasesController{Database: database}
+	server.Use(as(buyer))
+	server.GET("/purchase-orders", purchases.Index)
+	server.POST("/purchase-orders", purchases.Add)
+	server.GET("/purchase-orders/:id", purchases.View)
+	server.POST("/purchase-orders/:id/send", purchases.Send)
+	server.POST("/purchase-orders/:id/receive", purchases.Receive)
+	server.GET("/inventory/outstanding-purchase-orders", purchases.Outstanding)
+	return server, database
+}
+
+func purchaseOf(test *testing.T, body []byte) PurchaseOrderResponse {
+	purchase := PurchaseOrderResponse{}
+	require.Nil(test, json.Unmarshal(body, &purchase))
+	return purchase
+}
+
+func TestPurchaseOrders(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should draft, send and receive a purchase order through the ledger", func(test *testing.T) {
+		// Arrange
+		server, database := setupPurchases(test)
+
+		// Act
+		draft := serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 1, "lines": [{"book_id": 1, "quantity": 5}, {"book_id": 2, "quantity": 3}]}`)
+		sent := serve(server, http.MethodPost, "/purchase-orders/1/send", "")
+		partially := serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"lines": [{"book_id": 1, "quantity": 5}, {"book_id": 2, "quantity": 1}]}`)
+		received := serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"lines": [{"book_id": 2, "quantity": 2}]}`)
+
+		// Assert
+		book := &models.Book{}
+		database.First(book, 2)
+		movements, _ := models.StockHistory(database, book)
+		assert.Equal(http.StatusCreated, draft.Code)
+		assert.Equal("/purchase-orders/1", draft.Header().Get("Location"))
+		assert.Equal(models.PurchaseDraft, purchaseOf(test, draft.Body.Bytes()).Status)
+		assert.Equal(8, purchaseOf(test, draft.Body.Bytes()).Outstanding)
+		assert.Equal(http.StatusOK, sent.Code)
+		assert.Equal(models.PurchaseSent, purchaseOf(test, sent.Body.Bytes()).Status)
+		assert.Equal(http.StatusOK, partially.Code)
+		assert.Equal(models.PurchasePartiallyReceived, purchaseOf(test, partially.Body.Bytes()).Status)
+		assert.Equal(2, purchaseOf(test, partially.Body.Bytes()).Lines[1].Outstanding)
+		assert.Equal(http.StatusOK, received.Code)
+		assert.Equal(models.PurchaseReceived, purchaseOf(test, received.Body.Bytes()).Status)
+		assert.Equal(3, book.Quantity)
+		require.Len(test, movements, 2)
+		assert.Equal("purchase_order:1", movements[1].Reference)
+		assert.Equal("Received from Penguin", movements[1].Reason)
+	})
+
+	test.Run("Should NOT draft purchase orders of unknown suppliers or books", func(test *testing.T) {
+		// Arrange
+		server, _ := setupPurchases(test)
+
+		// Act
+		supplier := serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 9, "lines": [{"book_id": 1, "quantity": 5}]}`)
+		book := serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 1, "lines": [{"book_id": 9, "quantity": 5}]}`)
+		empty := serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 1, "lines": []}`)
+
+		// Assert
+		assert.Equal(http.StatusBadRequest, supplier.Code)
+		assert.Contains(supplier.Body.String(), `"field":"supplier_id"`)
+		assert.Equal(http.StatusBadRequest, book.Code)
+		assert.Contains(book.Body.String(), `"rule":"exists"`)
+		assert.Equal(http.StatusBadRequest, empty.Code)
+	})
+
+	test.Run("Should NOT receive drafts, books not ordered nor more copies than outstanding", func(test *testing.T) {
+		// Arrange
+		server, database := setupPurchases(test)
+		serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 1, "lines": [{"book_id": 1, "quantity": 5}]}`)
+
+		// Act
+		draft := serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"lines": [{"book_id": 1, "quantity": 1}]}`)
+		serve(server, http.MethodPost, "/purchase-orders/1/send", "")
+		again := serve(server, http.MethodPost, "/purchase-orders/1/send", "")
+		unordered := serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"lines": [{"book_id": 2, "quantity": 1}]}`)
+		excess := serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"lines": [{"book_id": 1, "quantity": 6}]}`)
+		missing := serve(server, http.MethodPost, "/purchase-orders/9/receive", `{"lines": [{"book_id": 1, "quantity": 1}]}`)
+
+		// Assert
+		book := &models.Book{}
+		database.First(book, 1)
+		assert.Equal(http.StatusConflict, draft.Code)
+		assert.Contains(draft.Body.String(), "invalid_transition")
+		assert.Equal(http.StatusConflict, again.Code)
+		assert.Equal(http.StatusBadRequest, unordered.Code)
+		assert.Contains(unordered.Body.String(), `"rule":"ordered"`)
+		assert.Equal(http.StatusBadRequest, excess.Code)
+		assert.Contains(excess.Body.String(), `"rule":"outstanding"`)
+		assert.Equal(http.StatusNotFound, missing.Code)
+		assert.Equal(1, book.Quantity)
+	})
+
+	test.Run("Should receive the copies at the location told", func(test *testing.T) {
+		// Arrange
+		server, database := setupPurchases(test)
+		require.Nil(test, database.Create(&models.Location{Name: "Warehouse"}).Error)
+		serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 1, "lines": [{"book_id": 1, "quantity": 5}]}`)
+		serve(server, http.MethodPost, "/purchase-orders/1/send", "")
+
+		// Act
+		unknown := serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"location_id": 9, "lines": [{"book_id": 1, "quantity": 5}]}`)
+		received := serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"location_id": 2, "lines": [{"book_id": 1, "quantity": 5}]}`)
+
+		// Assert
+		levels, _ := models.StockLevels(database, &models.Book{ID: 1})
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Contains(unknown.Body.String(), `"field":"location_id"`)
+		assert.Equal(http.StatusOK, received.Code)
+		assert.Equal(models.PurchaseReceived, purchaseOf(test, received.Body.Bytes()).Status)
+		require.Len(test, levels, 2)
+		assert.Equal(1, levels[0].Quantity)
+		assert.Equal(5, levels[1].Quantity)
+	})
+
+	test.Run("Should list the purchase orders filtered by status and supplier", func(test *testing.T) {
+		// Arrange
+		server, _ := setupPurchases(test)
+		serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 1, "lines": [{"book_id": 1, "quantity": 5}]}`)
+		serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 2, "lines": [{"book_id": 1, "quantity": 2}]}`)
+		serve(server, http.MethodPost, "/purchase-orders/2/send", "")
+
+		// Act
+		all := serve(server, http.MethodGet, "/purchase-orders", "")
+		sent := serve(server, http.MethodGet, "/purchase-orders?status=sent", "")
+		penguin := serve(server, http.MethodGet, "/purchase-orders?supplier_id=1", "")
+		found := serve(server, http.MethodGet, "/purchase-orders/1", "")
+
+		// Assert
+		list := func(body []byte) []uint {
+			purchases := []PurchaseOrderResponse{}
+			json.Unmarshal(body, &purchases)
+			identifiers := []uint{}
+			for _, purchase := range purchases {
+				identifiers = append(identifiers, purchase.ID)
+			}
+
+			return identifiers
+		}
+		assert.Equal([]uint{2, 1}, list(all.Body.Bytes()))
+		assert.Equal([]uint{2}, list(sent.Body.Bytes()))
+		assert.Equal([]uint{1}, list(penguin.Body.Bytes()))
+		assert.Equal(http.StatusOK, found.Code)
+		assert.Equal(uint(1), purchaseOf(test, found.Body.Bytes()).SupplierID)
+	})
+
+	test.Run("Should report the purchase orders outstanding by supplier", func(test *testing.T) {
+		// Arrange
+		server, _ := setupPurchases(test)
+		serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 1, "lines": [{"book_id": 1, "quantity": 5}, {"book_id": 2, "quantity": 2}]}`)
+		serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 2, "lines": [{"book_id": 1, "quantity": 2}]}`)
+		serve(server, http.MethodPost, "/purchase-orders", `{"supplier_id": 2, "lines": [{"book_id": 2, "quantity": 4}]}`)
+		for _, path := range []string{"/purchase-orders/1/send", "/purchase-orders/3/send"} {
+			serve(server, http.MethodPost, path, "")
+		}
+		serve(server, http.MethodPost, "/purchase-orders/1/receive", `{"lines": [{"book_id": 1, "quantity": 4}]}`)
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/inventory/outstanding-purchase-orders", "")
+
+		// Assert
+		report := []BacklogResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &report)
+		assert.Equal(http.StatusOK, recorder.Code)
+		require.Len(test, report, 2)
+		assert.Equal("Faber", report[0].Supplier.Name)
+		assert.Equal(4, report[0].Outstanding)
+		require.Len(test, report[0].Orders, 1)
+		assert.Equal(uint(3), report[0].Orders[0].ID)
+		assert.Equal("Penguin", report[1].Supplier.Name)
+		assert.Equal(3, report[1].Outstanding)
+		assert.Equal(models.PurchasePartiallyReceived, report[1].Orders[0].Status)
+	})
+}
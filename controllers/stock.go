@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+)
+
+type StockMovementInput struct {
+	LocationID uint   `json:"location_id"`
+	Kind       string `json:"kind" binding:"required,oneof=receipt return adjustment damage"`
+	Quantity   int    `json:"quantity" binding:"required,gte=-99999,lte=99999"`
+	Reason     string `json:"reason" binding:"required,notblank,max=255"`
+	Reference  string `json:"reference" binding:"max=64"`
+}
+
+type StockMovementResponse struct {
+	ID         uint      `json:"id"`
+	LocationID uint      `json:"location_id"`
+	Kind       string    `json:"kind"`
+	Quantity   int       `json:"quantity"`
+	Balance    int       `json:"balance"`
+	UserID     *int      `json:"user_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Reference  string    `json:"reference,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type StockHistoryResponse struct {
+	BookID    uint                    `json:"book_id"`
+	Quantity  int                     `json:"quantity"`
+	Reserved  int                     `json:"reserved"`
+	Available int                     `json:"available"`
+	Balance   int                     `json:"balance"`
+	Movements []StockMovementResponse `json:"movements"`
+}
+
+type StockDiscrepancyResponse struct {
+	BookID     uint                       `json:"book_id"`
+	Title      string                     `json:"title"`
+	Quantity   int                        `json:"quantity"`
+	Balance    int                        `json:"balance"`
+	Difference int                        `json:"difference"`
+	Levels     int                        `json:"levels"`
+	Locations  []LevelDiscrepancyResponse `json:"locations"`
+}
+
+type LevelDiscrepancyResponse struct {
+	LocationID uint `json:"location_id"`
+	Quantity   int  `json:"quantity"`
+	Balance    int  `json:"balance"`
+	Difference int  `json:"difference"`
+}
+
+func NewStockMovementResponse(movement *models.StockMovement, balance int) StockMovementResponse {
+	return StockMovementResponse{
+		ID:         movement.ID,
+		LocationID: movement.LocationID,
+		Kind:       movement.Kind,
+		Quantity:   movement.Quantity,
+		Balance:    balance,
+		UserID:     movement.UserID,
+		Reason:     movement.Reason,
+		Reference:  movement.Reference,
+		CreatedAt:  movement.CreatedAt,
+	}
+}
+
+// NewStockHistoryResponse shows the movements of the book newest first, each one with the balance of the ledger after it
+func NewStockHistoryResponse(book *models.Book, movements []models.StockMovement) StockHistoryResponse {
+	response := StockHistoryResponse{
+		BookID:    book.ID,
+		Quantity:  book.Quantity,
+		Reserved:  book.Reserved,
+		Available: book.Available(),
+		Movements: make([]StockMovementResponse, len(movements)),
+	}
+
+	for index := range movements {
+		response.Balance += movements[index].Quantity
+		response.Movements[len(movements)-1-index] = NewStockMovementResponse(&movements[index], response.Balance)
+	}
+
+	return response
+}
+
+func NewStockDiscrepancyResponses(discrepancies []models.StockDiscrepancy) []StockDiscrepancyResponse {
+	response := make([]StockDiscrepancyResponse, len(discrepancies))
+	for index, discrepancy := range discrepancies {
+		response[index] = StockDiscrepancyResponse{
+			BookID:     discrepancy.BookID,
+			Title:      discrepancy.Title,
+			Quantity:   discrepancy.Quantity,
+			Balance:    discrepancy.Balance,
+			Difference: discrepancy.Quantity - discrepancy.Balance,
+			Levels:     discrepancy.Levels,
+			Locations:  make([]LevelDiscrepancyResponse, len(discrepancy.Locations)),
+		}
+		for position, location := range discrepancy.Locations {
+			response[index].Locations[position] = LevelDiscrepancyResponse{
+				LocationID: location.LocationID,
+				Quantity:   location.Quantity,
+				Balance:    location.Balance,
+				Difference: location.Quantity - location.Balance,
+			}
+		}
+	}
+
+	return response
+}
+
+// History shows the ledger of the copies of a book, even a deleted one, for the staff
+func (books *BooksController) History(context *gin.Context) {
+	book := books.findIn(context, books.database(context).Unscoped())
+	if book == nil {
+		return
+	}
+
+	movements, exception := models.StockHistory(books.database(context), book)
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(http.StatusOK, NewStockHistoryResponse(book, movements))
+}
+
+// Record changes the copies of a book at a location, the primary one by default, with a movement made by the staff, e.g.
+// a receipt from a supplier or the copies damaged, answering the movement with the balance of the ledger after it
+func (books *BooksController) Record(context *gin.Context) {
+	book := books.find(context)
+	if book == nil {
+		return
+	}
+
+	var input StockMovementInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	movement := &models.StockMovement{
+		LocationID: input.LocationID,
+		Kind:       input.Kind,
+		Quantity:   input.Quantity,
+		Reason:     input.Reason,
+		Reference:  input.Reference,
+	}
+	if user := authorised(context); user != nil {
+		movement.UserID = &user.ID
+	}
+	if exception := book.Record(books.database(context), movement); errors.Is(exception, models.ErrInvalidMovement) {
+		problem := problems.ErrValidationFailed.WithDetail("The quantity doesn't fit the kind of the movement").Wrap(exception)
+		problem.Errors = []problems.FieldError{{Field: "quantity", Rule: "sign", Message: "must be positive for receipts and returns and negative for damages"}}
+		problems.Abort(context, problem)
+		return
+	} else if errors.Is(exception, models.ErrLocationUnavailable) {
+		problems.Abort(context, unknownLocation(exception, "location_id"))
+		return
+	} else if exception != nil {
+		problems.Abort(context, orderProblem(exception))
+		return
+	}
+
+	movements, exception := models.StockHistory(books.database(context), book)
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Header("Location", context.Request.URL.Path)
+	for _, recorded := range NewStockHistoryResponse(book, movements).Movements {
+		if recorded.ID == movement.ID {
+			context.JSON(http.StatusCreated, recorded)
+			return
+		}
+	}
+}
+
+// Audit lists the books whose quantity or copies at a location don't match the balance of their ledger
+func (books *BooksController) Audit(context *gin.Context) {
+	discrepancies, exception := models.StockDiscrepancies(books.database(context))
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(http.StatusOK, NewStockDiscrepancyResponses(discrepancies))
+}
+
+// Reconcile sets the quantity and the copies at each location of the books listed by the audit to the balance of their
+// ledger and lists them
+func (books *BooksController) Reconcile(context *gin.Context) {
+	discrepancies, exception := models.Reconcile(books.database(context))
+	if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(http.StatusOK, NewStockDiscrepancyResponses(discrepancies))
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/models"
+	"gorm.io/gorm"
+)
+
+func setupStock(test *testing.T) (*gin.Engine, *gorm.DB) {
+	server, database := setupServer(test)
+
+	staff := &models.User{Nickname: "staff", Password: "hash"}
+	require.Nil(test, database.Create(staff).Error)
+	book := &models.Book{Title: "Dune", Author: "Frank Herbert", Prices: gbp("9.99"), Quantity: 5}
+	require.Nil(test, book.Create(database, staff))
+
+	books := &BooksController{Database: database}
+	server.Use(as(staff))
+	server.PUT("/books/:id", books.Edit)
+	server.GET("/books/:id/stock", books.History)
+	server.POST("/books/:id/stock", books.Record)
+	server.GET("/stock/audit", books.Audit)
+	server.POST("/stock/reconcile", books.Reconcile)
+	return server, database
+}
+
+func TestStock(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should show every movement of the book newest first with the balance after it", func(test *testing.T) {
+		// Arrange
+		server, database := setupStock(test)
+		serve(server, http.MethodPost, "/books/1/stock", `{"kind": "damage", "quantity": -1, "reason": "Torn cover"}`)
+		serve(server, http.MethodPut, "/books/1", `{"title": "Dune", "author": "Frank Herbert", "prices": [{"amount": "9.99", "currency": "GBP"}], "quantity": 7}`)
+		order, exception := models.PlaceOrder(database, &models.User{ID: 1}, "GBP", []models.OrderLine{{BookID: 1, Quantity: 2}}, time.Now().Add(time.Hour), 0)
+		require.Nil(test, exception)
+		require.Nil(test, order.MoveTo(database, models.OrderPaid))
+
+		// Act
+		recorder := serve(server, http.MethodGet, "/books/1/stock", "")
+
+		// Assert
+		history := StockHistoryResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &history)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(5, history.Quantity)
+		assert.Equal(5, history.Balance)
+		require.Len(test, history.Movements, 4)
+		assert.Equal("sale", history.Movements[0].Kind)
+		assert.Equal(-2, history.Movements[0].Quantity)
+		assert.Equal("order:1", history.Movements[0].Reference)
+		assert.Equal("adjustment", history.Movements[1].Kind)
+		assert.Equal(3, history.Movements[1].Quantity)
+		assert.Equal(7, history.Movements[1].Balance)
+		assert.Equal("Torn cover", history.Movements[2].Reason)
+		assert.Equal("receipt", history.Movements[3].Kind)
+		assert.Equal(5, history.Movements[3].Balance)
+		assert.Equal(1, *history.Movements[3].UserID)
+	})
+
+	test.Run("Should record the movements which fit their kind and the stock", func(test *testing.T) {
+		// Arrange
+		server, _ := setupStock(test)
+
+		// Act
+		receipt := serve(server, http.MethodPost, "/books/1/stock", `{"kind": "receipt", "quantity": 10, "reason": "Delivery", "reference": "invoice:42"}`)
+		negative := serve(server, http.MethodPost, "/books/1/stock", `{"kind": "receipt", "quantity": -1, "reason": "Delivery"}`)
+		sale := serve(server, http.MethodPost, "/books/1/stock", `{"kind": "sale", "quantity": -1, "reason": "Sold at the till"}`)
+		excess := serve(server, http.MethodPost, "/books/1/stock", `{"kind": "damage", "quantity": -16, "reason": "Flood"}`)
+
+		// Assert
+		movement := StockMovementResponse{}
+		json.Unmarshal(receipt.Body.Bytes(), &movement)
+		assert.Equal(http.StatusCreated, receipt.Code)
+		assert.Equal("/books/1/stock", receipt.Header().Get("Location"))
+		assert.Equal(15, movement.Balance)
+		assert.Equal("invoice:42", movement.Reference)
+		assert.Equal(http.StatusBadRequest, negative.Code)
+		assert.Contains(negative.Body.String(), `"rule":"sign"`)
+		assert.Equal(http.StatusBadRequest, sale.Code)
+		assert.Equal(http.StatusConflict, excess.Code)
+		assert.Contains(excess.Body.String(), `"code":"out_of_stock"`)
+	})
+
+	test.Run("Should record the movements at the location told", func(test *testing.T) {
+		// Arrange
+		server, database := setupStock(test)
+		require.Nil(test, database.Create(&models.Location{Name: "Warehouse"}).Error)
+
+		// Act
+		receipt := serve(server, http.MethodPost, "/books/1/stock", `{"location_id": 2, "kind": "receipt", "quantity": 4, "reason": "Delivery"}`)
+		damage := serve(server, http.MethodPost, "/books/1/stock", `{"location_id": 2, "kind": "damage", "quantity": -5, "reason": "Flood"}`)
+		unknown := serve(server, http.MethodPost, "/books/1/stock", `{"location_id": 9, "kind": "receipt", "quantity": 1, "reason": "Delivery"}`)
+
+		// Assert
+		movement := StockMovementResponse{}
+		json.Unmarshal(receipt.Body.Bytes(), &movement)
+		levels, _ := models.StockLevels(database, &models.Book{ID: 1})
+		assert.Equal(http.StatusCreated, receipt.Code)
+		assert.Equal(uint(2), movement.LocationID)
+		assert.Equal(9, movement.Balance)
+		assert.Equal(http.StatusConflict, damage.Code)
+		assert.Equal(http.StatusBadRequest, unknown.Code)
+		assert.Contains(unknown.Body.String(), `"field":"location_id"`)
+		require.Len(test, levels, 2)
+		assert.Equal(5, levels[0].Quantity)
+		assert.Equal(4, levels[1].Quantity)
+	})
+
+	test.Run("Should report and reconcile the books whose quantity doesn't match their ledger", func(test *testing.T) {
+		// Arrange
+		server, database := setupStock(test)
+		database.Model(&models.Book{}).Where("id = 1").Update("quantity", 3)
+
+		// Act
+		audit := serve(server, http.MethodGet, "/stock/audit", "")
+		reconcile := serve(server, http.MethodPost, "/stock/reconcile", "")
+		after := serve(server, http.MethodGet, "/stock/audit", "")
+
+		// Assert
+		var discrepancies []StockDiscrepancyResponse
+		json.Unmarshal(audit.Body.Bytes(), &discrepancies)
+		book := &models.Book{}
+		database.First(book, 1)
+		assert.Equal(http.StatusOK, audit.Code)
+		require.Len(test, discrepancies, 1)
+		assert.Equal(StockDiscrepancyResponse{BookID: 1, Title: "Dune", Quantity: 3, Balance: 5, Difference: -2, Levels: 5, Locations: []LevelDiscrepancyResponse{}}, discrepancies[0])
+		assert.Equal(http.StatusOK, reconcile.Code)
+		assert.Equal(5, book.Quantity)
+		assert.JSONEq(`[]`, after.Body.String())
+	})
+
+	test.Run("Should report and reconcile the copies of a location drifting from its ledger", func(test *testing.T) {
+		// Arrange
+		server, database := setupStock(test)
+		database.Model(&models.StockLevel{}).Where("book_id = 1").Update("quantity", 7)
+
+		// Act
+		audit := serve(server, http.MethodGet, "/stock/audit", "")
+		reconcile := serve(server, http.MethodPost, "/stock/reconcile", "")
+		after := serve(server, http.MethodGet, "/stock/audit", "")
+
+		// Assert
+		var discrepancies []StockDiscrepancyResponse
+		json.Unmarshal(audit.Body.Bytes(), &discrepancies)
+		levels, _ := models.StockLevels(database, &models.Book{ID: 1})
+		assert.Equal(http.StatusOK, audit.Code)
+		require.Len(test, discrepancies, 1)
+		assert.Equal(7, discrepancies[0].Levels)
+		assert.Zero(discrepancies[0].Difference)
+		assert.Equal([]LevelDiscrepancyResponse{{LocationID: 1, Quantity: 7, Balance: 5, Difference: 2}}, discrepancies[0].Locations)
+		assert.Equal(http.StatusOK, reconcile.Code)
+		require.Len(test, levels, 1)
+		assert.Equal(5, levels[0].Quantity)
+		assert.JSONEq(`[]`, after.Body.String())
+	})
+}
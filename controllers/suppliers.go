@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
+)
+
+type SupplierInput struct {
+	Name  string `json:"name" binding:"required,notblank,max=64"`
+	Email string `json:"email" binding:"omitempty,email,max=255"`
+}
+
+type SupplierResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SuppliersController keeps the suppliers the staff buys the copies of the books from
+type SuppliersController struct {
+	Database *gorm.DB
+}
+
+var ErrSupplierNotFound = problems.ErrNotFound.WithDetail("The supplier was not found")
+
+func NewSupplierResponse(supplier *models.Supplier) SupplierResponse {
+	return SupplierResponse{
+		ID:        supplier.ID,
+		Name:      supplier.Name,
+		Email:     supplier.Email,
+		CreatedAt: supplier.CreatedAt,
+		UpdatedAt: supplier.UpdatedAt,
+	}
+}
+
+func (suppliers *SuppliersController) database(context *gin.Context) *gorm.DB {
+	return suppliers.Database.WithContext(context.Request.Context())
+}
+
+// Index lists the suppliers by name
+func (suppliers *SuppliersController) Index(context *gin.Context) {
+	records := []models.Supplier{}
+	if exception := suppliers.database(context).Order("name").Find(&records).Error; exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	response := make([]SupplierResponse, len(records))
+	for index := range records {
+		response[index] = NewSupplierResponse(&records[index])
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+func (suppliers *SuppliersController) View(context *gin.Context) {
+	identifier, exception := strconv.ParseUint(context.Param("id"), 10, 64)
+	if exception != nil {
+		problems.Abort(context, ErrSupplierNotFound.Wrap(exception))
+		return
+	}
+
+	supplier := &models.Supplier{}
+	if exception := suppliers.database(context).First(supplier, identifier).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+		problems.Abort(context, ErrSupplierNotFound.Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.JSON(http.StatusOK, NewSupplierResponse(supplier))
+}
+
+func (suppliers *SuppliersController) Add(context *gin.Context) {
+	var input SupplierInput
+	if exception := validation.BindJSON(context.Request.Body, &input); exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	supplier := &models.Supplier{Name: strings.TrimSpace(input.Name), Email: input.Email}
+	if exception := suppliers.database(context).Create(supplier).Error; exception != nil && problems.Duplicated(exception) {
+		problems.Abort(context, problems.ErrConflict.WithDetail("There is already a supplier with that name").Wrap(exception))
+		return
+	} else if exception != nil {
+		problems.Abort(context, exception)
+		return
+	}
+
+	context.Header("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(context.Request.URL.Path, "/"), supplier.ID))
+	context.JSON(http.StatusCreated, NewSupplierResponse(supplier))
+}
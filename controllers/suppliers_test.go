@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupSuppliers(test *testing.T) (*gin.Engine, *gorm.DB) {
+	server, database := setupServer(test)
+
+	suppliers := &SuppliersController{Database: database}
+	server.GET("/suppliers", suppliers.Index)
+	server.POST("/suppliers", suppliers.Add)
+	server.GET("/suppliers/:id", suppliers.View)
+	return server, database
+}
+
+func TestSuppliers(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should add the suppliers and list them by name", func(test *testing.T) {
+		// Arrange
+		server, _ := setupSuppliers(test)
+
+		// Act
+		penguin := serve(server, http.MethodPost, "/suppliers", `{"name": " Penguin ", "email": "orders@penguin.example"}`)
+		faber := serve(server, http.MethodPost, "/suppliers", `{"name": "Faber"}`)
+		recorder := serve(server, http.MethodGet, "/suppliers", "")
+
+		// Assert
+		list := []SupplierResponse{}
+		json.Unmarshal(recorder.Body.Bytes(), &list)
+		assert.Equal(http.StatusCreated, penguin.Code)
+		assert.Equal("/suppliers/1", penguin.Header().Get("Location"))
+		assert.Equal(http.StatusCreated, faber.Code)
+		assert.Equal(http.StatusOK, recorder.Code)
+		require.Len(test, list, 2)
+		assert.Equal("Faber", list[0].Name)
+		assert.Equal("Penguin", list[1].Name)
+		assert.Equal("orders@penguin.example", list[1].Email)
+	})
+
+	test.Run("Should view a supplier", func(test *testing.T) {
+		// Arrange
+		server, _ := setupSuppliers(test)
+		serve(server, http.MethodPost, "/suppliers", `{"name": "Penguin"}`)
+
+		// Act
+		found := serve(server, http.MethodGet, "/suppliers/1", "")
+		missing := serve(server, http.MethodGet, "/suppliers/9", "")
+
+		// Assert
+		supplier := SupplierResponse{}
+		json.Unmarshal(found.Body.Bytes(), &supplier)
+		assert.Equal(http.StatusOK, found.Code)
+		assert.Equal("Penguin", supplier.Name)
+		assert.Equal(http.StatusNotFound, missing.Code)
+	})
+
+	test.Run("Should NOT add a supplier twice nor an invalid one", func(test *testing.T) {
+		// Arrange
+		server, _ := setupSuppliers(test)
+		serve(server, http.MethodPost, "/suppliers", `{"name": "Penguin"}`)
+
+		// Act
+		duplicated := serve(server, http.MethodPost, "/suppliers", `{"name": "Penguin"}`)
+		invalid := serve(server, http.MethodPost, "/suppliers", `{"name": " ", "email": "nope"}`)
+
+		// Assert
+		assert.Equal(http.StatusConflict, duplicated.Code)
+		assert.Equal(http.StatusBadRequest, invalid.Code)
+		assert.Contains(invalid.Body.String(), `"field":"email"`)
+	})
+}
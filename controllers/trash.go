@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/zatarain/bookshop/problems"
+	"gorm.io/gorm"
+)
+
+func deletedAt(deleted gorm.DeletedAt) *time.Time {
+	if !deleted.Valid {
+		return nil
+	}
+
+	return &deleted.Time
+}
+
+func deletedFilterProblem(exception error) *problems.Problem {
+	problem := problems.ErrValidationFailed.Wrap(exception)
+	problem.Errors = []problems.FieldError{{Field: "deleted", Rule: "oneof", Message: "must be one of include only"}}
+	return problem
+}
@@ -1,25 +1,48 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/metrics"
 	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/tracing"
+	"github.com/zatarain/bookshop/validation"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Registration carries the rules of the new accounts, which the existing ones may predate
+type Registration struct {
+	Nickname string `json:"nickname" binding:"required,notblank,max=30"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
 type Credentials struct {
-	Nickname string
-	Password string
+	Nickname string `json:"nickname" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type UserResponse struct {
+	ID        int        `json:"id"`
+	Nickname  string     `json:"nickname"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type UsersController struct {
 	Database       models.DataAccessInterface
 	SecretTokenKey string
+	Administrators []string
+	Carts          *CartsController
 }
 
 type TokenMaker interface {
@@ -30,21 +53,38 @@ type Authoriser interface {
 	ValidateToken(*gin.Context) (*models.User, error)
 }
 
-func (credentials *Credentials) HashPassword() error {
-	hash, exception := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
-	credentials.Password = string(hash)
+func (registration *Registration) HashPassword(context context.Context) error {
+	_, span := tracing.Tracer().Start(context, "bcrypt.GenerateFromPassword")
+	defer span.End()
+
+	hash, exception := bcrypt.GenerateFromPassword([]byte(registration.Password), bcrypt.DefaultCost)
+	registration.Password = string(hash)
 	return exception
 }
 
+func (registration *Registration) User() models.User {
+	return models.User{
+		Nickname: registration.Nickname,
+		Password: registration.Password,
+	}
+}
+
+func NewUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Nickname:  user.Nickname,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		DeletedAt: deletedAt(user.DeletedAt),
+	}
+}
+
 func getCredentialsFromRequest(context *gin.Context) *Credentials {
 	var credentials Credentials
 
 	// Trying to bind input from JSON
-	if binding := context.BindJSON(&credentials); binding != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"summary": "Failed to read input",
-			"details": binding.Error(),
-		})
+	if binding := validation.BindJSON(context.Request.Body, &credentials); binding != nil {
+		problems.Abort(context, binding)
 		return nil
 	}
 
@@ -52,37 +92,37 @@ func getCredentialsFromRequest(context *gin.Context) *Credentials {
 }
 
 func (users *UsersController) Signup(context *gin.Context) {
-	credentials := getCredentialsFromRequest(context)
-	if credentials == nil {
+	var registration Registration
+	if binding := validation.BindJSON(context.Request.Body, &registration); binding != nil {
+		problems.Abort(context, binding)
 		return
 	}
 
 	// Trying to crete a hash for password
-	if exception := credentials.HashPassword(); exception != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"summary": "Failed to create the hash for password",
-			"details": exception.Error(),
-		})
+	if exception := registration.HashPassword(context.Request.Context()); errors.Is(exception, bcrypt.ErrPasswordTooLong) {
+		problem := problems.ErrValidationFailed.Wrap(exception)
+		problem.Errors = []problems.FieldError{{Field: "password", Rule: "max", Message: "must be at most 72 bytes long"}}
+		problems.Abort(context, problem)
+		return
+	} else if exception != nil {
+		problems.Abort(context, problems.ErrInternal.Wrap(exception))
 		return
 	}
 
 	// Insert user into the database table users
-	user := models.User{
-		Nickname: credentials.Nickname,
-		Password: credentials.Password,
-	}
-	inserting := users.Database.Create(&user).Error
-	if inserting != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"summary": "Failed to insert user into table users",
-			"details": inserting.Error(),
-		})
+	user := registration.User()
+	inserting := models.WithContext(users.Database, context.Request.Context()).Create(&user).Error
+	if inserting != nil && problems.Duplicated(inserting) {
+		problems.Abort(context, problems.ErrConflict.WithDetail("The nickname is already taken").Wrap(inserting))
+		return
+	} else if inserting != nil {
+		problems.Abort(context, problems.ErrInternal.Wrap(inserting))
 		return
 	}
 
 	context.JSON(http.StatusCreated, gin.H{
 		"summary": "User successfully created",
-		"details": user.String(),
+		"user":    NewUserResponse(&user),
 	})
 }
 
@@ -106,31 +146,38 @@ func (users *UsersController) Login(context *gin.Context) {
 
 	// Checking the credentials
 	user := &models.User{}
-	users.Database.First(user, "nickname = ?", credentials.Nickname)
+	models.WithContext(users.Database, context.Request.Context()).First(user, "nickname = ?", credentials.Nickname)
+	_, span := tracing.Tracer().Start(context.Request.Context(), "bcrypt.CompareHashAndPassword")
 	failed := bcrypt.CompareHashAndPassword(
 		[]byte(user.Password),
 		[]byte(credentials.Password),
 	)
+	span.End()
 	if user.ID == 0 || failed != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"summary": "Invalid nickname or password",
-		})
+		metrics.Logins.WithLabelValues("failure").Inc()
+		problems.Abort(context, problems.ErrInvalidCredentials)
 		return
 	}
 
 	// Generate JWT Token and send it in the Cookies
 	token, exception := users.NewToken(user)
 	if exception != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"summary": "Unable to generate access token",
-			"details": exception.Error(),
-		})
+		problems.Abort(context, problems.ErrInternal.Wrap(exception))
 		return
 	}
 
 	// Send cookie to the client
+	metrics.Logins.WithLabelValues("success").Inc()
 	context.SetSameSite(http.SameSiteLaxMode)
-	context.SetCookie("Authorisation", token, 7*24*60*60, "", "", false, true)
+	context.SetCookie("Authorisation", token, 7*24*60*60, "/", "", false, true)
+
+	// The books added to the cart before logging in are kept in the cart of the user
+	if users.Carts != nil {
+		if exception := users.Carts.Merge(context, user); exception != nil {
+			logging.FromContext(context.Request.Context()).Error("Failed to merge the anonymous cart", "error", exception.Error())
+		}
+	}
+
 	context.JSON(http.StatusOK, gin.H{"summary": "Yaaay! You are logged in :)"})
 }
 
@@ -168,7 +215,7 @@ func (users *UsersController) ValidateToken(context *gin.Context) (*models.User,
 
 	// Looking for the user nickname
 	user := &models.User{}
-	users.Database.First(user, "nickname = ?", claims["identifier"].(string))
+	models.WithContext(users.Database, context.Request.Context()).First(user, "nickname = ?", claims["identifier"].(string))
 	if user.ID == 0 {
 		return nil, errors.New("user not found")
 	}
@@ -179,16 +226,55 @@ func (users *UsersController) ValidateToken(context *gin.Context) (*models.User,
 func (users *UsersController) Authorise(context *gin.Context) {
 	user, exception := users.ValidateToken(context)
 	if exception != nil {
-		context.AbortWithStatusJSON(
-			http.StatusUnauthorized,
-			gin.H{
-				"summary": "Unauthorised",
-				"details": exception.Error(),
-			},
-		)
+		problems.Abort(context, problems.ErrUnauthorised.Wrap(exception))
+		return
+	}
+
+	// Attach user to context and to the request logger, allow access and continue
+	identify(context, user)
+	context.Next()
+}
+
+// Identify attaches the user of a valid token to the context like Authorise, but lets the anonymous users continue too
+func (users *UsersController) Identify(context *gin.Context) {
+	if user, exception := users.ValidateToken(context); exception == nil {
+		identify(context, user)
 	}
 
-	// Attach user to context, allow access and continue
+	context.Next()
+}
+
+func identify(context *gin.Context, user *models.User) {
 	context.Set("user", user)
+	if user != nil {
+		logger := logging.FromContext(context.Request.Context()).With("user_id", user.ID)
+		context.Request = context.Request.WithContext(logging.WithLogger(context.Request.Context(), logger))
+	}
+}
+
+// authorised gives the user attached to the context by Authorise or Identify, if any
+func authorised(context *gin.Context) *models.User {
+	user, _ := context.Get("user")
+	authorised, _ := user.(*models.User)
+	return authorised
+}
+
+// Administer only lets the authorised users listed as administrators continue
+func (users *UsersController) Administer(context *gin.Context) {
+	if user := authorised(context); user == nil || !slices.Contains(users.Administrators, user.Nickname) {
+		problems.Abort(context, problems.ErrForbidden.WithDetail("Only the administrators can do this"))
+		return
+	}
+
 	context.Next()
 }
+
+// AdministerTrash only lets the administrators look into the trash through the deleted filter
+func (users *UsersController) AdministerTrash(context *gin.Context) {
+	if context.Query("deleted") == "" {
+		context.Next()
+		return
+	}
+
+	users.Administer(context)
+}
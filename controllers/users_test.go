@@ -2,22 +2,34 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"bou.ke/monkey"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/metrics"
+	"github.com/zatarain/bookshop/middlewares"
 	"github.com/zatarain/bookshop/mocks"
 	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/exp/slices"
 	"gorm.io/gorm"
@@ -33,6 +45,7 @@ func TestSignup(test *testing.T) {
 	test.Run("Should create a new user", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		database.
@@ -53,17 +66,21 @@ func TestSignup(test *testing.T) {
 		// Assert
 		assert.Equal(http.StatusCreated, recorder.Code)
 		assert.Contains(recorder.Body.String(), "User successfully created")
+		assert.Contains(recorder.Body.String(), `"nickname":"dummy-user"`)
+		assert.NotContains(recorder.Body.String(), "password")
+		assert.NotContains(recorder.Body.String(), "top-secret")
 		database.AssertExpectations(test)
 	})
 
 	test.Run("Should NOT create a duplicated user", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		database.
 			On("Create", mock.AnythingOfType("*models.User")).
-			Return(&gorm.DB{Error: errors.New("User already exists")})
+			Return(&gorm.DB{Error: errors.New("UNIQUE constraint failed: users.nickname")})
 		server.POST("/signup", users.Signup)
 		user := Credentials{
 			Nickname: "dummy-user",
@@ -77,14 +94,42 @@ func TestSignup(test *testing.T) {
 		server.ServeHTTP(recorder, request)
 
 		// Assert
-		assert.Equal(http.StatusBadRequest, recorder.Code)
-		assert.Contains(recorder.Body.String(), "User already exists")
+		assert.Equal(http.StatusConflict, recorder.Code)
+		assert.Equal(problems.ContentType, recorder.Header().Get("Content-Type"))
+		assert.Contains(recorder.Body.String(), `"code":"conflict"`)
+		assert.Contains(recorder.Body.String(), "The nickname is already taken")
+		assert.NotContains(recorder.Body.String(), "UNIQUE constraint failed")
+		database.AssertExpectations(test)
+	})
+
+	test.Run("Should NOT echo an unexpected database error", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		server.Use(middlewares.Problems())
+		database := new(mocks.MockedDataAccessInterface)
+		users := &UsersController{Database: database}
+		database.
+			On("Create", mock.AnythingOfType("*models.User")).
+			Return(&gorm.DB{Error: errors.New("database is locked")})
+		server.POST("/signup", users.Signup)
+		body, _ := json.Marshal(Credentials{Nickname: "dummy-user", Password: "top-secret"})
+		request, _ := http.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(body))
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		assert.Equal(http.StatusInternalServerError, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"internal_error"`)
+		assert.NotContains(recorder.Body.String(), "database is locked")
 		database.AssertExpectations(test)
 	})
 
 	test.Run("Should NOT try to create a user when unable to bind JSON", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		database.
@@ -100,13 +145,84 @@ func TestSignup(test *testing.T) {
 
 		// Assert
 		assert.Equal(http.StatusBadRequest, recorder.Code)
-		assert.Contains(recorder.Body.String(), "Failed to read input")
+		assert.Contains(recorder.Body.String(), `"code":"malformed_request"`)
+		database.AssertNotCalled(test, "Create", mock.AnythingOfType("*models.User"))
+	})
+
+	InvalidCredentialsTestcases := []struct {
+		description string
+		body        string
+		expected    string
+	}{
+		{
+			description: "Should NOT try to create a user without nickname",
+			body:        `{"password": "top-secret"}`,
+			expected:    `{"field":"nickname","rule":"required","message":"is required"}`,
+		},
+		{
+			description: "Should NOT try to create a user with a blank nickname",
+			body:        `{"nickname": "   ", "password": "top-secret"}`,
+			expected:    `{"field":"nickname","rule":"notblank","message":"must not be blank"}`,
+		},
+		{
+			description: "Should NOT try to create a user with a short password",
+			body:        `{"nickname": "dummy-user", "password": "secret"}`,
+			expected:    `{"field":"password","rule":"min","message":"must be at least 8 characters long"}`,
+		},
+		{
+			description: "Should NOT try to create a user with unknown fields",
+			body:        `{"nickname": "dummy-user", "password": "top-secret", "admin": true}`,
+			expected:    `{"field":"admin","rule":"unknown","message":"is not allowed"}`,
+		},
+	}
+
+	for _, testcase := range InvalidCredentialsTestcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			server := gin.New()
+			server.Use(middlewares.Problems())
+			database := new(mocks.MockedDataAccessInterface)
+			users := &UsersController{Database: database}
+			server.POST("/signup", users.Signup)
+			request, _ := http.NewRequest(http.MethodPost, "/signup", strings.NewReader(testcase.body))
+			recorder := httptest.NewRecorder()
+
+			// Act
+			server.ServeHTTP(recorder, request)
+
+			// Assert
+			assert.Equal(http.StatusBadRequest, recorder.Code)
+			assert.Contains(recorder.Body.String(), `"code":"validation_failed"`)
+			assert.Contains(recorder.Body.String(), testcase.expected)
+			database.AssertNotCalled(test, "Create", mock.AnythingOfType("*models.User"))
+		})
+	}
+
+	test.Run("Should NOT try to create a user when the password has too many bytes", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		server.Use(middlewares.Problems())
+		database := new(mocks.MockedDataAccessInterface)
+		users := &UsersController{Database: database}
+		server.POST("/signup", users.Signup)
+		body, _ := json.Marshal(Credentials{Nickname: "dummy-user", Password: strings.Repeat("ñ", 40)})
+		request, _ := http.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(body))
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"validation_failed"`)
+		assert.Contains(recorder.Body.String(), `{"field":"password","rule":"max","message":"must be at most 72 bytes long"}`)
 		database.AssertNotCalled(test, "Create", mock.AnythingOfType("*models.User"))
 	})
 
 	test.Run("Should NOT try to create a user when unable hash password", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		database.
@@ -128,12 +244,37 @@ func TestSignup(test *testing.T) {
 		server.ServeHTTP(recorder, request)
 
 		// Assert
-		assert.Equal(http.StatusBadRequest, recorder.Code)
-		assert.Contains(recorder.Body.String(), "Failed to create the hash for password")
+		assert.Equal(http.StatusInternalServerError, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"internal_error"`)
+		assert.NotContains(recorder.Body.String(), "Unable to hash")
 		database.AssertNotCalled(test, "Create", mock.AnythingOfType("*models.User"))
 	})
 }
 
+func TestHashPassword(test *testing.T) {
+	assert := assert.New(test)
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	test.Run("Should hash the password within a child span", func(test *testing.T) {
+		// Arrange
+		registration := &Registration{Nickname: "dummy-user", Password: "top-secret"}
+		parent, span := tracing.Tracer().Start(context.Background(), "signup")
+
+		// Act
+		exception := registration.HashPassword(parent)
+		span.End()
+
+		// Assert
+		assert.Nil(exception)
+		assert.Nil(bcrypt.CompareHashAndPassword([]byte(registration.Password), []byte("top-secret")))
+		spans := exporter.GetSpans()
+		require.Len(test, spans, 2)
+		assert.Equal("bcrypt.GenerateFromPassword", spans[0].Name)
+		assert.Equal(span.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	})
+}
+
 func TestLogin(test *testing.T) {
 	assert := assert.New(test)
 	gin.SetMode(gin.TestMode)
@@ -164,6 +305,7 @@ func TestLogin(test *testing.T) {
 	test.Run("Should login the user and create the token", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		anyUser := mock.AnythingOfType("*models.User")
@@ -187,6 +329,7 @@ func TestLogin(test *testing.T) {
 		body, _ := json.Marshal(user)
 		request, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
 		recorder := httptest.NewRecorder()
+		successes := testutil.ToFloat64(metrics.Logins.WithLabelValues("success"))
 
 		// Act
 		server.ServeHTTP(recorder, request)
@@ -195,18 +338,53 @@ func TestLogin(test *testing.T) {
 
 		// Assert
 		database.AssertExpectations(test)
+		assert.Equal(successes+1, testutil.ToFloat64(metrics.Logins.WithLabelValues("success")))
 		assert.Equal(http.StatusOK, recorder.Code)
 		assert.Contains(recorder.Body.String(), "Yaaay! You are logged in :)")
 		require.GreaterOrEqual(test, index, 0)
 		assert.Equal("Nice+Fake+Token", cookies[index].Value)
+		assert.Equal("/", cookies[index].Path)
 		assert.Equal(7*24*60*60, cookies[index].MaxAge)
 		assert.False(cookies[index].Secure)
 		assert.True(cookies[index].HttpOnly)
 	})
 
+	test.Run("Should login the users whose credentials predate the rules of the signup", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		server.Use(middlewares.Problems())
+		database := new(mocks.MockedDataAccessInterface)
+		users := &UsersController{Database: database}
+		nickname := strings.Repeat("n", 40)
+		call := database.
+			On("First", mock.AnythingOfType("*models.User"), "nickname = ?", nickname).
+			Return(&gorm.DB{Error: nil})
+		call.RunFn = func(arguments mock.Arguments) {
+			user := arguments.Get(0).(*models.User)
+			user.ID = 12345
+			user.Nickname = nickname
+			user.Password = "short"
+		}
+
+		monkey.Patch(bcrypt.CompareHashAndPassword, CompareSuccessful)
+		monkey.PatchInstanceMethod(reflect.TypeOf(users), "NewToken", NiceFakeToken)
+		server.POST("/login", users.Login)
+		body, _ := json.Marshal(Credentials{Nickname: nickname, Password: "short"})
+		request, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		database.AssertExpectations(test)
+		assert.Equal(http.StatusOK, recorder.Code)
+	})
+
 	test.Run("Should response with internal server error when unable to generate token", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		anyUser := mock.AnythingOfType("*models.User")
@@ -239,13 +417,15 @@ func TestLogin(test *testing.T) {
 		// Assert
 		database.AssertExpectations(test)
 		assert.Equal(http.StatusInternalServerError, recorder.Code)
-		assert.Contains(recorder.Body.String(), "Unable to generate access token")
+		assert.Contains(recorder.Body.String(), `"code":"internal_error"`)
+		assert.NotContains(recorder.Body.String(), "No Token")
 		require.Equal(test, index, -1)
 	})
 
 	test.Run("Should NOT try to login the user when unable to bind JSON", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		anyUser := mock.AnythingOfType("*models.User")
@@ -260,7 +440,7 @@ func TestLogin(test *testing.T) {
 
 		// Assert
 		assert.Equal(http.StatusBadRequest, recorder.Code)
-		assert.Contains(recorder.Body.String(), "Failed to read input")
+		assert.Contains(recorder.Body.String(), `"code":"malformed_request"`)
 		database.AssertNotCalled(test, "First", mock.AnythingOfType("*models.User"))
 	})
 
@@ -309,6 +489,7 @@ func TestLogin(test *testing.T) {
 		test.Run(testcase.description, func(test *testing.T) {
 			// Arrange
 			server := gin.New()
+			server.Use(middlewares.Problems())
 			database := new(mocks.MockedDataAccessInterface)
 			users := &UsersController{Database: database}
 			call := database.
@@ -327,16 +508,47 @@ func TestLogin(test *testing.T) {
 			body, _ := json.Marshal(user)
 			request, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
 			recorder := httptest.NewRecorder()
+			failures := testutil.ToFloat64(metrics.Logins.WithLabelValues("failure"))
 
 			// Act
 			server.ServeHTTP(recorder, request)
 
 			// Assert
+			assert.Equal(failures+1, testutil.ToFloat64(metrics.Logins.WithLabelValues("failure")))
 			assert.Equal(http.StatusBadRequest, recorder.Code)
+			assert.Contains(recorder.Body.String(), `"code":"invalid_credentials"`)
 			assert.Contains(recorder.Body.String(), "Invalid nickname or password")
 			database.AssertExpectations(test)
 		})
 	}
+
+	test.Run("Should trace the password comparison within the request", func(test *testing.T) {
+		// Arrange
+		exporter := tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		server := gin.New()
+		server.Use(middlewares.Problems())
+		server.Use(middlewares.Tracing())
+		database := new(mocks.MockedDataAccessInterface)
+		users := &UsersController{Database: database}
+		database.
+			On("First", mock.AnythingOfType("*models.User"), "nickname = ?", "dummy-user").
+			Return(&gorm.DB{Error: nil})
+		monkey.Patch(bcrypt.CompareHashAndPassword, CompareFailure)
+		server.POST("/login", users.Login)
+		body, _ := json.Marshal(Credentials{Nickname: "dummy-user", Password: "top-secret"})
+		request, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+
+		// Act
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		// Assert
+		spans := exporter.GetSpans()
+		require.Len(test, spans, 2)
+		assert.Equal("bcrypt.CompareHashAndPassword", spans[0].Name)
+		assert.Equal("POST /login", spans[1].Name)
+		assert.Equal(spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	})
 }
 
 func TestAuthorise(test *testing.T) {
@@ -377,6 +589,7 @@ func TestAuthorise(test *testing.T) {
 	test.Run("Should set the user within the context and continue when token is valid", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		server.GET("/", users.Authorise, AuthorisedEndPointHandler)
@@ -394,6 +607,7 @@ func TestAuthorise(test *testing.T) {
 	test.Run("Should set the user within the context and continue when token is valid", func(test *testing.T) {
 		// Arrange
 		server := gin.New()
+		server.Use(middlewares.Problems())
 		database := new(mocks.MockedDataAccessInterface)
 		users := &UsersController{Database: database}
 		server.GET("/", users.Authorise, UnauthorisedEndPointHandler)
@@ -406,10 +620,113 @@ func TestAuthorise(test *testing.T) {
 
 		// Assert
 		assert.Equal(http.StatusUnauthorized, recorder.Code)
-		assert.Contains(recorder.Body.String(), "Invalid token")
+		assert.Contains(recorder.Body.String(), `"code":"unauthorised"`)
+		assert.NotContains(recorder.Body.String(), "Invalid token")
+	})
+
+	test.Run("Should attach the user to the request logger", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		server := gin.New()
+		server.Use(middlewares.Problems())
+		database := new(mocks.MockedDataAccessInterface)
+		users := &UsersController{Database: database}
+		server.Use(middlewares.Logger(logging.New(&output, slog.LevelInfo, "json")))
+		server.GET("/", users.Authorise, func(context *gin.Context) {
+			logging.FromContext(context.Request.Context()).Info("Inside the handler")
+		})
+		monkey.PatchInstanceMethod(reflect.TypeOf(users), "ValidateToken", ValidToken)
+		request, _ := http.NewRequest("GET", "/", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		var entry map[string]any
+		json.Unmarshal(bytes.Split(output.Bytes(), []byte("\n"))[0], &entry)
+		assert.Equal("Inside the handler", entry["msg"])
+		assert.Equal(float64(dummy.ID), entry["user_id"])
 	})
 }
 
+func TestAdminister(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	users := &UsersController{Administrators: []string{"admin-user"}}
+
+	testcases := []struct {
+		description string
+		user        *models.User
+		expected    int
+	}{
+		{"Should let the administrators continue", &models.User{Nickname: "admin-user"}, http.StatusNoContent},
+		{"Should forbid the other users", &models.User{Nickname: "dummy-user"}, http.StatusForbidden},
+		{"Should forbid the requests without user", nil, http.StatusForbidden},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			server := gin.New()
+			server.Use(middlewares.Problems())
+			server.DELETE("/users/:id", func(context *gin.Context) {
+				if testcase.user != nil {
+					context.Set("user", testcase.user)
+				}
+			}, users.Administer, func(context *gin.Context) {
+				context.Status(http.StatusNoContent)
+			})
+			request, _ := http.NewRequest(http.MethodDelete, "/users/7", nil)
+			recorder := httptest.NewRecorder()
+
+			// Act
+			server.ServeHTTP(recorder, request)
+
+			// Assert
+			assert.Equal(testcase.expected, recorder.Code)
+		})
+	}
+}
+
+func TestAdministerTrash(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	users := &UsersController{Administrators: []string{"admin-user"}}
+
+	testcases := []struct {
+		description string
+		nickname    string
+		path        string
+		expected    int
+	}{
+		{"Should let the administrators look into the trash", "admin-user", "/books?deleted=only", http.StatusNoContent},
+		{"Should forbid the other users to look into the trash", "dummy-user", "/books?deleted=include", http.StatusForbidden},
+		{"Should let any user list the books without the filter", "dummy-user", "/books", http.StatusNoContent},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			server := gin.New()
+			server.Use(middlewares.Problems())
+			server.GET("/books", func(context *gin.Context) {
+				context.Set("user", &models.User{Nickname: testcase.nickname})
+			}, users.AdministerTrash, func(context *gin.Context) {
+				context.Status(http.StatusNoContent)
+			})
+			request, _ := http.NewRequest(http.MethodGet, testcase.path, nil)
+			recorder := httptest.NewRecorder()
+
+			// Act
+			server.ServeHTTP(recorder, request)
+
+			// Assert
+			assert.Equal(testcase.expected, recorder.Code)
+		})
+	}
+}
+
 func TestNewToken(test *testing.T) {
 	assert := assert.New(test)
 	users := &UsersController{SecretTokenKey: "super-secret-key"}
@@ -443,6 +760,7 @@ func TestNewToken(test *testing.T) {
 func TestValidateToken(test *testing.T) {
 	assert := assert.New(test)
 	server := gin.New()
+	server.Use(middlewares.Problems())
 	users := &UsersController{SecretTokenKey: "super-secret-key"}
 	var exception error
 	var userResult *models.User
@@ -0,0 +1,223 @@
+package databasetest
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type server struct {
+	once      sync.Once
+	url       string
+	directory string
+	reason    string
+}
+
+var local = &server{}
+
+var counter = struct {
+	sync.Mutex
+	value int
+}{}
+
+var silent = &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+func unique(prefix string) string {
+	counter.Lock()
+	defer counter.Unlock()
+	counter.value++
+	return fmt.Sprintf("%s_%d_%d", prefix, os.Getpid(), counter.value)
+}
+
+// Main runs the tests of a package and stops the local PostgreSQL server if one was started
+func Main(suite *testing.M) int {
+	code := suite.Run()
+	if local.directory != "" {
+		data := filepath.Join(local.directory, "data")
+		exec.Command(local.binary("pg_ctl"), "-D", data, "-m", "immediate", "stop").Run()
+		os.RemoveAll(local.directory)
+	}
+
+	return code
+}
+
+// Run executes the scenario once per database driver available in this machine
+func Run(test *testing.T, scenario func(test *testing.T, database *gorm.DB)) {
+	drivers := map[string]func(*testing.T) *gorm.DB{
+		"sqlite":   openSQLite,
+		"postgres": openPostgres,
+		"mysql":    openMySQL,
+	}
+
+	for _, name := range []string{"sqlite", "postgres", "mysql"} {
+		open := drivers[name]
+		test.Run(name, func(test *testing.T) {
+			scenario(test, open(test))
+		})
+	}
+}
+
+// SQLite opens a fresh database file for the scenarios that only need a working database
+func SQLite(test *testing.T) *gorm.DB {
+	return openSQLite(test)
+}
+
+func openSQLite(test *testing.T) *gorm.DB {
+	// The transactions lock the database as they begin and wait for each other, like the ones of the service
+	filename := filepath.Join(test.TempDir(), "test.db") + "?_busy_timeout=5000&_txlock=immediate"
+	database, exception := gorm.Open(sqlite.Open(filename), silent)
+	if exception != nil {
+		test.Fatal(exception)
+	}
+
+	connection, _ := database.DB()
+	test.Cleanup(func() { connection.Close() })
+	return database
+}
+
+func openPostgres(test *testing.T) *gorm.DB {
+	location := os.Getenv("TEST_POSTGRES_URL")
+	if location == "" {
+		local.once.Do(local.start)
+		if local.url == "" {
+			test.Skipf("PostgreSQL is not available: %s", local.reason)
+		}
+		location = local.url
+	}
+
+	// Every test works within its own schema
+	administrator, exception := gorm.Open(postgres.Open(location), silent)
+	if exception != nil {
+		test.Fatal(exception)
+	}
+
+	schema := unique("test")
+	administrator.Exec("CREATE SCHEMA " + schema)
+	separator := "?"
+	if strings.Contains(location, "?") {
+		separator = "&"
+	}
+
+	database, exception := gorm.Open(postgres.Open(location+separator+"search_path="+schema), silent)
+	if exception != nil {
+		test.Fatal(exception)
+	}
+
+	test.Cleanup(func() {
+		connection, _ := database.DB()
+		connection.Close()
+		administrator.Exec("DROP SCHEMA " + schema + " CASCADE")
+		connection, _ = administrator.DB()
+		connection.Close()
+	})
+
+	return database
+}
+
+func openMySQL(test *testing.T) *gorm.DB {
+	location := os.Getenv("TEST_MYSQL_DSN")
+	if location == "" {
+		test.Skip("MySQL is not available: TEST_MYSQL_DSN is not set")
+	}
+
+	// Every test works within its own database
+	administrator, exception := gorm.Open(mysql.Open(location), silent)
+	if exception != nil {
+		test.Fatal(exception)
+	}
+
+	name := unique("test")
+	administrator.Exec("CREATE DATABASE " + name)
+	prefix, parameters, _ := strings.Cut(location, "?")
+	prefix = prefix[:strings.LastIndex(prefix, "/")+1] + name
+	if parameters != "" {
+		prefix += "?" + parameters
+	}
+
+	database, exception := gorm.Open(mysql.Open(prefix), silent)
+	if exception != nil {
+		test.Fatal(exception)
+	}
+
+	test.Cleanup(func() {
+		connection, _ := database.DB()
+		connection.Close()
+		administrator.Exec("DROP DATABASE " + name)
+		connection, _ = administrator.DB()
+		connection.Close()
+	})
+
+	return database
+}
+
+func (local *server) binary(name string) string {
+	if directory := os.Getenv("POSTGRES_BIN"); directory != "" {
+		return filepath.Join(directory, name)
+	}
+
+	if found, exception := exec.LookPath(name); exception == nil {
+		return found
+	}
+
+	// Debian and Ubuntu install the binaries out of the PATH
+	candidates, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql/*/bin", name))
+	if len(candidates) > 0 {
+		return candidates[len(candidates)-1]
+	}
+
+	return name
+}
+
+func (local *server) start() {
+	if _, exception := exec.LookPath(local.binary("initdb")); exception != nil {
+		local.reason = "no initdb binary was found"
+		return
+	}
+
+	directory, exception := os.MkdirTemp("", "bookshop-postgres-")
+	if exception != nil {
+		local.reason = exception.Error()
+		return
+	}
+
+	// Pick a free port, the server only listens on a socket within the directory
+	listener, exception := net.Listen("tcp", "127.0.0.1:0")
+	if exception != nil {
+		local.reason = exception.Error()
+		return
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	data := filepath.Join(directory, "data")
+	initialise := exec.Command(local.binary("initdb"), "-D", data, "-U", "postgres", "--auth=trust")
+	if output, exception := initialise.CombinedOutput(); exception != nil {
+		local.reason = fmt.Sprintf("initdb failed: %s", output)
+		os.RemoveAll(directory)
+		return
+	}
+
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=''", port, directory)
+	start := exec.Command(local.binary("pg_ctl"), "-D", data, "-o", options, "-l", filepath.Join(directory, "server.log"), "-w", "-t", "30", "start")
+	if output, exception := start.CombinedOutput(); exception != nil {
+		local.reason = fmt.Sprintf("pg_ctl failed: %s", output)
+		os.RemoveAll(directory)
+		return
+	}
+
+	log.Printf("Started a local PostgreSQL server in %s\n", directory)
+	local.directory = directory
+	local.url = fmt.Sprintf("postgres://postgres@/postgres?host=%s&port=%d&sslmode=disable", directory, port)
+}
@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zatarain/bookshop/migrations"
+	"gorm.io/gorm"
+)
+
+func Database(database *gorm.DB) Checker {
+	return CheckerFunc(func(context context.Context) (Details, error) {
+		connection, exception := database.DB()
+		if exception != nil {
+			return nil, exception
+		}
+
+		if exception := connection.PingContext(context); exception != nil {
+			return nil, exception
+		}
+
+		statistics := connection.Stats()
+		return Details{
+			"driver":        database.Dialector.Name(),
+			"open":          statistics.OpenConnections,
+			"in_use":        statistics.InUse,
+			"max_open":      statistics.MaxOpenConnections,
+			"wait_count":    statistics.WaitCount,
+			"wait_duration": statistics.WaitDuration.String(),
+		}, nil
+	})
+}
+
+// Migrations checks every migration was applied, only reading the schema so the probes never change it
+func Migrations(database *gorm.DB) Checker {
+	return CheckerFunc(func(context context.Context) (Details, error) {
+		migrator, exception := migrations.New(database.WithContext(context))
+		if exception != nil {
+			return nil, exception
+		}
+
+		statuses, exception := migrator.Inspect()
+		if exception != nil {
+			return nil, exception
+		}
+
+		pending := []string{}
+		current := uint(0)
+		for _, status := range statuses {
+			if status.Applied {
+				current = status.Version
+				continue
+			}
+			pending = append(pending, fmt.Sprintf("%04d_%s", status.Version, status.Name))
+		}
+
+		details := Details{"current": current, "pending": pending}
+		if len(pending) > 0 {
+			return details, fmt.Errorf("%d pending migrations", len(pending))
+		}
+
+		return details, nil
+	})
+}
+
+// Disk checks the database file still exists and its file system has at least the minimum free bytes
+func Disk(filename string, minimum uint64) Checker {
+	return CheckerFunc(func(context.Context) (Details, error) {
+		if _, exception := os.Stat(filename); exception != nil {
+			return nil, exception
+		}
+
+		free, exception := freeSpace(filepath.Dir(filename))
+		if exception != nil {
+			return nil, exception
+		}
+
+		details := Details{"path": filename, "free_bytes": free, "minimum_bytes": minimum}
+		if free < minimum {
+			return details, errors.New("not enough free disk space")
+		}
+
+		return details, nil
+	})
+}
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/migrations"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func temporaryDatabase(test *testing.T) (*gorm.DB, string) {
+	filename := filepath.Join(test.TempDir(), "test.db")
+	database, exception := gorm.Open(sqlite.Open(filename), &gorm.Config{})
+	require.Nil(test, exception)
+
+	connection, _ := database.DB()
+	test.Cleanup(func() { connection.Close() })
+	return database, filename
+}
+
+func TestDatabase(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should be up when the database answers the ping", func(test *testing.T) {
+		// Arrange
+		database, _ := temporaryDatabase(test)
+
+		// Act
+		details, exception := Database(database).Check(context.Background())
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal("sqlite", details["driver"])
+	})
+
+	test.Run("Should be down when the connection is closed", func(test *testing.T) {
+		// Arrange
+		database, _ := temporaryDatabase(test)
+		connection, _ := database.DB()
+		connection.Close()
+
+		// Act
+		_, exception := Database(database).Check(context.Background())
+
+		// Assert
+		assert.ErrorContains(exception, "database is closed")
+	})
+}
+
+func TestMigrations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should be down while there are pending migrations", func(test *testing.T) {
+		// Arrange
+		database, _ := temporaryDatabase(test)
+		migrator, _ := migrations.New(database)
+		migrator.Migrations = migrator.Migrations[:1]
+		migrator.Up()
+
+		// Act
+		details, exception := Migrations(database).Check(context.Background())
+
+		// Assert
+		assert.ErrorContains(exception, "pending migrations")
+		assert.Equal(uint(1), details["current"])
+		assert.Contains(details["pending"], "0002_book_prices")
+	})
+
+	test.Run("Should be down without changing the schema when the database was never migrated", func(test *testing.T) {
+		// Arrange
+		database, _ := temporaryDatabase(test)
+
+		// Act
+		_, exception := Migrations(database).Check(context.Background())
+
+		// Assert
+		assert.ErrorIs(exception, migrations.ErrNotMigrated)
+		assert.False(database.Migrator().HasTable(&migrations.SchemaMigration{}))
+	})
+
+	test.Run("Should be up when all the migrations were applied", func(test *testing.T) {
+		// Arrange
+		database, _ := temporaryDatabase(test)
+		migrator, _ := migrations.New(database)
+		migrator.Up()
+
+		// Act
+		details, exception := Migrations(database).Check(context.Background())
+
+		// Assert
+		assert.Nil(exception)
+		assert.Empty(details["pending"])
+		assert.Equal(migrator.Migrations[len(migrator.Migrations)-1].Version, details["current"])
+	})
+}
+
+func TestDisk(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should be up when the file exists and there is enough space", func(test *testing.T) {
+		// Arrange
+		filename := filepath.Join(test.TempDir(), "test.db")
+		os.WriteFile(filename, []byte{}, 0644)
+
+		// Act
+		details, exception := Disk(filename, 1).Check(context.Background())
+
+		// Assert
+		assert.Nil(exception)
+		assert.Greater(details["free_bytes"], uint64(0))
+	})
+
+	test.Run("Should be down when the file is gone", func(test *testing.T) {
+		// Arrange
+		filename := filepath.Join(test.TempDir(), "missing.db")
+
+		// Act
+		_, exception := Disk(filename, 1).Check(context.Background())
+
+		// Assert
+		assert.ErrorIs(exception, os.ErrNotExist)
+	})
+
+	test.Run("Should be down when there is not enough space", func(test *testing.T) {
+		// Arrange
+		filename := filepath.Join(test.TempDir(), "test.db")
+		os.WriteFile(filename, []byte{}, 0644)
+
+		// Act
+		_, exception := Disk(filename, math.MaxUint64).Check(context.Background())
+
+		// Assert
+		assert.ErrorContains(exception, "not enough free disk space")
+	})
+}
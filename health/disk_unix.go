@@ -0,0 +1,14 @@
+//go:build unix
+
+package health
+
+import "syscall"
+
+func freeSpace(directory string) (uint64, error) {
+	var statistics syscall.Statfs_t
+	if exception := syscall.Statfs(directory, &statistics); exception != nil {
+		return 0, exception
+	}
+
+	return statistics.Bavail * uint64(statistics.Bsize), nil
+}
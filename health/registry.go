@@ -0,0 +1,187 @@
+package health
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Build information, overridden at link time with -ldflags "-X github.com/zatarain/bookshop/health.Version=..."
+var (
+	Version   = "development"
+	Commit    = ""
+	BuildTime = ""
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type Details map[string]any
+
+type Checker interface {
+	Check(context.Context) (Details, error)
+}
+
+type CheckerFunc func(context.Context) (Details, error)
+
+type Component struct {
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_ms"`
+	Details Details `json:"details,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type Build struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+type Report struct {
+	Status     string               `json:"status"`
+	Latency    float64              `json:"latency_ms"`
+	Build      Build                `json:"build"`
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+type Registry struct {
+	mutex    sync.RWMutex
+	checkers map[string]Checker
+	Timeout  time.Duration
+}
+
+func (check CheckerFunc) Check(context context.Context) (Details, error) {
+	return check(context)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checkers: map[string]Checker{}, Timeout: 2 * time.Second}
+}
+
+func CurrentBuild() Build {
+	build := Build{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	// Fallback to the version control information embedded by the compiler
+	if information, available := debug.ReadBuildInfo(); available {
+		for _, setting := range information.Settings {
+			if setting.Key == "vcs.revision" && build.Commit == "" {
+				build.Commit = setting.Value
+			}
+			if setting.Key == "vcs.time" && build.BuildTime == "" {
+				build.BuildTime = setting.Value
+			}
+		}
+	}
+
+	return build
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+func (registry *Registry) Register(name string, checker Checker) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.checkers[name] = checker
+}
+
+func (registry *Registry) Names() []string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	names := make([]string, 0, len(registry.checkers))
+	for name := range registry.checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Run executes all the registered checks concurrently, each one limited by the registry timeout
+func (registry *Registry) Run(parent context.Context) Report {
+	registry.mutex.RLock()
+	checkers := make(map[string]Checker, len(registry.checkers))
+	for name, checker := range registry.checkers {
+		checkers[name] = checker
+	}
+	registry.mutex.RUnlock()
+
+	start := time.Now()
+	report := Report{
+		Status:     StatusUp,
+		Build:      CurrentBuild(),
+		Components: make(map[string]Component, len(checkers)),
+	}
+
+	var mutex sync.Mutex
+	var running sync.WaitGroup
+	for name, checker := range checkers {
+		running.Add(1)
+		go func(name string, checker Checker) {
+			defer running.Done()
+			component := run(parent, checker, registry.Timeout)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Components[name] = component
+			if component.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, checker)
+	}
+
+	running.Wait()
+	report.Latency = milliseconds(time.Since(start))
+	return report
+}
+
+func run(parent context.Context, checker Checker, timeout time.Duration) Component {
+	context, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	type outcome struct {
+		details Details
+		failure error
+	}
+
+	start := time.Now()
+	finished := make(chan outcome, 1)
+	go func() {
+		details, failure := checker.Check(context)
+		finished <- outcome{details, failure}
+	}()
+
+	// A check that ignores its context must not block the whole report
+	var result outcome
+	select {
+	case result = <-finished:
+	case <-context.Done():
+		result.failure = context.Err()
+	}
+
+	component := Component{
+		Status:  StatusUp,
+		Latency: milliseconds(time.Since(start)),
+		Details: result.details,
+	}
+
+	if result.failure != nil {
+		component.Status = StatusDown
+		component.Error = result.failure.Error()
+	}
+
+	return component
+}
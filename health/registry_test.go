@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(test *testing.T) {
+	assert := assert.New(test)
+	healthy := CheckerFunc(func(context.Context) (Details, error) {
+		return Details{"answer": 42}, nil
+	})
+	broken := CheckerFunc(func(context.Context) (Details, error) {
+		return nil, errors.New("Something is broken")
+	})
+	stuck := CheckerFunc(func(context.Context) (Details, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+
+	test.Run("Should report up when every component is up", func(test *testing.T) {
+		// Arrange
+		registry := NewRegistry()
+		registry.Register("first", healthy)
+		registry.Register("second", healthy)
+
+		// Act
+		report := registry.Run(context.Background())
+
+		// Assert
+		assert.Equal(StatusUp, report.Status)
+		assert.Len(report.Components, 2)
+		assert.Equal(StatusUp, report.Components["first"].Status)
+		assert.Equal(42, report.Components["first"].Details["answer"])
+		assert.Equal(runtime.Version(), report.Build.GoVersion)
+	})
+
+	test.Run("Should report down when any component is down", func(test *testing.T) {
+		// Arrange
+		registry := NewRegistry()
+		registry.Register("healthy", healthy)
+		registry.Register("broken", broken)
+
+		// Act
+		report := registry.Run(context.Background())
+
+		// Assert
+		assert.Equal(StatusDown, report.Status)
+		assert.Equal(StatusUp, report.Components["healthy"].Status)
+		assert.Equal(StatusDown, report.Components["broken"].Status)
+		assert.Equal("Something is broken", report.Components["broken"].Error)
+	})
+
+	test.Run("Should report down the components exceeding the timeout", func(test *testing.T) {
+		// Arrange
+		registry := NewRegistry()
+		registry.Timeout = 10 * time.Millisecond
+		registry.Register("stuck", stuck)
+
+		// Act
+		start := time.Now()
+		report := registry.Run(context.Background())
+
+		// Assert
+		assert.Less(time.Since(start), 500*time.Millisecond)
+		assert.Equal(StatusDown, report.Status)
+		assert.Equal(context.DeadlineExceeded.Error(), report.Components["stuck"].Error)
+	})
+
+	test.Run("Should report up without components", func(test *testing.T) {
+		// Act
+		report := NewRegistry().Run(context.Background())
+
+		// Assert
+		assert.Equal(StatusUp, report.Status)
+		assert.Empty(report.Components)
+	})
+}
+
+func TestRegister(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should replace a checker registered with the same name", func(test *testing.T) {
+		// Arrange
+		registry := NewRegistry()
+		registry.Register("zeta", CheckerFunc(func(context.Context) (Details, error) {
+			return nil, errors.New("Old checker")
+		}))
+
+		// Act
+		registry.Register("zeta", CheckerFunc(func(context.Context) (Details, error) {
+			return nil, nil
+		}))
+		registry.Register("alpha", CheckerFunc(func(context.Context) (Details, error) {
+			return nil, nil
+		}))
+
+		// Assert
+		assert.Equal([]string{"alpha", "zeta"}, registry.Names())
+		assert.Equal(StatusUp, registry.Run(context.Background()).Status)
+	})
+}
+
+func TestCurrentBuild(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should use the build information given at link time", func(test *testing.T) {
+		// Arrange
+		defer func(version string, commit string) {
+			Version, Commit = version, commit
+		}(Version, Commit)
+		Version = "1.2.3"
+		Commit = "abcdef"
+
+		// Act
+		build := CurrentBuild()
+
+		// Assert
+		assert.Equal("1.2.3", build.Version)
+		assert.Equal("abcdef", build.Commit)
+	})
+}
@@ -0,0 +1,70 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+type Lifecycle struct {
+	draining atomic.Bool
+	workers  sync.WaitGroup
+	context  context.Context
+	cancel   context.CancelFunc
+}
+
+func New() *Lifecycle {
+	context, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{context: context, cancel: cancel}
+}
+
+func (lifecycle *Lifecycle) Context() context.Context {
+	return lifecycle.context
+}
+
+func (lifecycle *Lifecycle) Draining() bool {
+	return lifecycle.draining.Load()
+}
+
+// Go runs a background worker that should return once the lifecycle context is done
+func (lifecycle *Lifecycle) Go(name string, worker func(context.Context)) {
+	lifecycle.workers.Add(1)
+	go func() {
+		defer lifecycle.workers.Done()
+		defer func() {
+			if failure := recover(); failure != nil {
+				slog.Error("Background worker crashed", "worker", name, "panic", failure)
+			}
+		}()
+
+		worker(lifecycle.context)
+	}()
+}
+
+// Withdraw flags the service as not ready so the load balancer stops sending traffic, while the workers keep going
+func (lifecycle *Lifecycle) Withdraw() {
+	lifecycle.draining.Store(true)
+}
+
+// Drain flags the service as not ready and asks the background workers to stop
+func (lifecycle *Lifecycle) Drain() {
+	lifecycle.Withdraw()
+	lifecycle.cancel()
+}
+
+// Wait blocks until all the background workers finish or the context is done
+func (lifecycle *Lifecycle) Wait(context context.Context) error {
+	finished := make(chan struct{})
+	go func() {
+		lifecycle.workers.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-context.Done():
+		return context.Err()
+	}
+}
@@ -0,0 +1,95 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should flag draining and stop the background workers", func(test *testing.T) {
+		// Arrange
+		lifecycle := New()
+		stopped := false
+		lifecycle.Go("dummy", func(context context.Context) {
+			<-context.Done()
+			stopped = true
+		})
+
+		// Act
+		before := lifecycle.Draining()
+		lifecycle.Drain()
+		exception := lifecycle.Wait(context.Background())
+
+		// Assert
+		assert.False(before)
+		assert.True(lifecycle.Draining())
+		assert.Nil(exception)
+		assert.True(stopped)
+	})
+}
+
+func TestWithdraw(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should flag draining and keep the background workers going", func(test *testing.T) {
+		// Arrange
+		lifecycle := New()
+
+		// Act
+		lifecycle.Withdraw()
+
+		// Assert
+		assert.True(lifecycle.Draining())
+		assert.Nil(lifecycle.Context().Err())
+	})
+}
+
+func TestWait(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should give up when a worker takes longer than the deadline", func(test *testing.T) {
+		// Arrange
+		lifecycle := New()
+		release := make(chan struct{})
+		defer close(release)
+		lifecycle.Go("stubborn", func(context.Context) {
+			<-release
+		})
+		deadline, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		// Act
+		lifecycle.Drain()
+		exception := lifecycle.Wait(deadline)
+
+		// Assert
+		assert.ErrorIs(exception, context.DeadlineExceeded)
+	})
+
+	test.Run("Should log and survive a worker that panics", func(test *testing.T) {
+		// Arrange
+		var capture bytes.Buffer
+		log.SetOutput(&capture)
+		defer log.SetOutput(os.Stderr)
+		lifecycle := New()
+		lifecycle.Go("faulty", func(context.Context) {
+			panic("Something went wrong")
+		})
+
+		// Act
+		exception := lifecycle.Wait(context.Background())
+
+		// Assert
+		assert.Nil(exception)
+		assert.Contains(capture.String(), "Background worker crashed")
+		assert.Contains(capture.String(), "worker=faulty panic=\"Something went wrong\"")
+	})
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var _ gormlogger.Interface = (*Gorm)(nil)
+var _ gorm.ParamsFilter = (*Gorm)(nil)
+
+// Gorm writes the logs of GORM through the logger of the context, so they are structured and redacted like the others.
+// The queries are logged at debug level, the ones slower than the threshold as warnings and the failed ones as errors,
+// always without the values of their parameters
+type Gorm struct {
+	SlowThreshold time.Duration
+	Level         gormlogger.LogLevel
+}
+
+func NewGorm(threshold time.Duration) *Gorm {
+	return &Gorm{SlowThreshold: threshold, Level: gormlogger.Info}
+}
+
+func (logger *Gorm) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *logger
+	copied.Level = level
+	return &copied
+}
+
+func (logger *Gorm) Info(context context.Context, message string, data ...any) {
+	if logger.Level >= gormlogger.Info {
+		FromContext(context).InfoContext(context, fmt.Sprintf(message, data...))
+	}
+}
+
+func (logger *Gorm) Warn(context context.Context, message string, data ...any) {
+	if logger.Level >= gormlogger.Warn {
+		FromContext(context).WarnContext(context, fmt.Sprintf(message, data...))
+	}
+}
+
+func (logger *Gorm) Error(context context.Context, message string, data ...any) {
+	if logger.Level >= gormlogger.Error {
+		FromContext(context).ErrorContext(context, fmt.Sprintf(message, data...))
+	}
+}
+
+func (logger *Gorm) Trace(context context.Context, begin time.Time, query func() (string, int64), exception error) {
+	if logger.Level <= gormlogger.Silent {
+		return
+	}
+
+	output := FromContext(context)
+	elapsed := time.Since(begin)
+	failed := exception != nil && !errors.Is(exception, gorm.ErrRecordNotFound) && logger.Level >= gormlogger.Error
+	slow := logger.SlowThreshold > 0 && elapsed > logger.SlowThreshold && logger.Level >= gormlogger.Warn
+	if !failed && !slow && (logger.Level < gormlogger.Info || !output.Enabled(context, slog.LevelDebug)) {
+		return
+	}
+
+	sql, rows := query()
+	attributes := []any{"sql", sql, "rows", rows, "elapsed", elapsed}
+	switch {
+	case failed:
+		output.ErrorContext(context, "Database query failed", append(attributes, "error", exception.Error())...)
+	case slow:
+		output.WarnContext(context, "Slow database query", attributes...)
+	default:
+		output.DebugContext(context, "Database query", attributes...)
+	}
+}
+
+// ParamsFilter leaves the values out of the queries logged, as they can be sensitive
+func (logger *Gorm) ParamsFilter(_ context.Context, sql string, _ ...any) (string, []any) {
+	return sql, nil
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGorm(test *testing.T) {
+	assert := assert.New(test)
+	query := func() (string, int64) { return "SELECT * FROM users WHERE name = ?", 1 }
+
+	test.Run("Should log the failed queries as errors", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		context := WithLogger(context.Background(), New(&output, slog.LevelInfo, "text"))
+		logger := NewGorm(time.Second)
+
+		// Act
+		logger.Trace(context, time.Now(), query, errors.New("no such table: users"))
+
+		// Assert
+		assert.Contains(output.String(), "level=ERROR")
+		assert.Contains(output.String(), "no such table: users")
+		assert.Contains(output.String(), "SELECT * FROM users WHERE name = ?")
+	})
+
+	test.Run("Should log the slow queries as warnings", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		context := WithLogger(context.Background(), New(&output, slog.LevelInfo, "text"))
+		logger := NewGorm(time.Millisecond)
+
+		// Act
+		logger.Trace(context, time.Now().Add(-time.Second), query, nil)
+
+		// Assert
+		assert.Contains(output.String(), "level=WARN")
+		assert.Contains(output.String(), "Slow database query")
+	})
+
+	test.Run("Should NOT log the queries that aren't found or fine unless debugging", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		context := WithLogger(context.Background(), New(&output, slog.LevelInfo, "text"))
+		logger := NewGorm(time.Second)
+
+		// Act
+		logger.Trace(context, time.Now(), query, gorm.ErrRecordNotFound)
+		logger.Trace(context, time.Now(), query, nil)
+
+		// Assert
+		assert.Empty(output.String())
+	})
+
+	test.Run("Should log the queries when debugging", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		context := WithLogger(context.Background(), New(&output, slog.LevelDebug, "text"))
+		logger := NewGorm(time.Second)
+
+		// Act
+		logger.Trace(context, time.Now(), query, nil)
+
+		// Assert
+		assert.Contains(output.String(), "level=DEBUG")
+		assert.Contains(output.String(), "rows=1")
+	})
+
+	test.Run("Should NOT log anything when silent", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		context := WithLogger(context.Background(), New(&output, slog.LevelDebug, "text"))
+		logger := NewGorm(time.Second).LogMode(gormlogger.Silent)
+
+		// Act
+		logger.Trace(context, time.Now(), query, errors.New("no such table: users"))
+		logger.Error(context, "Something failed: %s", "badly")
+
+		// Assert
+		assert.Empty(output.String())
+	})
+
+	test.Run("Should leave the values of the parameters out", func(test *testing.T) {
+		// Arrange
+		logger := NewGorm(time.Second)
+
+		// Act
+		sql, parameters := logger.ParamsFilter(context.Background(), "SELECT * FROM users WHERE password = ?", "top-secret")
+
+		// Assert
+		assert.Equal("SELECT * FROM users WHERE password = ?", sql)
+		assert.Nil(parameters)
+	})
+}
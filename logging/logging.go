@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type key struct{}
+
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+	if exception := level.UnmarshalText([]byte(name)); exception != nil {
+		return slog.LevelInfo
+	}
+
+	return level
+}
+
+func New(output io.Writer, level slog.Level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: Redact,
+	}
+
+	if strings.EqualFold(format, "text") {
+		return slog.New(slog.NewTextHandler(output, options))
+	}
+
+	return slog.New(slog.NewJSONHandler(output, options))
+}
+
+// Setup creates the logger from LOG_LEVEL and LOG_FORMAT and makes it the default one
+func Setup() *slog.Logger {
+	logger := New(os.Stdout, ParseLevel(os.Getenv("LOG_LEVEL")), os.Getenv("LOG_FORMAT"))
+	slog.SetDefault(logger)
+	return logger
+}
+
+func WithLogger(parent context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(parent, key{}, logger)
+}
+
+func FromContext(context context.Context) *slog.Logger {
+	if logger, ok := context.Value(key{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
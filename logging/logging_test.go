@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(test *testing.T) {
+	assert := assert.New(test)
+
+	testcases := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warn":    slog.LevelWarn,
+		"error":   slog.LevelError,
+		"":        slog.LevelInfo,
+		"verbose": slog.LevelInfo,
+	}
+
+	for name, expected := range testcases {
+		test.Run("Should parse level '"+name+"'", func(test *testing.T) {
+			// Act
+			actual := ParseLevel(name)
+
+			// Assert
+			assert.Equal(expected, actual)
+		})
+	}
+}
+
+func TestNew(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should write JSON lines filtered by level", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		logger := New(&output, slog.LevelWarn, "json")
+
+		// Act
+		logger.Info("Ignored message")
+		logger.Warn("Something happened", "answer", 42)
+
+		// Assert
+		var line map[string]any
+		assert.Nil(json.Unmarshal(output.Bytes(), &line))
+		assert.Equal("WARN", line["level"])
+		assert.Equal("Something happened", line["msg"])
+		assert.Equal(float64(42), line["answer"])
+	})
+
+	test.Run("Should write text lines when requested", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		logger := New(&output, slog.LevelInfo, "text")
+
+		// Act
+		logger.Info("Hello", "password", "top-secret")
+
+		// Assert
+		assert.Contains(output.String(), "msg=Hello")
+		assert.Contains(output.String(), "password=[REDACTED]")
+	})
+}
+
+func TestSetup(test *testing.T) {
+	assert := assert.New(test)
+	defer slog.SetDefault(slog.Default())
+
+	test.Run("Should make the configured logger the default one", func(test *testing.T) {
+		// Arrange
+		test.Setenv("LOG_LEVEL", "error")
+
+		// Act
+		logger := Setup()
+
+		// Assert
+		assert.Equal(logger, slog.Default())
+		assert.False(logger.Enabled(context.Background(), slog.LevelWarn))
+	})
+}
+
+func TestFromContext(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should return the logger attached to the context", func(test *testing.T) {
+		// Arrange
+		logger := New(&bytes.Buffer{}, slog.LevelInfo, "json")
+
+		// Act
+		actual := FromContext(WithLogger(context.Background(), logger))
+
+		// Assert
+		assert.Same(logger, actual)
+	})
+
+	test.Run("Should fallback to the default logger", func(test *testing.T) {
+		// Act
+		actual := FromContext(context.Background())
+
+		// Assert
+		assert.Same(slog.Default(), actual)
+	})
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"encoding"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+const Redacted = "[REDACTED]"
+
+var sensitive = []string{"password", "secret", "token", "authori", "cookie"}
+
+func Sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitive {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact hides the values of sensitive attributes, including the fields of structures and maps
+func Redact(groups []string, attribute slog.Attr) slog.Attr {
+	if Sensitive(attribute.Key) {
+		return slog.String(attribute.Key, Redacted)
+	}
+
+	if attribute.Value.Kind() == slog.KindAny {
+		return slog.Any(attribute.Key, sanitise(reflect.ValueOf(attribute.Value.Any()), 0))
+	}
+
+	return attribute
+}
+
+func opaque(value reflect.Value) bool {
+	// Values knowing how to represent themselves are not inspected
+	if !value.CanInterface() {
+		return false
+	}
+
+	switch value.Interface().(type) {
+	case error, json.Marshaler, encoding.TextMarshaler:
+		return true
+	}
+
+	return false
+}
+
+func sanitise(value reflect.Value, depth int) any {
+	if !value.IsValid() {
+		return nil
+	}
+
+	if depth > 8 || opaque(value) {
+		return value.Interface()
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return sanitise(value.Elem(), depth+1)
+	case reflect.Struct:
+		fields := map[string]any{}
+		sanitiseFields(value, fields, depth)
+		return fields
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return value.Interface()
+		}
+
+		entries := make(map[string]any, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			name := iterator.Key().String()
+			if Sensitive(name) {
+				entries[name] = Redacted
+				continue
+			}
+			entries[name] = sanitise(iterator.Value(), depth+1)
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return value.Interface()
+		}
+
+		items := make([]any, value.Len())
+		for index := range items {
+			items[index] = sanitise(value.Index(index), depth+1)
+		}
+		return items
+	}
+
+	return value.Interface()
+}
+
+func sanitiseFields(value reflect.Value, fields map[string]any, depth int) {
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structures are flattened like the JSON encoder does
+		if field.Anonymous && name == "" && value.Field(index).Kind() == reflect.Struct {
+			sanitiseFields(value.Field(index), fields, depth+1)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if Sensitive(field.Name) || Sensitive(name) || field.Tag.Get("log") == "redact" {
+			fields[name] = Redacted
+			continue
+		}
+
+		fields[name] = sanitise(value.Field(index), depth+1)
+	}
+}
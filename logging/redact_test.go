@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type account struct {
+	Nickname string
+	Password string
+}
+
+type profile struct {
+	account
+	ID       int               `json:"id"`
+	Session  string            `json:"session" log:"redact"`
+	Hidden   string            `json:"-"`
+	Friends  []account         `json:"friends"`
+	Settings map[string]string `json:"settings"`
+	Since    time.Time         `json:"since"`
+	internal string
+}
+
+func capture(arguments ...any) map[string]any {
+	var output bytes.Buffer
+	New(&output, slog.LevelInfo, "json").Info("Testing", arguments...)
+
+	var line map[string]any
+	json.Unmarshal(output.Bytes(), &line)
+	return line
+}
+
+func TestRedact(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should redact the password of credentials", func(test *testing.T) {
+		// Act
+		line := capture("credentials", &account{Nickname: "dummy-user", Password: "top-secret"})
+
+		// Assert
+		assert.Equal(map[string]any{"Nickname": "dummy-user", "Password": Redacted}, line["credentials"])
+	})
+
+	test.Run("Should redact sensitive attribute keys", func(test *testing.T) {
+		// Act
+		line := capture("password", "top-secret", "SecretTokenKey", "abc", "Authorisation", "Bearer abc", "nickname", "dummy-user")
+
+		// Assert
+		assert.Equal(Redacted, line["password"])
+		assert.Equal(Redacted, line["SecretTokenKey"])
+		assert.Equal(Redacted, line["Authorisation"])
+		assert.Equal("dummy-user", line["nickname"])
+	})
+
+	test.Run("Should redact nested, embedded and tagged fields", func(test *testing.T) {
+		// Arrange
+		since, _ := time.Parse(time.RFC3339, "1986-01-10T10:04:00Z")
+		value := profile{
+			account:  account{Nickname: "dummy-user", Password: "top-secret"},
+			ID:       7,
+			Session:  "session-identifier",
+			Hidden:   "invisible",
+			Friends:  []account{{Nickname: "friend", Password: "friend-secret"}},
+			Settings: map[string]string{"theme": "dark", "api_token": "xyz"},
+			Since:    since,
+			internal: "unexported",
+		}
+
+		// Act
+		line := capture("profile", value)
+
+		// Assert
+		assert.Equal(map[string]any{
+			"Nickname": "dummy-user",
+			"Password": Redacted,
+			"id":       float64(7),
+			"session":  Redacted,
+			"friends":  []any{map[string]any{"Nickname": "friend", "Password": Redacted}},
+			"settings": map[string]any{"theme": "dark", "api_token": Redacted},
+			"since":    "1986-01-10T10:04:00Z",
+		}, line["profile"])
+	})
+
+	test.Run("Should keep errors and plain values untouched", func(test *testing.T) {
+		// Act
+		line := capture("error", errors.New("Something went wrong"), "count", 3, "missing", nil)
+
+		// Assert
+		assert.Equal("Something went wrong", line["error"])
+		assert.Equal(float64(3), line["count"])
+		assert.Nil(line["missing"])
+	})
+}
@@ -1,24 +1,58 @@
 package main
 
 import (
-	"log"
+	"context"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/zatarain/bookshop/configuration"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/middlewares"
+	"github.com/zatarain/bookshop/problems"
+	"github.com/zatarain/bookshop/tracing"
 )
 
 func main() {
+	// Configure the structured logs first, so even the failures to start are logged through them
+	logger := logging.Setup()
+
 	// Connect to Database
 	connection := configuration.ConnectToDatabase()
 	defer connection.Close()
 
-	// Initialise Database
+	// Run a migration command instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if exception := configuration.RunMigrationCommand(os.Args[2:], os.Stdout); exception != nil {
+			logger.Error("Failed to run the migration command", "error", exception.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialise Database, purge the old soft-deleted records and release the expired reservations in the background
 	configuration.MigrateDatabase()
+	configuration.ScheduleRetention(configuration.Lifecycle, configuration.Database)
+	configuration.ScheduleReservations(configuration.Lifecycle, configuration.Database)
+
+	// Initialise the API Server with structured logs and traces
+	shutdown, exception := tracing.Setup(context.Background())
+	if exception != nil {
+		logger.Error("Failed to set up the tracing", "error", exception.Error())
+		os.Exit(1)
+	}
+	defer shutdown(context.Background())
+
+	engine := gin.New()
+	engine.Use(middlewares.Tracing(), middlewares.Logger(logger), middlewares.Metrics(), middlewares.Recovery(), middlewares.Problems())
+	engine.NoRoute(func(context *gin.Context) {
+		problems.Abort(context, problems.ErrNotFound.WithDetail("The route was not found"))
+	})
+	configuration.Setup(engine)
 
-	// Initialise the API Server
-	server := gin.Default()
-	configuration.Setup(server)
+	// Serve until we receive a signal to stop, then drain before closing the database
+	server := configuration.NewServer(engine)
 	if exception := server.Run(); exception != nil {
-		log.Panic(exception.Error())
+		logger.Error("Failed to run the server", "error", exception.Error())
+		os.Exit(1)
 	}
 }
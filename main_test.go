@@ -3,7 +3,8 @@ package main
 import (
 	"bytes"
 	"errors"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"reflect"
 	"testing"
@@ -12,27 +13,41 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/zatarain/bookshop/configuration"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/logging"
+	"gorm.io/gorm"
 )
 
 func TestMain(test *testing.T) {
 	assert := assert.New(test)
 	gin.SetMode(gin.TestMode)
-	monkey.Patch(log.Panic, log.Print)
+	code := 0
+	monkey.Patch(os.Exit, func(status int) { code = status })
+	monkey.Patch(logging.Setup, slog.Default)
+	monkey.Patch(configuration.ScheduleRetention, func(*lifecycle.Lifecycle, *gorm.DB) {})
+	monkey.Patch(configuration.ScheduleReservations, func(*lifecycle.Lifecycle, *gorm.DB) {})
 
 	// Teardown test suite
 	defer monkey.UnpatchAll()
-	defer log.SetOutput(os.Stderr)
 
 	test.Run("Should run the service", func(test *testing.T) {
 		// Arrange
 		serverHasBeenSetup := false
 		serverIsRunning := false
+		retentionIsScheduled := false
+		reservationsAreScheduled := false
+		monkey.Patch(configuration.ScheduleRetention, func(*lifecycle.Lifecycle, *gorm.DB) {
+			retentionIsScheduled = true
+		})
+		monkey.Patch(configuration.ScheduleReservations, func(*lifecycle.Lifecycle, *gorm.DB) {
+			reservationsAreScheduled = true
+		})
 		monkey.Patch(configuration.Setup, func(server gin.IRouter) {
 			serverHasBeenSetup = true
-			monkey.PatchInstanceMethod(reflect.TypeOf(server), "Run", func(*gin.Engine, ...string) error {
-				serverIsRunning = true
-				return nil
-			})
+		})
+		monkey.PatchInstanceMethod(reflect.TypeOf(&configuration.Server{}), "Run", func(*configuration.Server) error {
+			serverIsRunning = true
+			return nil
 		})
 
 		// Act
@@ -41,16 +56,43 @@ func TestMain(test *testing.T) {
 		// Assert
 		assert.True(serverHasBeenSetup)
 		assert.True(serverIsRunning)
+		assert.True(retentionIsScheduled)
+		assert.True(reservationsAreScheduled)
 	})
 
-	test.Run("Should log panic when failed to run server", func(test *testing.T) {
+	test.Run("Should run a migration command instead of the service", func(test *testing.T) {
 		// Arrange
-		var capture bytes.Buffer
-		log.SetOutput(&capture)
+		arguments := os.Args
+		defer func() { os.Args = arguments }()
+		os.Args = []string{"bookshop", "migrate", "status"}
+		var received []string
+		serverHasBeenSetup := false
+		monkey.Patch(configuration.RunMigrationCommand, func(arguments []string, output io.Writer) error {
+			received = arguments
+			return nil
+		})
 		monkey.Patch(configuration.Setup, func(server gin.IRouter) {
-			monkey.PatchInstanceMethod(reflect.TypeOf(server), "Run", func(*gin.Engine, ...string) error {
-				return errors.New("Failed to start the server")
-			})
+			serverHasBeenSetup = true
+		})
+
+		// Act
+		main()
+
+		// Assert
+		assert.Equal([]string{"status"}, received)
+		assert.False(serverHasBeenSetup)
+	})
+
+	test.Run("Should log an error and exit when failed to run server", func(test *testing.T) {
+		// Arrange
+		var capture bytes.Buffer
+		monkey.Patch(logging.Setup, func() *slog.Logger {
+			return slog.New(slog.NewTextHandler(&capture, nil))
+		})
+		defer monkey.Patch(logging.Setup, slog.Default)
+		monkey.Patch(configuration.Setup, func(server gin.IRouter) {})
+		monkey.PatchInstanceMethod(reflect.TypeOf(&configuration.Server{}), "Run", func(*configuration.Server) error {
+			return errors.New("Failed to start the server")
 		})
 
 		// Act
@@ -58,5 +100,6 @@ func TestMain(test *testing.T) {
 
 		// Assert
 		assert.Contains(capture.String(), "Failed to start the server")
+		assert.Equal(1, code)
 	})
 }
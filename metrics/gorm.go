@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func before(database *gorm.DB) {
+	database.InstanceSet(startKey, time.Now())
+}
+
+func after(operation string) func(*gorm.DB) {
+	return func(database *gorm.DB) {
+		value, exists := database.InstanceGet(startKey)
+		start, ok := value.(time.Time)
+		if !exists || !ok {
+			return
+		}
+
+		table := database.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+
+		outcome := "success"
+		if database.Error != nil && !errors.Is(database.Error, gorm.ErrRecordNotFound) {
+			outcome = "error"
+		}
+
+		DatabaseQueries.WithLabelValues(operation, table, outcome).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Initialize times every statement executed through the GORM callbacks
+func (GormPlugin) Initialize(database *gorm.DB) error {
+	callbacks := database.Callback()
+	type register func(string, func(*gorm.DB)) error
+	registrations := map[string][2]register{
+		"create": {callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		"query":  {callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		"update": {callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		"delete": {callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		"row":    {callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		"raw":    {callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+
+	for operation, registration := range registrations {
+		if exception := registration[0]("metrics:before_"+operation, before); exception != nil {
+			return exception
+		}
+
+		if exception := registration[1]("metrics:after_"+operation, after(operation)); exception != nil {
+			return exception
+		}
+	}
+
+	return nil
+}
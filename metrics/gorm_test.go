@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type shelf struct {
+	ID    uint
+	Label string
+}
+
+func samples(operation string, table string, outcome string) uint64 {
+	metric := &dto.Metric{}
+	DatabaseQueries.WithLabelValues(operation, table, outcome).(prometheus.Metric).Write(metric)
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestGormPlugin(test *testing.T) {
+	assert := assert.New(test)
+	filename := filepath.Join(test.TempDir(), "test.db")
+	database, exception := gorm.Open(sqlite.Open(filename), &gorm.Config{
+		Plugins: map[string]gorm.Plugin{"metrics": GormPlugin{}},
+	})
+	require.Nil(test, exception)
+	database.AutoMigrate(&shelf{})
+
+	test.Run("Should time the statements by operation and table", func(test *testing.T) {
+		// Arrange
+		creates := samples("create", "shelves", "success")
+		queries := samples("query", "shelves", "success")
+
+		// Act
+		database.Create(&shelf{Label: "fiction"})
+		database.First(&shelf{}, "label = ?", "fiction")
+		database.First(&shelf{}, "label = ?", "missing")
+
+		// Assert
+		assert.Equal(creates+1, samples("create", "shelves", "success"))
+		assert.Equal(queries+2, samples("query", "shelves", "success"))
+	})
+
+	test.Run("Should flag the statements that failed", func(test *testing.T) {
+		// Arrange
+		failures := samples("raw", "unknown", "error")
+
+		// Act
+		database.Exec("THIS IS NOT SQL")
+
+		// Assert
+		assert.Equal(failures+1, samples("raw", "unknown", "error"))
+	})
+}
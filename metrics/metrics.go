@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of the HTTP requests by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DatabaseQueries = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "database_query_duration_seconds",
+		Help:    "Latency of the database statements by operation, table and outcome.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table", "outcome"})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logins_total",
+		Help: "Number of login attempts by result.",
+	}, []string{"result"})
+
+	Checkouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "checkouts_total",
+		Help: "Number of book checkouts by result.",
+	}, []string{"result"})
+
+	StockOuts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stock_out_events_total",
+		Help: "Number of times a book ran out of stock.",
+	})
+
+	LowStockEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "low_stock_events_total",
+		Help: "Number of times a checkout took a book below its reorder point.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		DatabaseQueries,
+		Logins,
+		Checkouts,
+		StockOuts,
+		LowStockEvents,
+	)
+}
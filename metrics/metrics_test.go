@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should expose the Go runtime and process metrics", func(test *testing.T) {
+		// Act
+		families, exception := Registry.Gather()
+
+		// Assert
+		assert.Nil(exception)
+		names := []string{}
+		for _, family := range families {
+			names = append(names, family.GetName())
+		}
+		assert.Contains(names, "go_goroutines")
+		assert.Contains(names, "go_memstats_alloc_bytes")
+	})
+}
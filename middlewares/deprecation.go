@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Deprecation struct {
+	// Since is when the end-points were deprecated
+	Since time.Time
+	// Sunset is when the end-points will stop answering
+	Sunset time.Time
+	// Successor is the prefix of the version replacing them, e.g. /v1
+	Successor string
+}
+
+// Deprecated marks the responses with the Deprecation (RFC 9745) and Sunset (RFC 8594) headers and links the successor
+func Deprecated(deprecation Deprecation) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Header("Deprecation", fmt.Sprintf("@%d", deprecation.Since.Unix()))
+		if !deprecation.Sunset.IsZero() {
+			context.Header("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+		}
+
+		if deprecation.Successor != "" {
+			successor := deprecation.Successor + context.Request.URL.Path
+			context.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		}
+
+		context.Next()
+	}
+}
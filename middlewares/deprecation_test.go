@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecated(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	since := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+
+	test.Run("Should announce the deprecation, the sunset and the successor", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		server.GET("/books/:id", Deprecated(Deprecation{Since: since, Sunset: sunset, Successor: "/v1"}), func(context *gin.Context) {
+			context.Status(http.StatusNoContent)
+		})
+		request, _ := http.NewRequest(http.MethodGet, "/books/7", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.Equal("@1792368000", recorder.Header().Get("Deprecation"))
+		assert.Equal("Mon, 19 Apr 2027 00:00:00 GMT", recorder.Header().Get("Sunset"))
+		assert.Equal(`</v1/books/7>; rel="successor-version"`, recorder.Header().Get("Link"))
+	})
+
+	test.Run("Should only announce the deprecation when there is no sunset nor successor", func(test *testing.T) {
+		// Arrange
+		server := gin.New()
+		server.GET("/books", Deprecated(Deprecation{Since: since}), func(context *gin.Context) {
+			context.Status(http.StatusNoContent)
+		})
+		request, _ := http.NewRequest(http.MethodGet, "/books", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		assert.Equal("@1792368000", recorder.Header().Get("Deprecation"))
+		assert.Empty(recorder.Header().Get("Sunset"))
+		assert.Empty(recorder.Header().Get("Link"))
+	})
+}
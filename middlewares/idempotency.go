@@ -0,0 +1,202 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"gorm.io/gorm"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// The headers of the first answer which are replayed along with its status and body
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *recordingWriter) Write(data []byte) (int, error) {
+	writer.body.Write(data)
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *recordingWriter) WriteString(data string) (int, error) {
+	writer.body.WriteString(data)
+	return writer.ResponseWriter.WriteString(data)
+}
+
+// Idempotency answers the retries of a request carrying an Idempotency-Key with its first answer, as long as they come
+// from the same client with the same payload within the window, so the handler only runs once. The clients are the
+// users or, for the anonymous ones, the token of their cookie, and the anonymous requests without it are only told
+// apart by their fingerprint, so just the retries of the same request share their key
+func Idempotency(database *gorm.DB, window time.Duration, cookie string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := context.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			context.Next()
+			return
+		}
+
+		if len(key) > 191 {
+			problem := problems.ErrValidationFailed.WithDetail("The idempotency key is too long")
+			problem.Errors = []problems.FieldError{{Field: IdempotencyKeyHeader, Rule: "max", Message: "must be at most 191 characters long"}}
+			problems.Abort(context, problem)
+			return
+		}
+
+		// The fingerprint identifies the request by its method, path and payload
+		payload, exception := io.ReadAll(context.Request.Body)
+		if exception != nil {
+			problems.Abort(context, problems.ErrMalformedRequest.Wrap(exception))
+			return
+		}
+		context.Request.Body = io.NopCloser(bytes.NewReader(payload))
+		hash := sha256.New()
+		hash.Write([]byte(context.Request.Method + " " + context.Request.URL.Path + "\n"))
+		hash.Write(payload)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		userID, client := clientOf(context, cookie)
+		if userID == 0 && client == "" {
+			client = "request:" + fingerprint
+		}
+
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Client:      client,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(window),
+		}
+
+		scoped := database.WithContext(context.Request.Context())
+		existing, exception := claim(scoped, record)
+		switch {
+		case exception != nil:
+			problems.Abort(context, exception)
+			return
+		case existing == nil:
+			break
+		case existing.Fingerprint != record.Fingerprint:
+			problems.Abort(context, problems.ErrIdempotencyReused)
+			return
+		case !existing.Completed():
+			problems.Abort(context, problems.ErrIdempotencyBusy)
+			return
+		default:
+			replay(context, existing)
+			return
+		}
+
+		// The key is released when the handler panics or the server fails, so the client can try again with it
+		defer func() {
+			if failure := recover(); failure != nil {
+				scoped.Delete(record)
+				panic(failure)
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: context.Writer}
+		context.Writer = writer
+		context.Next()
+
+		// The problems are answered here instead of the outer middleware to record them too
+		if len(context.Errors) > 0 && !context.Writer.Written() {
+			problems.Respond(context, problems.From(context.Errors.Last().Err))
+		}
+
+		if writer.Status() >= http.StatusInternalServerError {
+			scoped.Delete(record)
+			return
+		}
+
+		headers := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := writer.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		encoded, _ := json.Marshal(headers)
+		record.Status = writer.Status()
+		record.Headers = string(encoded)
+		record.Body = writer.body.String()
+		if exception := scoped.Select("status", "headers", "body").Updates(record).Error; exception != nil {
+			logging.FromContext(context.Request.Context()).Error("Failed to store the idempotent answer", "error", exception.Error())
+		}
+	}
+}
+
+// clientOf gives the user of the request or a digest of the token of the cookie of the anonymous client, so the token
+// itself isn't stored
+func clientOf(context *gin.Context, cookie string) (int, string) {
+	if user, ok := context.Get("user"); ok {
+		if user, ok := user.(*models.User); ok && user != nil {
+			return user.ID, ""
+		}
+	}
+
+	token, exception := context.Cookie(cookie)
+	if exception != nil || token == "" {
+		return 0, ""
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	return 0, hex.EncodeToString(digest[:])
+}
+
+// claim takes the key for the request, or gives the record of the request which took it first while it hasn't expired
+func claim(database *gorm.DB, record *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		exception := database.Create(record).Error
+		if exception == nil {
+			return nil, nil
+		}
+
+		if !problems.Duplicated(exception) {
+			return nil, exception
+		}
+
+		existing := &models.IdempotencyKey{}
+		lookup := database.Where("user_id = ? AND client = ? AND idempotency_key = ?", record.UserID, record.Client, record.Key).First(existing).Error
+		if errors.Is(lookup, gorm.ErrRecordNotFound) {
+			continue
+		} else if lookup != nil {
+			return nil, lookup
+		}
+
+		if existing.ExpiresAt.After(time.Now()) {
+			return existing, nil
+		}
+
+		// The window of the key closed, so it can be taken again
+		if exception := database.Delete(existing).Error; exception != nil {
+			return nil, exception
+		}
+	}
+
+	return nil, problems.ErrIdempotencyBusy
+}
+
+func replay(context *gin.Context, record *models.IdempotencyKey) {
+	headers := map[string]string{}
+	json.Unmarshal([]byte(record.Headers), &headers)
+	for name, value := range headers {
+		context.Header(name, value)
+	}
+
+	context.Header("Idempotent-Replayed", "true")
+	context.Data(record.Status, headers["Content-Type"], []byte(record.Body))
+	context.Abort()
+}
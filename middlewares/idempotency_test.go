@@ -0,0 +1,231 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/migrations/migrationstest"
+	"github.com/zatarain/bookshop/models"
+	"github.com/zatarain/bookshop/problems"
+	"gorm.io/gorm"
+)
+
+func setupIdempotency(test *testing.T) (*gin.Engine, *gorm.DB, *int) {
+	gin.SetMode(gin.TestMode)
+	database := migrationstest.Migrated(test)
+
+	calls := 0
+	server := gin.New()
+	server.Use(Problems(), Recovery(), func(context *gin.Context) {
+		if nickname := context.GetHeader("X-User"); nickname != "" {
+			context.Set("user", &models.User{ID: len(nickname), Nickname: nickname})
+		}
+	}, Idempotency(database, time.Hour, "Cart"))
+	server.POST("/books/:id/checkout", func(context *gin.Context) {
+		calls++
+		context.Header("ETag", `"1-2"`)
+		context.JSON(http.StatusOK, gin.H{"quantity": 10 - calls})
+	})
+	server.POST("/out-of-stock", func(context *gin.Context) {
+		calls++
+		problems.Abort(context, problems.ErrOutOfStock)
+	})
+	server.POST("/failure", func(context *gin.Context) {
+		calls++
+		problems.Abort(context, errors.New("connection refused"))
+	})
+	server.POST("/panic", func(context *gin.Context) {
+		calls++
+		panic("unexpected")
+	})
+	return server, database, &calls
+}
+
+func send(server *gin.Engine, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for index := 0; index+1 < len(headers); index += 2 {
+		request.Header.Set(headers[index], headers[index+1])
+	}
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotency(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should run every request without idempotency key", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+
+		// Act
+		send(server, "/books/1/checkout", "")
+		recorder := send(server, "/books/1/checkout", "")
+
+		// Assert
+		assert.Equal(2, *calls)
+		assert.JSONEq(`{"quantity": 8}`, recorder.Body.String())
+	})
+
+	test.Run("Should replay the first answer to the retries", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+		first := send(server, "/books/1/checkout", `{}`, IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Act
+		retry := send(server, "/books/1/checkout", `{}`, IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Assert
+		assert.Equal(1, *calls)
+		assert.Equal(http.StatusOK, retry.Code)
+		assert.Equal(first.Body.String(), retry.Body.String())
+		assert.Equal(first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+		assert.Equal(`"1-2"`, retry.Header().Get("ETag"))
+		assert.Equal("true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Empty(first.Header().Get("Idempotent-Replayed"))
+	})
+
+	test.Run("Should keep the keys of every user apart", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+		send(server, "/books/1/checkout", `{}`, IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Act
+		recorder := send(server, "/books/1/checkout", `{}`, IdempotencyKeyHeader, "dummy-key", "X-User", "bob")
+
+		// Assert
+		assert.Equal(2, *calls)
+		assert.Empty(recorder.Header().Get("Idempotent-Replayed"))
+	})
+
+	test.Run("Should keep the keys of every anonymous client apart by their cookie", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+		first := send(server, "/books/1/checkout", `{}`, IdempotencyKeyHeader, "dummy-key", "Cookie", "Cart=first-token")
+
+		// Act
+		retry := send(server, "/books/1/checkout", `{}`, IdempotencyKeyHeader, "dummy-key", "Cookie", "Cart=first-token")
+		other := send(server, "/books/1/checkout", `{}`, IdempotencyKeyHeader, "dummy-key", "Cookie", "Cart=other-token")
+
+		// Assert
+		assert.Equal(2, *calls)
+		assert.Equal(first.Body.String(), retry.Body.String())
+		assert.Equal("true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Empty(other.Header().Get("Idempotent-Replayed"))
+		assert.JSONEq(`{"quantity": 8}`, other.Body.String())
+	})
+
+	test.Run("Should keep the keys of the anonymous clients without cookie apart by their request", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+		first := send(server, "/books/1/checkout", `{"copies": 1}`, IdempotencyKeyHeader, "dummy-key")
+
+		// Act
+		retry := send(server, "/books/1/checkout", `{"copies": 1}`, IdempotencyKeyHeader, "dummy-key")
+		other := send(server, "/books/1/checkout", `{"copies": 2}`, IdempotencyKeyHeader, "dummy-key")
+
+		// Assert
+		assert.Equal(2, *calls)
+		assert.Equal(first.Body.String(), retry.Body.String())
+		assert.Equal("true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Equal(http.StatusOK, other.Code)
+		assert.Empty(other.Header().Get("Idempotent-Replayed"))
+	})
+
+	test.Run("Should reject the key reused with another payload", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+		send(server, "/books/1/checkout", `{"copies": 1}`, IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Act
+		other := send(server, "/books/1/checkout", `{"copies": 2}`, IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+		path := send(server, "/books/2/checkout", `{"copies": 1}`, IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Assert
+		assert.Equal(1, *calls)
+		assert.Equal(http.StatusUnprocessableEntity, other.Code)
+		assert.Contains(other.Body.String(), `"code":"idempotency_key_reused"`)
+		assert.Equal(http.StatusUnprocessableEntity, path.Code)
+	})
+
+	test.Run("Should replay the problems of the client", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+		send(server, "/out-of-stock", "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Act
+		retry := send(server, "/out-of-stock", "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Assert
+		assert.Equal(1, *calls)
+		assert.Equal(http.StatusConflict, retry.Code)
+		assert.Equal(problems.ContentType, retry.Header().Get("Content-Type"))
+		assert.Contains(retry.Body.String(), `"code":"out_of_stock"`)
+	})
+
+	for _, path := range []string{"/failure", "/panic"} {
+		test.Run("Should release the key when the server fails on "+path, func(test *testing.T) {
+			// Arrange
+			server, database, calls := setupIdempotency(test)
+			send(server, path, "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+			// Act
+			retry := send(server, path, "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+			// Assert
+			var count int64
+			database.Model(&models.IdempotencyKey{}).Count(&count)
+			assert.Equal(2, *calls)
+			assert.Equal(http.StatusInternalServerError, retry.Code)
+			assert.Zero(count)
+		})
+	}
+
+	test.Run("Should answer conflict while the first request is being processed", func(test *testing.T) {
+		// Arrange
+		server, database, calls := setupIdempotency(test)
+		send(server, "/books/1/checkout", "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+		database.Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", "dummy-key").Update("status", 0)
+
+		// Act
+		retry := send(server, "/books/1/checkout", "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Assert
+		assert.Equal(1, *calls)
+		assert.Equal(http.StatusConflict, retry.Code)
+		assert.Contains(retry.Body.String(), `"code":"idempotency_in_progress"`)
+	})
+
+	test.Run("Should run the request again once the key expired", func(test *testing.T) {
+		// Arrange
+		server, database, calls := setupIdempotency(test)
+		send(server, "/books/1/checkout", "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+		database.Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", "dummy-key").Update("expires_at", time.Now().Add(-time.Minute))
+
+		// Act
+		retry := send(server, "/books/1/checkout", "", IdempotencyKeyHeader, "dummy-key", "X-User", "alice")
+
+		// Assert
+		assert.Equal(2, *calls)
+		assert.Empty(retry.Header().Get("Idempotent-Replayed"))
+	})
+
+	test.Run("Should reject a key too long", func(test *testing.T) {
+		// Arrange
+		server, _, calls := setupIdempotency(test)
+
+		// Act
+		recorder := send(server, "/books/1/checkout", "", IdempotencyKeyHeader, strings.Repeat("k", 192), "X-User", "alice")
+
+		// Assert
+		assert.Zero(*calls)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+}
@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+func NewRequestID() string {
+	var random [16]byte
+	rand.Read(random[:])
+
+	// Format as version 4 UUID
+	random[6] = (random[6] & 0x0f) | 0x40
+	random[8] = (random[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", random[0:4], random[4:6], random[6:8], random[8:10], random[10:])
+}
+
+func RequestID(context *gin.Context) string {
+	return context.GetString("request_id")
+}
+
+// Logger propagates or generates the request ID, attaches a per-request logger and logs every request
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		identifier := context.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(identifier) {
+			identifier = NewRequestID()
+		}
+
+		context.Set("request_id", identifier)
+		context.Header(RequestIDHeader, identifier)
+		scoped := logger.With(slog.String("request_id", identifier))
+		if span := trace.SpanContextFromContext(context.Request.Context()); span.IsValid() {
+			scoped = scoped.With(slog.String("trace_id", span.TraceID().String()))
+		}
+		context.Request = context.Request.WithContext(logging.WithLogger(context.Request.Context(), scoped))
+
+		context.Next()
+
+		route := context.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		attributes := []slog.Attr{
+			slog.String("method", context.Request.Method),
+			slog.String("route", route),
+			slog.String("path", context.Request.URL.Path),
+			slog.Int("status", context.Writer.Status()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", context.ClientIP()),
+			slog.Int("size", context.Writer.Size()),
+		}
+
+		if user, ok := context.Value("user").(*models.User); ok && user != nil {
+			attributes = append(attributes, slog.Int("user_id", user.ID))
+		}
+
+		if len(context.Errors) > 0 {
+			attributes = append(attributes, slog.String("errors", context.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		switch status := context.Writer.Status(); {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		scoped.LogAttrs(context.Request.Context(), level, "request", attributes...)
+	}
+}
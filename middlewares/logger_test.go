@@ -0,0 +1,134 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func lines(output *bytes.Buffer) []map[string]any {
+	result := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		entry := map[string]any{}
+		json.Unmarshal([]byte(line), &entry)
+		result = append(result, entry)
+	}
+
+	return result
+}
+
+func TestLogger(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+
+	setup := func(output *bytes.Buffer) *gin.Engine {
+		server := gin.New()
+		server.Use(Logger(logging.New(output, slog.LevelDebug, "json")))
+		server.GET("/books/:id", func(context *gin.Context) {
+			context.Set("user", &models.User{ID: 12345})
+			logging.FromContext(context.Request.Context()).Info("Inside the handler")
+			context.String(http.StatusOK, "Book")
+		})
+		server.POST("/login", func(context *gin.Context) {
+			context.String(http.StatusBadRequest, "Invalid nickname or password")
+		})
+		return server
+	}
+
+	test.Run("Should generate a request ID and log the request", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		server := setup(&output)
+		request, _ := http.NewRequest(http.MethodGet, "/books/7", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		identifier := recorder.Header().Get(RequestIDHeader)
+		assert.Regexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, identifier)
+		entries := lines(&output)
+		assert.Len(entries, 2)
+		assert.Equal("Inside the handler", entries[0]["msg"])
+		assert.Equal(identifier, entries[0]["request_id"])
+		assert.Equal("request", entries[1]["msg"])
+		assert.Equal("INFO", entries[1]["level"])
+		assert.Equal(identifier, entries[1]["request_id"])
+		assert.Equal("/books/:id", entries[1]["route"])
+		assert.Equal("/books/7", entries[1]["path"])
+		assert.Equal(float64(http.StatusOK), entries[1]["status"])
+		assert.Equal(float64(12345), entries[1]["user_id"])
+		assert.Contains(entries[1], "latency_ms")
+	})
+
+	test.Run("Should propagate the request ID given by the client", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		server := setup(&output)
+		request, _ := http.NewRequest(http.MethodPost, "/login", nil)
+		request.Header.Set(RequestIDHeader, "upstream-request-42")
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		entries := lines(&output)
+		assert.Equal("upstream-request-42", recorder.Header().Get(RequestIDHeader))
+		assert.Equal("upstream-request-42", entries[0]["request_id"])
+		assert.Equal("WARN", entries[0]["level"])
+		assert.NotContains(entries[0], "user_id")
+	})
+
+	test.Run("Should replace an unsafe request ID", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		server := setup(&output)
+		request, _ := http.NewRequest(http.MethodGet, "/nowhere", nil)
+		request.Header.Set(RequestIDHeader, "fake\nlog line")
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		entries := lines(&output)
+		assert.NotEqual("fake\nlog line", recorder.Header().Get(RequestIDHeader))
+		assert.Equal("unmatched", entries[0]["route"])
+		assert.Equal(float64(http.StatusNotFound), entries[0]["status"])
+	})
+
+	test.Run("Should attach the trace ID when the request is traced", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		server := gin.New()
+		server.Use(Tracing(), Logger(logging.New(&output, slog.LevelDebug, "json")))
+		server.GET("/books/:id", func(context *gin.Context) {
+			context.String(http.StatusOK, "Book")
+		})
+		request, _ := http.NewRequest(http.MethodGet, "/books/7", nil)
+		request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		// Act
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		// Assert
+		entries := lines(&output)
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", entries[0]["trace_id"])
+	})
+}
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/metrics"
+)
+
+// Metrics counts and times the requests by route template to keep the cardinality bounded
+func Metrics() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		route := context.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(context.Writer.Status())
+		method := context.Request.Method
+		metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
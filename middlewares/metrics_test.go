@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/metrics"
+)
+
+func TestMetrics(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(Metrics())
+	server.GET("/books/:id", func(context *gin.Context) {
+		context.String(http.StatusOK, "Book")
+	})
+
+	test.Run("Should count the requests by route template", func(test *testing.T) {
+		// Arrange
+		counter := metrics.HTTPRequests.WithLabelValues("GET", "/books/:id", "200")
+		before := testutil.ToFloat64(counter)
+
+		// Act
+		for _, path := range []string{"/books/1", "/books/2"} {
+			request, _ := http.NewRequest(http.MethodGet, path, nil)
+			server.ServeHTTP(httptest.NewRecorder(), request)
+		}
+
+		// Assert
+		assert.Equal(before+2, testutil.ToFloat64(counter))
+	})
+
+	test.Run("Should group the unmatched requests together", func(test *testing.T) {
+		// Arrange
+		counter := metrics.HTTPRequests.WithLabelValues("GET", "unmatched", "404")
+		before := testutil.ToFloat64(counter)
+
+		// Act
+		request, _ := http.NewRequest(http.MethodGet, "/random/path", nil)
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		// Assert
+		assert.Equal(before+1, testutil.ToFloat64(counter))
+	})
+}
@@ -0,0 +1,19 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/problems"
+)
+
+// Problems answers the last error recorded by the handlers as problem details unless they already answered
+func Problems() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Next()
+
+		if len(context.Errors) == 0 || context.Writer.Written() {
+			return
+		}
+
+		problems.Respond(context, problems.From(context.Errors.Last().Err))
+	}
+}
@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/problems"
+)
+
+func TestProblems(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(func(context *gin.Context) {
+		context.Set("request_id", "dummy-request")
+	}, Problems())
+	server.GET("/books/:id", func(context *gin.Context) {
+		problems.Abort(context, problems.ErrNotFound.WithDetail("The book was not found"))
+	})
+	server.GET("/failure", func(context *gin.Context) {
+		problems.Abort(context, errors.New("connection refused by 10.0.0.7"))
+	})
+	server.GET("/answered", func(context *gin.Context) {
+		context.Error(errors.New("Already handled"))
+		context.String(http.StatusAccepted, "Accepted")
+	})
+
+	test.Run("Should answer the problem details with the correlation ID", func(test *testing.T) {
+		// Arrange
+		request, _ := http.NewRequest(http.MethodGet, "/books/7", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		var problem problems.Problem
+		json.Unmarshal(recorder.Body.Bytes(), &problem)
+		assert.Equal(http.StatusNotFound, recorder.Code)
+		assert.Equal(problems.ContentType, recorder.Header().Get("Content-Type"))
+		assert.Equal(problems.Problem{
+			Type:      "urn:bookshop:problem:not_found",
+			Title:     "Not Found",
+			Status:    http.StatusNotFound,
+			Detail:    "The book was not found",
+			Instance:  "/books/7",
+			Code:      "not_found",
+			RequestID: "dummy-request",
+		}, problem)
+	})
+
+	test.Run("Should hide the internal errors", func(test *testing.T) {
+		// Arrange
+		request, _ := http.NewRequest(http.MethodGet, "/failure", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		assert.Equal(http.StatusInternalServerError, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"code":"internal_error"`)
+		assert.NotContains(recorder.Body.String(), "10.0.0.7")
+	})
+
+	test.Run("Should NOT overwrite a response already written", func(test *testing.T) {
+		// Arrange
+		request, _ := http.NewRequest(http.MethodGet, "/answered", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		assert.Equal(http.StatusAccepted, recorder.Code)
+		assert.Equal("Accepted", recorder.Body.String())
+	})
+}
@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/problems"
+)
+
+// Recovery logs the panics of the handlers with the request logger and answers an internal error problem
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(context *gin.Context, failure any) {
+		logging.FromContext(context.Request.Context()).Error(
+			"Recovered from panic",
+			"panic", failure,
+			"stack", string(debug.Stack()),
+		)
+		problems.Respond(context, problems.ErrInternal)
+	})
+}
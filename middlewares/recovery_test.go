@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/logging"
+	"github.com/zatarain/bookshop/problems"
+)
+
+func TestRecovery(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+
+	test.Run("Should log the panic and answer internal server error", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		server := gin.New()
+		server.Use(Logger(logging.New(&output, slog.LevelInfo, "json")), Recovery())
+		server.GET("/panic", func(*gin.Context) {
+			panic("Something went wrong")
+		})
+		request, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+		recorder := httptest.NewRecorder()
+
+		// Act
+		server.ServeHTTP(recorder, request)
+
+		// Assert
+		entries := lines(&output)
+		assert.Equal(http.StatusInternalServerError, recorder.Code)
+		assert.Equal(problems.ContentType, recorder.Header().Get("Content-Type"))
+		assert.Contains(recorder.Body.String(), `"code":"internal_error"`)
+		assert.NotContains(recorder.Body.String(), "Something went wrong")
+		assert.Equal("Recovered from panic", entries[0]["msg"])
+		assert.Equal("Something went wrong", entries[0]["panic"])
+		assert.Equal(entries[0]["request_id"], entries[1]["request_id"])
+		assert.Equal("ERROR", entries[1]["level"])
+	})
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zatarain/bookshop/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for every request continuing the trace given by the traceparent header
+func Tracing() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		parent := otel.GetTextMapPropagator().Extract(
+			context.Request.Context(),
+			propagation.HeaderCarrier(context.Request.Header),
+		)
+
+		route := context.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		scoped, span := tracing.Tracer().Start(
+			parent,
+			context.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(context.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(context.Request.URL.Path),
+				semconv.ClientAddress(context.ClientIP()),
+			),
+		)
+		defer span.End()
+		context.Request = context.Request.WithContext(scoped)
+
+		context.Next()
+
+		status := context.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+
+		for _, exception := range context.Errors {
+			span.RecordError(exception.Err)
+		}
+	}
+}
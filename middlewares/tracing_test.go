@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(test *testing.T) {
+	assert := assert.New(test)
+	gin.SetMode(gin.TestMode)
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	server := gin.New()
+	server.Use(Tracing())
+	var current trace.SpanContext
+	server.GET("/books/:id", func(context *gin.Context) {
+		current = trace.SpanContextFromContext(context.Request.Context())
+		context.String(http.StatusOK, "Book")
+	})
+	server.GET("/failure", func(context *gin.Context) {
+		context.String(http.StatusInternalServerError, "Failure")
+	})
+
+	test.Run("Should continue the trace given by the traceparent header", func(test *testing.T) {
+		// Arrange
+		exporter.Reset()
+		request, _ := http.NewRequest(http.MethodGet, "/books/1", nil)
+		request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		// Act
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		// Assert
+		spans := exporter.GetSpans()
+		require.Len(test, spans, 1)
+		assert.Equal("GET /books/:id", spans[0].Name)
+		assert.Equal(trace.SpanKindServer, spans[0].SpanKind)
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+		assert.Equal("00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+		assert.Equal(spans[0].SpanContext.SpanID(), current.SpanID())
+	})
+
+	test.Run("Should start a new trace without traceparent header", func(test *testing.T) {
+		// Arrange
+		exporter.Reset()
+		request, _ := http.NewRequest(http.MethodGet, "/books/2", nil)
+
+		// Act
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		// Assert
+		spans := exporter.GetSpans()
+		require.Len(test, spans, 1)
+		assert.True(spans[0].SpanContext.IsValid())
+		assert.False(spans[0].Parent.IsValid())
+	})
+
+	test.Run("Should flag the requests that failed", func(test *testing.T) {
+		// Arrange
+		exporter.Reset()
+		request, _ := http.NewRequest(http.MethodGet, "/failure", nil)
+
+		// Act
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		// Assert
+		spans := exporter.GetSpans()
+		require.Len(test, spans, 1)
+		assert.Equal(codes.Error, spans[0].Status.Code)
+	})
+}
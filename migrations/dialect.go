@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var types = map[string]map[string]string{
+	"sqlite": {
+		"identity":  "integer PRIMARY KEY",
+		"reference": "integer",
+		"integer":   "integer",
+		"real":      "real",
+		"boolean":   "numeric",
+		"string":    "text",
+		"text":      "text",
+		"timestamp": "datetime",
+	},
+	"postgres": {
+		"identity":  "bigserial PRIMARY KEY",
+		"reference": "bigint",
+		"integer":   "bigint",
+		"real":      "real",
+		"boolean":   "boolean",
+		"string":    "text",
+		"text":      "text",
+		"timestamp": "timestamptz",
+	},
+	"mysql": {
+		"identity":  "bigint unsigned AUTO_INCREMENT PRIMARY KEY",
+		"reference": "bigint unsigned",
+		"integer":   "bigint",
+		"real":      "float",
+		"boolean":   "boolean",
+		"string":    "varchar(191)",
+		"text":      "longtext",
+		"timestamp": "datetime(3)",
+	},
+}
+
+func functions(dialect string) template.FuncMap {
+	functions := template.FuncMap{
+		"dialect": func() string { return dialect },
+		"createIndex": func(name string, table string, columns ...string) string {
+			// MySQL has no IF NOT EXISTS clause for indexes
+			if dialect == "mysql" {
+				return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+			}
+			return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+		},
+		"createUniqueIndex": func(name string, table string, columns ...string) string {
+			if dialect == "mysql" {
+				return fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+			}
+			return fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+		},
+		"dropIndex": func(name string, table string) string {
+			if dialect == "mysql" {
+				return fmt.Sprintf("DROP INDEX %s ON %s", name, table)
+			}
+			return fmt.Sprintf("DROP INDEX IF EXISTS %s", name)
+		},
+		"addInterval": func(timestamp string, interval time.Duration) string {
+			seconds := int64(interval / time.Second)
+			switch dialect {
+			case "mysql":
+				return fmt.Sprintf("DATE_ADD(%s, INTERVAL %d SECOND)", timestamp, seconds)
+			case "postgres":
+				return fmt.Sprintf("(%s + INTERVAL '%d seconds')", timestamp, seconds)
+			}
+			return fmt.Sprintf("datetime(%s, '%+d seconds')", timestamp, seconds)
+		},
+	}
+
+	for name, sqlType := range types[dialect] {
+		sqlType := sqlType
+		functions[name] = func() string { return sqlType }
+	}
+
+	return functions
+}
+
+func Render(script string, dialect string, settings Settings) (string, error) {
+	if _, supported := types[dialect]; !supported {
+		return "", fmt.Errorf("unsupported migration dialect: %s", dialect)
+	}
+
+	parsed, exception := template.New("migration").Funcs(functions(dialect)).Parse(script)
+	if exception != nil {
+		return "", exception
+	}
+
+	var rendered strings.Builder
+	if exception := parsed.Execute(&rendered, settings); exception != nil {
+		return "", exception
+	}
+
+	return rendered.String(), nil
+}
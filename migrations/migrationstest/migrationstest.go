@@ -0,0 +1,25 @@
+package migrationstest
+
+import (
+	"testing"
+
+	"github.com/zatarain/bookshop/databasetest"
+	"github.com/zatarain/bookshop/migrations"
+	"gorm.io/gorm"
+)
+
+// Migrated opens a fresh SQLite database with every migration applied. It lives apart from databasetest because the
+// tests of the migrations use that one
+func Migrated(test *testing.T) *gorm.DB {
+	database := databasetest.SQLite(test)
+	migrator, exception := migrations.New(database)
+	if exception != nil {
+		test.Fatal(exception)
+	}
+
+	if _, exception := migrator.Up(); exception != nil {
+		test.Fatal(exception)
+	}
+
+	return database
+}
@@ -0,0 +1,333 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var ErrNotMigrated = errors.New("the database was never migrated")
+
+var filenamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+type SchemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Settings are the values of the configuration the scripts can read, e.g. {{.ReservationTime}}
+type Settings struct {
+	ReservationTime time.Duration
+}
+
+type Migrator struct {
+	Database   *gorm.DB
+	Migrations []Migration
+	Settings   Settings
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+func New(database *gorm.DB) (*Migrator, error) {
+	migrations, exception := Load(files, "sql")
+	if exception != nil {
+		return nil, exception
+	}
+
+	return &Migrator{Database: database, Migrations: migrations, Settings: Settings{ReservationTime: 15 * time.Minute}}, nil
+}
+
+func Load(source fs.FS, directory string) ([]Migration, error) {
+	entries, exception := fs.ReadDir(source, directory)
+	if exception != nil {
+		return nil, exception
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		matches := filenamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseUint(matches[1], 10, 32)
+		content, exception := fs.ReadFile(source, path.Join(directory, entry.Name()))
+		if exception != nil {
+			return nil, exception
+		}
+
+		migration, exists := byVersion[uint(version)]
+		if !exists {
+			migration = &Migration{Version: uint(version), Name: matches[2]}
+			byVersion[uint(version)] = migration
+		}
+
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// statements splits a script at the semicolons ending its statements, leaving alone the ones within quotes, comments
+// and dollar-quoted bodies. A trigger or procedure can be wrapped between `-- +begin` and `-- +end` lines to run it as
+// a single statement with every semicolon of its body
+func statements(script string) []string {
+	result := []string{}
+	add := func(statement string) {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			result = append(result, statement)
+		}
+	}
+
+	start, block := 0, false
+	for index := 0; index < len(script); index++ {
+		switch rest := script[index:]; {
+		case strings.HasPrefix(rest, "-- +begin"):
+			block = true
+			index = skipLine(script, index)
+		case strings.HasPrefix(rest, "-- +end"):
+			block = false
+			add(script[start:index])
+			index = skipLine(script, index)
+			start = index
+		case strings.HasPrefix(rest, "--"):
+			index = skipLine(script, index)
+		case strings.HasPrefix(rest, "/*"):
+			index = skipPast(script, index+2, "*/")
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+			index = skipPast(script, index+1, rest[:1])
+		case rest[0] == '$':
+			if tag := dollarTag.FindString(rest); tag != "" {
+				index = skipPast(script, index+len(tag), tag)
+			}
+		case rest[0] == ';' && !block:
+			add(script[start:index])
+			start = index + 1
+		}
+	}
+	add(script[start:])
+
+	return result
+}
+
+var dollarTag = regexp.MustCompile(`^\$\w*\$`)
+
+// skipLine gives the position of the end of the line of the index
+func skipLine(script string, index int) int {
+	if end := strings.IndexByte(script[index:], '\n'); end >= 0 {
+		return index + end
+	}
+
+	return len(script)
+}
+
+// skipPast gives the position of the last character of the delimiter closing what starts at the index, where a doubled
+// quote is an escaped one
+func skipPast(script string, index int, delimiter string) int {
+	for {
+		end := strings.Index(script[index:], delimiter)
+		if end < 0 {
+			return len(script)
+		}
+
+		index += end + len(delimiter)
+		if len(delimiter) > 1 || !strings.HasPrefix(script[index:], delimiter) {
+			return index - 1
+		}
+		index++
+	}
+}
+
+func (migrator *Migrator) execute(database *gorm.DB, script string) error {
+	rendered, exception := Render(script, database.Dialector.Name(), migrator.Settings)
+	if exception != nil {
+		return exception
+	}
+
+	for _, statement := range statements(rendered) {
+		if exception := database.Exec(statement).Error; exception != nil {
+			return exception
+		}
+	}
+
+	return nil
+}
+
+func (migrator *Migrator) applied() (map[uint]SchemaMigration, error) {
+	if exception := migrator.Database.AutoMigrate(&SchemaMigration{}); exception != nil {
+		return nil, exception
+	}
+
+	return migrator.records()
+}
+
+func (migrator *Migrator) records() (map[uint]SchemaMigration, error) {
+	records := []SchemaMigration{}
+	if exception := migrator.Database.Order("version").Find(&records).Error; exception != nil {
+		return nil, exception
+	}
+
+	applied := map[uint]SchemaMigration{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+func (migrator *Migrator) Up() ([]Migration, error) {
+	applied, exception := migrator.applied()
+	if exception != nil {
+		return nil, exception
+	}
+
+	done := []Migration{}
+	for _, migration := range migrator.Migrations {
+		if _, exists := applied[migration.Version]; exists {
+			continue
+		}
+
+		// Run the script and record it within the same transaction
+		exception := migrator.Database.Transaction(func(transaction *gorm.DB) error {
+			if exception := migrator.execute(transaction, migration.Up); exception != nil {
+				return exception
+			}
+
+			return transaction.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+
+		if exception != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, exception)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func (migrator *Migrator) Down(steps int) ([]Migration, error) {
+	applied, exception := migrator.applied()
+	if exception != nil {
+		return nil, exception
+	}
+
+	done := []Migration{}
+	for index := len(migrator.Migrations) - 1; index >= 0 && len(done) < steps; index-- {
+		migration := migrator.Migrations[index]
+		if _, exists := applied[migration.Version]; !exists {
+			continue
+		}
+
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %d_%s is irreversible", migration.Version, migration.Name)
+		}
+
+		// Revert the script and forget it within the same transaction
+		exception := migrator.Database.Transaction(func(transaction *gorm.DB) error {
+			if exception := migrator.execute(transaction, migration.Down); exception != nil {
+				return exception
+			}
+
+			return transaction.Delete(&SchemaMigration{Version: migration.Version}).Error
+		})
+
+		if exception != nil {
+			return done, fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, exception)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func (migrator *Migrator) Status() ([]Status, error) {
+	applied, exception := migrator.applied()
+	if exception != nil {
+		return nil, exception
+	}
+
+	return migrator.statuses(applied), nil
+}
+
+// Inspect tells the status of the migrations like Status but only reading the schema, so it fails with
+// ErrNotMigrated instead of creating the schema_migrations table when it doesn't exist yet
+func (migrator *Migrator) Inspect() ([]Status, error) {
+	if !migrator.Database.Migrator().HasTable(&SchemaMigration{}) {
+		return nil, ErrNotMigrated
+	}
+
+	applied, exception := migrator.records()
+	if exception != nil {
+		return nil, exception
+	}
+
+	return migrator.statuses(applied), nil
+}
+
+func (migrator *Migrator) statuses(applied map[uint]SchemaMigration) []Status {
+	statuses := make([]Status, 0, len(migrator.Migrations))
+	for _, migration := range migrator.Migrations {
+		record, exists := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Migration: migration,
+			Applied:   exists,
+			AppliedAt: record.AppliedAt,
+		})
+	}
+
+	return statuses
+}
@@ -0,0 +1,572 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/databasetest"
+	"gorm.io/gorm"
+)
+
+func TestMain(suite *testing.M) {
+	os.Exit(databasetest.Main(suite))
+}
+
+// The generic column lookup of GORM needs a model, so we just try to read it
+func hasColumn(database *gorm.DB, table string, column string) bool {
+	return database.Exec(fmt.Sprintf("SELECT %s FROM %s", column, table)).Error == nil
+}
+
+var fixtures = fstest.MapFS{
+	"scripts/0001_create_shelves.up.sql":   {Data: []byte("CREATE TABLE shelves (id {{identity}}, label {{string}});")},
+	"scripts/0001_create_shelves.down.sql": {Data: []byte("DROP TABLE shelves;")},
+	"scripts/0002_rename_label.up.sql":     {Data: []byte("ALTER TABLE shelves RENAME COLUMN label TO name;\nINSERT INTO shelves (name) VALUES ('fiction');")},
+	"scripts/0002_rename_label.down.sql":   {Data: []byte("DELETE FROM shelves;\nALTER TABLE shelves RENAME COLUMN name TO label;")},
+	"scripts/README.md":                    {Data: []byte("Not a migration")},
+}
+
+func TestLoad(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should load the migrations sorted by version", func(test *testing.T) {
+		// Act
+		migrations, exception := Load(fixtures, "scripts")
+
+		// Assert
+		assert.Nil(exception)
+		assert.Len(migrations, 2)
+		assert.Equal(uint(1), migrations[0].Version)
+		assert.Equal("create_shelves", migrations[0].Name)
+		assert.Equal(uint(2), migrations[1].Version)
+		assert.Contains(migrations[1].Down, "RENAME COLUMN name TO label")
+	})
+
+	test.Run("Should fail when a migration has no up script", func(test *testing.T) {
+		// Arrange
+		source := fstest.MapFS{
+			"scripts/0001_lonely.down.sql": {Data: []byte("DROP TABLE lonely;")},
+		}
+
+		// Act
+		migrations, exception := Load(source, "scripts")
+
+		// Assert
+		assert.Nil(migrations)
+		assert.ErrorContains(exception, "has no up script")
+	})
+
+	test.Run("Should fail when the same version has different names", func(test *testing.T) {
+		// Arrange
+		source := fstest.MapFS{
+			"scripts/0001_first.up.sql":  {Data: []byte("SELECT 1;")},
+			"scripts/0001_second.up.sql": {Data: []byte("SELECT 2;")},
+		}
+
+		// Act
+		_, exception := Load(source, "scripts")
+
+		// Assert
+		assert.ErrorContains(exception, "conflicting names")
+	})
+
+	test.Run("Should embed the baseline migration", func(test *testing.T) {
+		// Act
+		migrator, exception := New(nil)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal("baseline", migrator.Migrations[0].Name)
+	})
+}
+
+func TestStatements(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should split the statements at the semicolons ending them", func(test *testing.T) {
+		// Act
+		result := statements("CREATE TABLE shelves (id integer);\n\nINSERT INTO shelves VALUES (1);\n")
+
+		// Assert
+		assert.Equal([]string{"CREATE TABLE shelves (id integer)", "INSERT INTO shelves VALUES (1)"}, result)
+	})
+
+	test.Run("Should keep the semicolons within quotes, comments and dollar-quoted bodies", func(test *testing.T) {
+		// Arrange
+		script := strings.Join([]string{
+			"INSERT INTO shelves (name) VALUES ('a;b'), ('it''s; fine'), (\"c;d\");",
+			"-- a comment; with a semicolon",
+			"/* another one; */ SELECT 1;",
+			"CREATE FUNCTION touch() RETURNS trigger AS $body$ BEGIN NEW.at = now(); RETURN NEW; END; $body$ LANGUAGE plpgsql;",
+		}, "\n")
+
+		// Act
+		result := statements(script)
+
+		// Assert
+		assert.Equal([]string{
+			"INSERT INTO shelves (name) VALUES ('a;b'), ('it''s; fine'), (\"c;d\")",
+			"-- a comment; with a semicolon\n/* another one; */ SELECT 1",
+			"CREATE FUNCTION touch() RETURNS trigger AS $body$ BEGIN NEW.at = now(); RETURN NEW; END; $body$ LANGUAGE plpgsql",
+		}, result)
+	})
+
+	test.Run("Should keep the bodies between the begin and end markers whole", func(test *testing.T) {
+		// Arrange
+		script := "-- +begin\nCREATE TRIGGER touch BEFORE UPDATE ON shelves FOR EACH ROW BEGIN SET NEW.at = NOW(); END;\n-- +end\nSELECT 1;"
+
+		// Act
+		result := statements(script)
+
+		// Assert
+		assert.Len(result, 2)
+		assert.Contains(result[0], "SET NEW.at = NOW(); END;")
+		assert.Equal("SELECT 1", result[1])
+	})
+}
+
+func TestUp(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should apply pending migrations in order and only once", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrations, _ := Load(fixtures, "scripts")
+			migrator := &Migrator{Database: database, Migrations: migrations}
+
+			// Act
+			first, exception := migrator.Up()
+			second, again := migrator.Up()
+
+			// Assert
+			assert.Nil(exception)
+			assert.Nil(again)
+			assert.Len(first, 2)
+			assert.Empty(second)
+			assert.True(hasColumn(database, "shelves", "name"))
+			assert.False(hasColumn(database, "shelves", "label"))
+		})
+	})
+
+	test.Run("Should rollback a migration that fails halfway", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			if database.Dialector.Name() == "mysql" {
+				test.Skip("MySQL commits data definition statements implicitly")
+			}
+
+			// Arrange
+			migrator := &Migrator{
+				Database: database,
+				Migrations: []Migration{
+					{Version: 1, Name: "broken", Up: "CREATE TABLE halfway (id {{integer}});\nTHIS IS NOT SQL;"},
+				},
+			}
+
+			// Act
+			applied, exception := migrator.Up()
+
+			// Assert
+			assert.ErrorContains(exception, "migration 1_broken failed")
+			assert.Empty(applied)
+			assert.False(database.Migrator().HasTable("halfway"))
+			statuses, _ := migrator.Status()
+			assert.False(statuses[0].Applied)
+		})
+	})
+
+	test.Run("Should create the baseline tables", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator, _ := New(database)
+
+			// Act
+			_, exception := migrator.Up()
+
+			// Assert
+			assert.Nil(exception)
+			assert.True(database.Migrator().HasTable("books"))
+			assert.True(database.Migrator().HasTable("users"))
+			assert.True(database.Migrator().HasIndex("users", "idx_users_deleted_at"))
+		})
+	})
+}
+
+func TestDown(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should revert the latest applied migrations", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrations, _ := Load(fixtures, "scripts")
+			migrator := &Migrator{Database: database, Migrations: migrations}
+			migrator.Up()
+
+			// Act
+			reverted, exception := migrator.Down(1)
+
+			// Assert
+			assert.Nil(exception)
+			assert.Len(reverted, 1)
+			assert.Equal("rename_label", reverted[0].Name)
+			assert.True(hasColumn(database, "shelves", "label"))
+			statuses, _ := migrator.Status()
+			assert.True(statuses[0].Applied)
+			assert.False(statuses[1].Applied)
+		})
+	})
+
+	test.Run("Should revert everything when steps exceed applied migrations", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrations, _ := Load(fixtures, "scripts")
+			migrator := &Migrator{Database: database, Migrations: migrations}
+			migrator.Up()
+
+			// Act
+			reverted, exception := migrator.Down(10)
+
+			// Assert
+			assert.Nil(exception)
+			assert.Len(reverted, 2)
+			assert.False(database.Migrator().HasTable("shelves"))
+		})
+	})
+
+	test.Run("Should refuse to revert an irreversible migration", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator := &Migrator{
+				Database:   database,
+				Migrations: []Migration{{Version: 1, Name: "one_way", Up: "CREATE TABLE one_way (id {{integer}});"}},
+			}
+			migrator.Up()
+
+			// Act
+			reverted, exception := migrator.Down(1)
+
+			// Assert
+			assert.ErrorContains(exception, "irreversible")
+			assert.Empty(reverted)
+			assert.True(database.Migrator().HasTable("one_way"))
+		})
+	})
+}
+
+func TestStatus(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should report applied and pending migrations", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrations, _ := Load(fixtures, "scripts")
+			migrator := &Migrator{Database: database, Migrations: migrations[:1]}
+			migrator.Up()
+			migrator.Migrations = migrations
+
+			// Act
+			statuses, exception := migrator.Status()
+
+			// Assert
+			assert.Nil(exception)
+			assert.Len(statuses, 2)
+			assert.True(statuses[0].Applied)
+			assert.False(statuses[0].AppliedAt.IsZero())
+			assert.False(statuses[1].Applied)
+			assert.True(statuses[1].AppliedAt.IsZero())
+		})
+	})
+}
+
+func TestInspect(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should report applied and pending migrations", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrations, _ := Load(fixtures, "scripts")
+			migrator := &Migrator{Database: database, Migrations: migrations[:1]}
+			migrator.Up()
+			migrator.Migrations = migrations
+
+			// Act
+			statuses, exception := migrator.Inspect()
+
+			// Assert
+			assert.Nil(exception)
+			assert.Len(statuses, 2)
+			assert.True(statuses[0].Applied)
+			assert.False(statuses[1].Applied)
+		})
+	})
+
+	test.Run("Should NOT create the table of the migrations applied", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrations, _ := Load(fixtures, "scripts")
+			migrator := &Migrator{Database: database, Migrations: migrations}
+
+			// Act
+			statuses, exception := migrator.Inspect()
+
+			// Assert
+			assert.ErrorIs(exception, ErrNotMigrated)
+			assert.Nil(statuses)
+			assert.False(database.Migrator().HasTable(&SchemaMigration{}))
+		})
+	})
+}
+
+func TestBookPrices(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should move the prices to minor units in pounds and back", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator, _ := New(database)
+			migrations := migrator.Migrations
+			migrator.Migrations = migrations[:1]
+			migrator.Up()
+			database.Exec("INSERT INTO books (title, author, price, quantity) VALUES ('Dune', 'Frank Herbert', 9.99, 2)")
+			migrator.Migrations = migrations[:2]
+
+			// Act
+			_, exception := migrator.Up()
+
+			// Assert
+			var price struct {
+				Amount   int64
+				Currency string
+			}
+			assert.Nil(exception)
+			database.Raw("SELECT amount, currency FROM book_prices").Scan(&price)
+			assert.Equal(int64(999), price.Amount)
+			assert.Equal("GBP", price.Currency)
+			assert.False(hasColumn(database, "books", "price"))
+
+			// Act
+			_, exception = migrator.Down(1)
+
+			// Assert
+			var restored float64
+			assert.Nil(exception)
+			database.Raw("SELECT price FROM books").Scan(&restored)
+			assert.InDelta(9.99, restored, 0.001)
+			assert.False(database.Migrator().HasTable("book_prices"))
+		})
+	})
+}
+
+func TestBookVersions(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should start the existing books at the first version", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator, _ := New(database)
+			migrations := migrator.Migrations
+			migrator.Migrations = migrations[:2]
+			migrator.Up()
+			database.Exec("INSERT INTO books (title, author, quantity) VALUES ('Dune', 'Frank Herbert', 2)")
+			migrator.Migrations = migrations[:3]
+
+			// Act
+			_, exception := migrator.Up()
+
+			// Assert
+			var version int
+			assert.Nil(exception)
+			database.Raw("SELECT version FROM books").Scan(&version)
+			assert.Equal(1, version)
+
+			// Act
+			_, exception = migrator.Down(1)
+
+			// Assert
+			assert.Nil(exception)
+			// Listing the columns avoids mistaking a missing "version" column for the VERSION() function
+			rows, _ := database.Raw("SELECT * FROM books").Rows()
+			columns, _ := rows.Columns()
+			rows.Close()
+			assert.NotContains(columns, "version")
+		})
+	})
+}
+
+func TestReservations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should hold the copies of the pending orders instead of taking them", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator, _ := New(database)
+			migrations := migrator.Migrations
+			migrator.Migrations = migrations[:7]
+			migrator.Up()
+			database.Exec("INSERT INTO books (title, author, quantity) VALUES ('Dune', 'Frank Herbert', 1)")
+			database.Exec("INSERT INTO orders (user_id, status, total_amount, total_currency) VALUES (1, 'pending', 1998, 'GBP'), (1, 'paid', 999, 'GBP')")
+			database.Exec(`INSERT INTO order_lines (order_id, book_id, title, author, quantity, unit_price_amount, unit_price_currency)
+				VALUES (1, 1, 'Dune', 'Frank Herbert', 2, 999, 'GBP'), (2, 1, 'Dune', 'Frank Herbert', 1, 999, 'GBP')`)
+			migrator.Migrations = migrations[:8]
+			migrator.Settings.ReservationTime = time.Hour
+
+			// Act
+			_, exception := migrator.Up()
+
+			// Assert
+			var book struct {
+				Quantity int
+				Reserved int
+			}
+			var reservations int64
+			var expires time.Time
+			assert.Nil(exception)
+			database.Raw("SELECT quantity, reserved FROM books").Scan(&book)
+			database.Table("reservations").Where("order_id = 1").Count(&reservations)
+			database.Raw("SELECT expires_at FROM reservations").Scan(&expires)
+			assert.Equal(3, book.Quantity)
+			assert.Equal(2, book.Reserved)
+			assert.Equal(int64(1), reservations)
+			assert.WithinDuration(time.Now().Add(time.Hour), expires, time.Minute)
+
+			// Act
+			_, exception = migrator.Down(1)
+
+			// Assert
+			var quantity int
+			assert.Nil(exception)
+			database.Raw("SELECT quantity FROM books").Scan(&quantity)
+			assert.Equal(1, quantity)
+			assert.False(database.Migrator().HasTable("reservations"))
+		})
+	})
+}
+
+func TestStockMovements(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should open the ledger of the existing books with their quantity", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator, _ := New(database)
+			migrations := migrator.Migrations
+			migrator.Migrations = migrations[:8]
+			migrator.Up()
+			database.Exec("INSERT INTO books (title, author, quantity) VALUES ('Dune', 'Frank Herbert', 3), ('Emma', 'Jane Austen', 0)")
+			migrator.Migrations = migrations[:9]
+
+			// Act
+			_, exception := migrator.Up()
+
+			// Assert
+			var movement struct {
+				BookID   uint
+				Kind     string
+				Quantity int
+			}
+			var movements int64
+			assert.Nil(exception)
+			database.Raw("SELECT book_id, kind, quantity FROM stock_movements").Scan(&movement)
+			database.Table("stock_movements").Count(&movements)
+			assert.Equal(int64(1), movements)
+			assert.Equal(uint(1), movement.BookID)
+			assert.Equal("adjustment", movement.Kind)
+			assert.Equal(3, movement.Quantity)
+		})
+	})
+}
+
+func TestLocations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should keep the copies of the existing books in the main shop", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator, _ := New(database)
+			migrations := migrator.Migrations
+			migrator.Migrations = migrations[:11]
+			migrator.Up()
+			database.Exec("INSERT INTO books (title, author, quantity, reserved) VALUES ('Dune', 'Frank Herbert', 3, 1), ('Emma', 'Jane Austen', 0, 0)")
+			database.Exec("INSERT INTO stock_movements (book_id, kind, quantity) VALUES (1, 'receipt', 3)")
+			database.Exec("INSERT INTO reservations (order_id, book_id, quantity, expires_at) VALUES (1, 1, 1, CURRENT_TIMESTAMP)")
+			migrator.Migrations = migrations[:12]
+
+			// Act
+			_, exception := migrator.Up()
+
+			// Assert
+			var levels []struct {
+				LocationID uint
+				BookID     uint
+				Quantity   int
+				Reserved   int
+			}
+			var location string
+			var movements, reservations int64
+			assert.Nil(exception)
+			database.Raw("SELECT name FROM locations").Scan(&location)
+			database.Raw("SELECT location_id, book_id, quantity, reserved FROM stock_levels ORDER BY book_id").Scan(&levels)
+			database.Table("stock_movements").Where("location_id = 1").Count(&movements)
+			database.Table("reservations").Where("location_id = 1").Count(&reservations)
+			assert.Equal("Main shop", location)
+			assert.Len(levels, 2)
+			assert.Equal(uint(1), levels[0].LocationID)
+			assert.Equal(3, levels[0].Quantity)
+			assert.Equal(1, levels[0].Reserved)
+			assert.Equal(int64(1), movements)
+			assert.Equal(int64(1), reservations)
+
+			// Act
+			_, exception = migrator.Down(1)
+
+			// Assert
+			assert.Nil(exception)
+			rows, _ := database.Raw("SELECT * FROM reservations").Rows()
+			columns, _ := rows.Columns()
+			rows.Close()
+			assert.NotContains(columns, "location_id")
+			assert.False(database.Migrator().HasTable("stock_levels"))
+			assert.False(database.Migrator().HasTable("locations"))
+		})
+	})
+}
+
+func TestIdempotencyClients(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should keep the keys of the anonymous clients apart", func(test *testing.T) {
+		databasetest.Run(test, func(test *testing.T, database *gorm.DB) {
+			// Arrange
+			migrator, _ := New(database)
+			migrations := migrator.Migrations
+			migrator.Migrations = migrations[:12]
+			migrator.Up()
+			database.Exec("INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, status, expires_at) VALUES (0, 'retry', 'dummy', 201, CURRENT_TIMESTAMP)")
+			migrator.Migrations = migrations[:13]
+
+			// Act
+			_, exception := migrator.Up()
+			other := database.Exec("INSERT INTO idempotency_keys (user_id, client, idempotency_key, fingerprint, status, expires_at) VALUES (0, 'cart', 'retry', 'dummy', 201, CURRENT_TIMESTAMP)").Error
+
+			// Assert
+			var clients []string
+			assert.Nil(exception)
+			assert.Nil(other)
+			database.Raw("SELECT client FROM idempotency_keys ORDER BY id").Scan(&clients)
+			assert.Equal([]string{"", "cart"}, clients)
+
+			// Act
+			_, exception = migrator.Down(1)
+
+			// Assert
+			var keys int64
+			assert.Nil(exception)
+			database.Table("idempotency_keys").Count(&keys)
+			assert.Equal(int64(1), keys)
+			assert.False(hasColumn(database, "idempotency_keys", "client"))
+		})
+	})
+}
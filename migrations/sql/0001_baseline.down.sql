@@ -0,0 +1,4 @@
+{{dropIndex "idx_users_deleted_at" "users"}};
+DROP TABLE IF EXISTS users;
+{{dropIndex "idx_books_deleted_at" "books"}};
+DROP TABLE IF EXISTS books;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS books (
+	id {{identity}},
+	created_at {{timestamp}},
+	updated_at {{timestamp}},
+	deleted_at {{timestamp}},
+	title {{text}},
+	author {{text}},
+	price {{real}},
+	quantity {{integer}}
+);
+
+{{createIndex "idx_books_deleted_at" "books" "deleted_at"}};
+
+CREATE TABLE IF NOT EXISTS users (
+	id {{identity}},
+	created_at {{timestamp}},
+	updated_at {{timestamp}},
+	deleted_at {{timestamp}},
+	nickname {{string}} UNIQUE,
+	password {{text}}
+);
+
+{{createIndex "idx_users_deleted_at" "users" "deleted_at"}};
@@ -0,0 +1,8 @@
+ALTER TABLE books ADD COLUMN price {{real}};
+
+UPDATE books SET price = (
+	SELECT amount / 100.0 FROM book_prices
+	WHERE book_prices.book_id = books.id AND book_prices.currency = 'GBP'
+);
+
+DROP TABLE book_prices;
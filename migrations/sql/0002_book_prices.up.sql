@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS book_prices (
+	id {{identity}},
+	book_id {{reference}} NOT NULL,
+	amount {{integer}} NOT NULL,
+	currency {{string}} NOT NULL
+);
+
+{{createUniqueIndex "idx_book_prices_book_currency" "book_prices" "book_id" "currency"}};
+
+INSERT INTO book_prices (book_id, amount, currency)
+SELECT id, ROUND(price * 100), 'GBP' FROM books WHERE price IS NOT NULL;
+
+ALTER TABLE books DROP COLUMN price;
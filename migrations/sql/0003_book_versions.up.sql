@@ -0,0 +1 @@
+ALTER TABLE books ADD COLUMN version {{integer}} NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+{{dropIndex "idx_idempotency_keys_expires_at" "idempotency_keys"}};
+{{dropIndex "idx_idempotency_keys_user_key" "idempotency_keys"}};
+DROP TABLE IF EXISTS idempotency_keys;
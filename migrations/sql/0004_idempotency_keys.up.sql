@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	id {{identity}},
+	user_id {{reference}} NOT NULL,
+	idempotency_key {{string}} NOT NULL,
+	fingerprint {{string}} NOT NULL,
+	status {{integer}} NOT NULL,
+	headers {{text}},
+	body {{text}},
+	created_at {{timestamp}},
+	expires_at {{timestamp}} NOT NULL
+);
+
+{{createUniqueIndex "idx_idempotency_keys_user_key" "idempotency_keys" "user_id" "idempotency_key"}};
+
+{{createIndex "idx_idempotency_keys_expires_at" "idempotency_keys" "expires_at"}};
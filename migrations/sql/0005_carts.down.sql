@@ -0,0 +1,5 @@
+{{dropIndex "idx_cart_items_cart_book" "cart_items"}};
+DROP TABLE IF EXISTS cart_items;
+{{dropIndex "idx_carts_token" "carts"}};
+{{dropIndex "idx_carts_user_id" "carts"}};
+DROP TABLE IF EXISTS carts;
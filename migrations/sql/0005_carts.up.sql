@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS carts (
+	id {{identity}},
+	user_id {{reference}},
+	token {{string}} NOT NULL,
+	created_at {{timestamp}},
+	updated_at {{timestamp}}
+);
+
+{{createUniqueIndex "idx_carts_user_id" "carts" "user_id"}};
+
+{{createUniqueIndex "idx_carts_token" "carts" "token"}};
+
+CREATE TABLE IF NOT EXISTS cart_items (
+	id {{identity}},
+	cart_id {{reference}} NOT NULL,
+	book_id {{reference}} NOT NULL,
+	quantity {{integer}} NOT NULL,
+	created_at {{timestamp}},
+	updated_at {{timestamp}}
+);
+
+{{createUniqueIndex "idx_cart_items_cart_book" "cart_items" "cart_id" "book_id"}};
@@ -0,0 +1,5 @@
+{{dropIndex "idx_order_lines_order_id" "order_lines"}};
+DROP TABLE IF EXISTS order_lines;
+{{dropIndex "idx_orders_status" "orders"}};
+{{dropIndex "idx_orders_user_id" "orders"}};
+DROP TABLE IF EXISTS orders;
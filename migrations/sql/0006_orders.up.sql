@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS orders (
+	id {{identity}},
+	user_id {{reference}} NOT NULL,
+	status {{string}} NOT NULL,
+	total_amount {{integer}} NOT NULL,
+	total_currency {{string}} NOT NULL,
+	created_at {{timestamp}},
+	updated_at {{timestamp}}
+);
+
+{{createIndex "idx_orders_user_id" "orders" "user_id"}};
+
+{{createIndex "idx_orders_status" "orders" "status"}};
+
+CREATE TABLE IF NOT EXISTS order_lines (
+	id {{identity}},
+	order_id {{reference}} NOT NULL,
+	book_id {{reference}} NOT NULL,
+	title {{string}} NOT NULL,
+	author {{string}} NOT NULL,
+	quantity {{integer}} NOT NULL,
+	unit_price_amount {{integer}} NOT NULL,
+	unit_price_currency {{string}} NOT NULL
+);
+
+{{createIndex "idx_order_lines_order_id" "order_lines" "order_id"}};
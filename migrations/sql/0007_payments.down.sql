@@ -0,0 +1,3 @@
+{{dropIndex "idx_payments_order_id" "payments"}};
+{{dropIndex "idx_payments_provider_reference" "payments"}};
+DROP TABLE IF EXISTS payments;
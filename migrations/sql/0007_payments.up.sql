@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS payments (
+	id {{identity}},
+	order_id {{reference}} NOT NULL,
+	provider {{string}} NOT NULL,
+	reference {{string}} NOT NULL,
+	status {{string}} NOT NULL,
+	amount {{integer}} NOT NULL,
+	currency {{string}} NOT NULL,
+	created_at {{timestamp}},
+	updated_at {{timestamp}}
+);
+
+{{createUniqueIndex "idx_payments_provider_reference" "payments" "provider" "reference"}};
+
+{{createIndex "idx_payments_order_id" "payments" "order_id"}};
@@ -0,0 +1,5 @@
+UPDATE books SET quantity = quantity - reserved;
+{{dropIndex "idx_reservations_expires_at" "reservations"}};
+{{dropIndex "idx_reservations_order_id" "reservations"}};
+DROP TABLE IF EXISTS reservations;
+ALTER TABLE books DROP COLUMN reserved;
@@ -0,0 +1,24 @@
+ALTER TABLE books ADD COLUMN reserved {{integer}} NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id {{identity}},
+	order_id {{reference}} NOT NULL,
+	book_id {{reference}} NOT NULL,
+	quantity {{integer}} NOT NULL,
+	expires_at {{timestamp}} NOT NULL,
+	created_at {{timestamp}}
+);
+
+{{createIndex "idx_reservations_order_id" "reservations" "order_id"}};
+
+{{createIndex "idx_reservations_expires_at" "reservations" "expires_at"}};
+
+-- The copies of the pending orders were taken from the stock, they are held instead for a whole reservation time
+INSERT INTO reservations (order_id, book_id, quantity, expires_at, created_at)
+SELECT order_lines.order_id, order_lines.book_id, order_lines.quantity, {{addInterval "CURRENT_TIMESTAMP" .ReservationTime}}, CURRENT_TIMESTAMP
+FROM order_lines INNER JOIN orders ON orders.id = order_lines.order_id
+WHERE orders.status = 'pending';
+
+UPDATE books SET
+	reserved = (SELECT COALESCE(SUM(reservations.quantity), 0) FROM reservations WHERE reservations.book_id = books.id),
+	quantity = quantity + (SELECT COALESCE(SUM(reservations.quantity), 0) FROM reservations WHERE reservations.book_id = books.id);
@@ -0,0 +1,2 @@
+{{dropIndex "idx_stock_movements_book_id" "stock_movements"}};
+DROP TABLE IF EXISTS stock_movements;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS stock_movements (
+	id {{identity}},
+	book_id {{reference}} NOT NULL,
+	kind {{string}} NOT NULL,
+	quantity {{integer}} NOT NULL,
+	user_id {{reference}},
+	reason {{string}} NOT NULL DEFAULT '',
+	reference {{string}} NOT NULL DEFAULT '',
+	created_at {{timestamp}}
+);
+
+{{createIndex "idx_stock_movements_book_id" "stock_movements" "book_id"}};
+
+INSERT INTO stock_movements (book_id, kind, quantity, reason, created_at)
+SELECT id, 'adjustment', quantity, 'Opening balance', CURRENT_TIMESTAMP FROM books WHERE quantity <> 0;
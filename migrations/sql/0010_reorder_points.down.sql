@@ -0,0 +1,2 @@
+ALTER TABLE books DROP COLUMN reorder_target;
+ALTER TABLE books DROP COLUMN reorder_point;
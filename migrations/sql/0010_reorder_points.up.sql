@@ -0,0 +1,3 @@
+ALTER TABLE books ADD COLUMN reorder_point {{integer}} NOT NULL DEFAULT 0;
+
+ALTER TABLE books ADD COLUMN reorder_target {{integer}} NOT NULL DEFAULT 0;
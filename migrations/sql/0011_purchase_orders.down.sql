@@ -0,0 +1,7 @@
+{{dropIndex "idx_purchase_order_lines_order_book" "purchase_order_lines"}};
+DROP TABLE IF EXISTS purchase_order_lines;
+{{dropIndex "idx_purchase_orders_status" "purchase_orders"}};
+{{dropIndex "idx_purchase_orders_supplier_id" "purchase_orders"}};
+DROP TABLE IF EXISTS purchase_orders;
+{{dropIndex "idx_suppliers_name" "suppliers"}};
+DROP TABLE IF EXISTS suppliers;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS suppliers (
+	id {{identity}},
+	name {{string}} NOT NULL,
+	email {{string}} NOT NULL DEFAULT '',
+	created_at {{timestamp}},
+	updated_at {{timestamp}}
+);
+
+{{createUniqueIndex "idx_suppliers_name" "suppliers" "name"}};
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+	id {{identity}},
+	supplier_id {{reference}} NOT NULL,
+	user_id {{reference}},
+	status {{string}} NOT NULL,
+	created_at {{timestamp}},
+	updated_at {{timestamp}}
+);
+
+{{createIndex "idx_purchase_orders_supplier_id" "purchase_orders" "supplier_id"}};
+
+{{createIndex "idx_purchase_orders_status" "purchase_orders" "status"}};
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+	id {{identity}},
+	purchase_order_id {{reference}} NOT NULL,
+	book_id {{reference}} NOT NULL,
+	quantity {{integer}} NOT NULL,
+	received {{integer}} NOT NULL DEFAULT 0
+);
+
+{{createUniqueIndex "idx_purchase_order_lines_order_book" "purchase_order_lines" "purchase_order_id" "book_id"}};
@@ -0,0 +1,7 @@
+ALTER TABLE reservations DROP COLUMN location_id;
+ALTER TABLE stock_movements DROP COLUMN location_id;
+{{dropIndex "idx_stock_levels_book_id" "stock_levels"}};
+{{dropIndex "idx_stock_levels_location_book" "stock_levels"}};
+DROP TABLE IF EXISTS stock_levels;
+{{dropIndex "idx_locations_name" "locations"}};
+DROP TABLE IF EXISTS locations;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS locations (
+	id {{identity}},
+	name {{string}} NOT NULL,
+	created_at {{timestamp}},
+	updated_at {{timestamp}}
+);
+
+{{createUniqueIndex "idx_locations_name" "locations" "name"}};
+
+-- The copies the books already had are kept in the first location, which is the primary one
+INSERT INTO locations (name, created_at, updated_at) VALUES ('Main shop', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+CREATE TABLE IF NOT EXISTS stock_levels (
+	id {{identity}},
+	location_id {{reference}} NOT NULL,
+	book_id {{reference}} NOT NULL,
+	quantity {{integer}} NOT NULL DEFAULT 0,
+	reserved {{integer}} NOT NULL DEFAULT 0
+);
+
+{{createUniqueIndex "idx_stock_levels_location_book" "stock_levels" "location_id" "book_id"}};
+
+{{createIndex "idx_stock_levels_book_id" "stock_levels" "book_id"}};
+
+INSERT INTO stock_levels (location_id, book_id, quantity, reserved)
+SELECT locations.id, books.id, books.quantity, books.reserved FROM books CROSS JOIN locations;
+
+ALTER TABLE stock_movements ADD COLUMN location_id {{reference}};
+
+UPDATE stock_movements SET location_id = (SELECT MIN(id) FROM locations);
+
+ALTER TABLE reservations ADD COLUMN location_id {{reference}};
+
+UPDATE reservations SET location_id = (SELECT MIN(id) FROM locations);
@@ -0,0 +1,7 @@
+DELETE FROM idempotency_keys WHERE client <> '';
+
+{{dropIndex "idx_idempotency_keys_user_client_key" "idempotency_keys"}};
+
+{{createUniqueIndex "idx_idempotency_keys_user_key" "idempotency_keys" "user_id" "idempotency_key"}};
+
+ALTER TABLE idempotency_keys DROP COLUMN client;
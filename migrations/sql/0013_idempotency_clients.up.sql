@@ -0,0 +1,5 @@
+ALTER TABLE idempotency_keys ADD COLUMN client {{string}} NOT NULL DEFAULT '';
+
+{{dropIndex "idx_idempotency_keys_user_key" "idempotency_keys"}};
+
+{{createUniqueIndex "idx_idempotency_keys_user_client_key" "idempotency_keys" "user_id" "client" "idempotency_key"}};
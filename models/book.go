@@ -1,18 +1,150 @@
 package models
 
 import (
+	"errors"
 	"time"
 
+	"github.com/zatarain/bookshop/money"
 	"gorm.io/gorm"
 )
 
 type Book struct {
-	gorm.Model
-	ID        uint      `json:"id" gorm:"primary_key"`
-	Title     string    `json:"title"`
-	Author    string    `json:"author"`
-	Price     float32   `json:"price"`
-	Quantity  int       `json:"quantity"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	Title         string
+	Author        string
+	Prices        []BookPrice
+	Levels        []StockLevel
+	Quantity      int
+	Reserved      int  `gorm:"not null;default:0"`
+	Version       uint `gorm:"not null;default:1"`
+	ReorderPoint  int  `gorm:"not null;default:0"`
+	ReorderTarget int  `gorm:"not null;default:0"`
+}
+
+type BookPrice struct {
+	ID     uint `gorm:"primaryKey"`
+	BookID uint
+	Price  money.Money `gorm:"embedded"`
+}
+
+var ErrStaleBook = errors.New("the book was modified since it was read")
+
+// Update stores the changes of the book and replaces its prices as long as nobody updated it since it was read,
+// then the book moves to the next version. A change of the quantity is recorded as an adjustment made by the user at
+// the primary location
+func (book *Book) Update(database *gorm.DB, user *User) (exception error) {
+	version := book.Version
+	defer func() {
+		// Nothing was stored when the transaction failed, so the book stays at the version that was read
+		if exception != nil {
+			book.Version = version
+		}
+	}()
+
+	return database.Transaction(func(transaction *gorm.DB) error {
+		stored := &Book{}
+		if exception := transaction.Select("quantity").Where("version = ?", version).First(stored, book.ID).Error; errors.Is(exception, gorm.ErrRecordNotFound) {
+			return ErrStaleBook
+		} else if exception != nil {
+			return exception
+		}
+
+		book.Version = version + 1
+		update := transaction.Model(book).
+			Where("version = ?", version).
+			Select("title", "author", "quantity", "reorder_point", "reorder_target", "version", "updated_at").
+			Updates(book)
+		if update.Error != nil {
+			return update.Error
+		}
+
+		if update.RowsAffected == 0 {
+			return ErrStaleBook
+		}
+
+		if difference := book.Quantity - stored.Quantity; difference != 0 {
+			location, exception := PrimaryLocation(transaction)
+			if exception != nil {
+				return exception
+			}
+
+			if exception := stockAt(transaction, book.ID, location.ID, difference); exception != nil {
+				return exception
+			}
+
+			exception = transaction.Create(&StockMovement{
+				BookID:     book.ID,
+				LocationID: location.ID,
+				Kind:       StockAdjustment,
+				Quantity:   difference,
+				UserID:     movedBy(user),
+				Reason:     "Edited the book",
+			}).Error
+			if exception != nil {
+				return exception
+			}
+		}
+
+		if exception := transaction.Where("book_id = ?", book.ID).Delete(&BookPrice{}).Error; exception != nil || len(book.Prices) == 0 {
+			return exception
+		}
+
+		return transaction.Create(&book.Prices).Error
+	})
+}
+
+// Remove deletes the book as long as nobody updated it since it was read
+func (book *Book) Remove(database *gorm.DB) error {
+	deletion := database.Where("version = ?", book.Version).Delete(book)
+	if deletion.Error == nil && deletion.RowsAffected == 0 {
+		return ErrStaleBook
+	}
+
+	return deletion.Error
+}
+
+// Restore brings a soft-deleted book back to the catalogue as a new version
+func (book *Book) Restore(database *gorm.DB) error {
+	return database.Unscoped().Model(book).Where("deleted_at IS NOT NULL").Updates(map[string]any{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	}).Error
+}
+
+// Purge removes the book, its prices, the cart items and its copies at every location permanently, even when it wasn't
+// soft-deleted. The pending orders of the book are cancelled, which releases the copies they hold
+func (book *Book) Purge(database *gorm.DB) error {
+	return database.Transaction(func(transaction *gorm.DB) error {
+		if exception := cancelPendingOrders(transaction, book.ID); exception != nil {
+			return exception
+		}
+
+		if exception := transaction.Where("book_id = ?", book.ID).Delete(&BookPrice{}).Error; exception != nil {
+			return exception
+		}
+
+		if exception := transaction.Where("book_id = ?", book.ID).Delete(&CartItem{}).Error; exception != nil {
+			return exception
+		}
+
+		if exception := transaction.Where("book_id = ?", book.ID).Delete(&StockLevel{}).Error; exception != nil {
+			return exception
+		}
+
+		return transaction.Unscoped().Delete(book).Error
+	})
+}
+
+// PriceIn looks for the price of the book in the given currency
+func (book *Book) PriceIn(currency string) (money.Money, bool) {
+	for _, price := range book.Prices {
+		if price.Price.Currency == currency {
+			return price.Price, true
+		}
+	}
+
+	return money.Money{}, false
 }
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/migrations/migrationstest"
+	"github.com/zatarain/bookshop/money"
+	"gorm.io/gorm"
+)
+
+func setupBooks(test *testing.T) *gorm.DB {
+	return migrationstest.Migrated(test)
+}
+
+func TestBookUpdate(test *testing.T) {
+	assert := assert.New(test)
+	database := setupBooks(test)
+	price := []BookPrice{{Price: money.MustParse("9.99", "GBP")}}
+	require.Nil(test, database.Create(&Book{Title: "Dune", Author: "Frank Herbert", Prices: price, Quantity: 2}).Error)
+
+	test.Run("Should store the changes and move the book to the next version", func(test *testing.T) {
+		// Arrange
+		book := &Book{}
+		database.First(book, 1)
+		book.Quantity = 0
+		book.Prices = []BookPrice{{BookID: book.ID, Price: money.MustParse("11", "EUR")}}
+
+		// Act
+		exception := book.Update(database, nil)
+
+		// Assert
+		stored := &Book{}
+		database.Preload("Prices").First(stored, 1)
+		assert.Nil(exception)
+		assert.Equal(uint(2), book.Version)
+		assert.Equal(uint(2), stored.Version)
+		assert.Equal(0, stored.Quantity)
+		require.Len(test, stored.Prices, 1)
+		assert.Equal(money.MustParse("11", "EUR"), stored.Prices[0].Price)
+	})
+
+	test.Run("Should NOT overwrite the changes made since the book was read", func(test *testing.T) {
+		// Arrange
+		first, second := &Book{}, &Book{}
+		database.First(first, 1)
+		database.First(second, 1)
+		first.Quantity = 10
+		second.Quantity = 20
+		require.Nil(test, first.Update(database, nil))
+
+		// Act
+		exception := second.Update(database, nil)
+
+		// Assert
+		stored := &Book{}
+		database.First(stored, 1)
+		assert.ErrorIs(exception, ErrStaleBook)
+		assert.Equal(uint(2), second.Version)
+		assert.Equal(uint(3), stored.Version)
+		assert.Equal(10, stored.Quantity)
+	})
+
+	test.Run("Should keep the version that was read when the prices can't be replaced", func(test *testing.T) {
+		// Arrange
+		book := &Book{}
+		database.First(book, 1)
+		book.Quantity = 5
+		book.Prices = []BookPrice{
+			{BookID: book.ID, Price: money.MustParse("9.99", "GBP")},
+			{BookID: book.ID, Price: money.MustParse("8.99", "GBP")},
+		}
+
+		// Act
+		exception := book.Update(database, nil)
+
+		// Assert
+		stored := &Book{}
+		database.First(stored, 1)
+		assert.NotNil(exception)
+		assert.Equal(uint(3), book.Version)
+		assert.Equal(uint(3), stored.Version)
+		assert.Equal(10, stored.Quantity)
+	})
+}
+
+func TestBookRemove(test *testing.T) {
+	assert := assert.New(test)
+	database := setupBooks(test)
+	require.Nil(test, database.Create(&Book{Title: "Dune", Author: "Frank Herbert", Quantity: 2}).Error)
+
+	test.Run("Should NOT remove a book changed since it was read", func(test *testing.T) {
+		// Arrange
+		stale := &Book{}
+		database.First(stale, 1)
+		database.Model(&Book{ID: 1}).Update("version", 2)
+
+		// Act
+		exception := stale.Remove(database)
+
+		// Assert
+		assert.ErrorIs(exception, ErrStaleBook)
+		assert.Nil(database.First(&Book{}, 1).Error)
+	})
+
+	test.Run("Should remove the book at its current version", func(test *testing.T) {
+		// Arrange
+		book := &Book{}
+		database.First(book, 1)
+
+		// Act
+		exception := book.Remove(database)
+
+		// Assert
+		assert.Nil(exception)
+		assert.ErrorIs(database.First(&Book{}, 1).Error, gorm.ErrRecordNotFound)
+	})
+}
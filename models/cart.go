@@ -0,0 +1,176 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Cart keeps the books a user wants to buy, the anonymous carts are only known by their token until the user logs in
+type Cart struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    *int
+	Token     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Items     []CartItem
+}
+
+type CartItem struct {
+	ID        uint `gorm:"primaryKey"`
+	CartID    uint
+	BookID    uint
+	Book      Book
+	Quantity  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var ErrCartItemNotFound = errors.New("the book is not in the cart")
+
+// MaxCartQuantity is the most copies of a book a cart can have
+const MaxCartQuantity = 99
+
+func newCartToken() string {
+	var random [16]byte
+	rand.Read(random[:])
+	return hex.EncodeToString(random[:])
+}
+
+// NewCart creates an anonymous cart with a new token
+func NewCart(database *gorm.DB) (*Cart, error) {
+	cart := &Cart{Token: newCartToken()}
+	return cart, database.Create(cart).Error
+}
+
+// CartOf gives the cart of the user, which is created the first time
+func CartOf(database *gorm.DB, user *User) (*Cart, error) {
+	cart := &Cart{}
+	exception := database.Where(Cart{UserID: &user.ID}).Attrs(Cart{Token: newCartToken()}).FirstOrCreate(cart).Error
+	return cart, exception
+}
+
+// AnonymousCart looks for the cart of the token which doesn't belong to any user yet
+func AnonymousCart(database *gorm.DB, token string) (*Cart, error) {
+	cart := &Cart{}
+	exception := database.Where("token = ? AND user_id IS NULL", token).First(cart).Error
+	return cart, exception
+}
+
+// WithBooks loads the items of the cart with their live books and prices, including the ones deleted since they were added
+func (cart *Cart) WithBooks(database *gorm.DB) error {
+	return database.
+		Preload("Items", func(database *gorm.DB) *gorm.DB {
+			return database.Order("id")
+		}).
+		Preload("Items.Book", func(database *gorm.DB) *gorm.DB {
+			return database.Unscoped()
+		}).
+		Preload("Items.Book.Prices", func(database *gorm.DB) *gorm.DB {
+			return database.Order("id")
+		}).
+		First(cart, cart.ID).Error
+}
+
+// Add puts copies of the book in the cart, on top of the ones it already has, up to MaxCartQuantity
+func (cart *Cart) Add(database *gorm.DB, bookID uint, quantity int) error {
+	item := &CartItem{CartID: cart.ID, BookID: bookID, Quantity: min(quantity, MaxCartQuantity)}
+	// CASE instead of MIN or LEAST, as each dialect has only one of them
+	capped := gorm.Expr(
+		"CASE WHEN cart_items.quantity + ? > ? THEN ? ELSE cart_items.quantity + ? END",
+		quantity, MaxCartQuantity, MaxCartQuantity, quantity,
+	)
+	return database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cart_id"}, {Name: "book_id"}},
+		DoUpdates: clause.Assignments(map[string]any{"quantity": capped, "updated_at": time.Now()}),
+	}).Create(item).Error
+}
+
+// SetQuantity changes the number of copies of a book already in the cart
+func (cart *Cart) SetQuantity(database *gorm.DB, bookID uint, quantity int) error {
+	update := database.Model(&CartItem{}).Where("cart_id = ? AND book_id = ?", cart.ID, bookID).Update("quantity", quantity)
+	if update.Error == nil && update.RowsAffected == 0 {
+		return ErrCartItemNotFound
+	}
+
+	return update.Error
+}
+
+// Remove takes the book out of the cart
+func (cart *Cart) Remove(database *gorm.DB, bookID uint) error {
+	deletion := database.Where("cart_id = ? AND book_id = ?", cart.ID, bookID).Delete(&CartItem{})
+	if deletion.Error == nil && deletion.RowsAffected == 0 {
+		return ErrCartItemNotFound
+	}
+
+	return deletion.Error
+}
+
+// Merge moves the items of the other cart into this one, adding up the copies of the books in both up to
+// MaxCartQuantity, then removes it
+func (cart *Cart) Merge(database *gorm.DB, other *Cart) error {
+	return database.Transaction(func(transaction *gorm.DB) error {
+		items := []CartItem{}
+		if exception := transaction.Where("cart_id = ?", other.ID).Find(&items).Error; exception != nil {
+			return exception
+		}
+
+		for _, item := range items {
+			if exception := cart.Add(transaction, item.BookID, item.Quantity); exception != nil {
+				return exception
+			}
+		}
+
+		return other.Delete(transaction)
+	})
+}
+
+// Delete removes the cart along with its items
+func (cart *Cart) Delete(database *gorm.DB) error {
+	if exception := database.Where("cart_id = ?", cart.ID).Delete(&CartItem{}).Error; exception != nil {
+		return exception
+	}
+
+	return database.Delete(cart).Error
+}
+
+// purgeCarts removes the carts of the given users along with their items
+func purgeCarts(database *gorm.DB, users any) error {
+	carts := database.Model(&Cart{}).Select("id").Where("user_id IN (?)", users)
+	if exception := database.Where("cart_id IN (?)", carts).Delete(&CartItem{}).Error; exception != nil {
+		return exception
+	}
+
+	return database.Where("user_id IN (?)", users).Delete(&Cart{}).Error
+}
+
+// Checkout places an order with every book of the cart for the user, reserved until the expiry preferably at the given
+// location, and empties it, either both happen or none does
+func (cart *Cart) Checkout(database *gorm.DB, user *User, currency string, expiry time.Time, preferred uint) (order *Order, exception error) {
+	exception = database.Transaction(func(transaction *gorm.DB) error {
+		items := []CartItem{}
+		if exception := transaction.Where("cart_id = ?", cart.ID).Order("id").Find(&items).Error; exception != nil {
+			return exception
+		}
+
+		lines := make([]OrderLine, len(items))
+		for index, item := range items {
+			lines[index] = OrderLine{BookID: item.BookID, Quantity: item.Quantity}
+		}
+
+		if order, exception = PlaceOrder(transaction, user, currency, lines, expiry, preferred); exception != nil {
+			return exception
+		}
+
+		return transaction.Where("cart_id = ?", cart.ID).Delete(&CartItem{}).Error
+	})
+	if exception != nil {
+		return nil, exception
+	}
+
+	return order, nil
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/money"
+	"gorm.io/gorm"
+)
+
+func setupCarts(test *testing.T) (*gorm.DB, *User) {
+	database := setupBooks(test)
+	price := []BookPrice{{Price: money.MustParse("9.99", "GBP")}}
+	require.Nil(test, database.Create(&Book{Title: "Dune", Author: "Frank Herbert", Prices: price, Quantity: 2}).Error)
+	require.Nil(test, database.Create(&Book{Title: "Emma", Author: "Jane Austen", Quantity: 1}).Error)
+	user := &User{Nickname: "dummy-user", Password: "hash"}
+	require.Nil(test, database.Create(user).Error)
+	return database, user
+}
+
+func quantities(database *gorm.DB, cart *Cart) map[uint]int {
+	items := []CartItem{}
+	database.Where("cart_id = ?", cart.ID).Find(&items)
+	result := map[uint]int{}
+	for _, item := range items {
+		result[item.BookID] = item.Quantity
+	}
+
+	return result
+}
+
+func TestCartOf(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should give the same cart to the user every time", func(test *testing.T) {
+		// Arrange
+		database, user := setupCarts(test)
+
+		// Act
+		first, exception := CartOf(database, user)
+		second, _ := CartOf(database, user)
+
+		// Assert
+		assert.Nil(exception)
+		assert.NotZero(first.ID)
+		assert.Equal(first.ID, second.ID)
+		assert.Equal(&user.ID, second.UserID)
+		assert.NotEmpty(second.Token)
+	})
+
+	test.Run("Should NOT give the cart of a user as an anonymous one", func(test *testing.T) {
+		// Arrange
+		database, user := setupCarts(test)
+		cart, _ := CartOf(database, user)
+
+		// Act
+		_, exception := AnonymousCart(database, cart.Token)
+
+		// Assert
+		assert.ErrorIs(exception, gorm.ErrRecordNotFound)
+	})
+}
+
+func TestCartItems(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should add up the copies of the same book", func(test *testing.T) {
+		// Arrange
+		database, _ := setupCarts(test)
+		cart, _ := NewCart(database)
+
+		// Act
+		first := cart.Add(database, 1, 1)
+		second := cart.Add(database, 1, 2)
+
+		// Assert
+		assert.Nil(first)
+		assert.Nil(second)
+		assert.Equal(map[uint]int{1: 3}, quantities(database, cart))
+	})
+
+	test.Run("Should NOT add more copies of the same book than the maximum", func(test *testing.T) {
+		// Arrange
+		database, _ := setupCarts(test)
+		cart, _ := NewCart(database)
+
+		// Act
+		first := cart.Add(database, 1, 60)
+		second := cart.Add(database, 1, 60)
+
+		// Assert
+		assert.Nil(first)
+		assert.Nil(second)
+		assert.Equal(map[uint]int{1: MaxCartQuantity}, quantities(database, cart))
+	})
+
+	test.Run("Should change the quantity of a book in the cart", func(test *testing.T) {
+		// Arrange
+		database, _ := setupCarts(test)
+		cart, _ := NewCart(database)
+		cart.Add(database, 1, 1)
+
+		// Act
+		exception := cart.SetQuantity(database, 1, 5)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{1: 5}, quantities(database, cart))
+	})
+
+	test.Run("Should remove a book from the cart", func(test *testing.T) {
+		// Arrange
+		database, _ := setupCarts(test)
+		cart, _ := NewCart(database)
+		cart.Add(database, 1, 1)
+		cart.Add(database, 2, 1)
+
+		// Act
+		exception := cart.Remove(database, 1)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{2: 1}, quantities(database, cart))
+	})
+
+	test.Run("Should NOT change the books which are not in the cart", func(test *testing.T) {
+		// Arrange
+		database, _ := setupCarts(test)
+		cart, _ := NewCart(database)
+
+		// Act
+		update := cart.SetQuantity(database, 1, 5)
+		removal := cart.Remove(database, 1)
+
+		// Assert
+		assert.ErrorIs(update, ErrCartItemNotFound)
+		assert.ErrorIs(removal, ErrCartItemNotFound)
+	})
+
+	test.Run("Should load the live books, even the deleted ones", func(test *testing.T) {
+		// Arrange
+		database, _ := setupCarts(test)
+		cart, _ := NewCart(database)
+		cart.Add(database, 1, 1)
+		cart.Add(database, 2, 1)
+		database.Delete(&Book{}, 2)
+
+		// Act
+		exception := cart.WithBooks(database)
+
+		// Assert
+		assert.Nil(exception)
+		require.Len(test, cart.Items, 2)
+		assert.Equal("Dune", cart.Items[0].Book.Title)
+		assert.Len(cart.Items[0].Book.Prices, 1)
+		assert.Equal("Emma", cart.Items[1].Book.Title)
+		assert.True(cart.Items[1].Book.DeletedAt.Valid)
+	})
+}
+
+func TestCartMerge(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should move the items of the anonymous cart into the one of the user", func(test *testing.T) {
+		// Arrange
+		database, user := setupCarts(test)
+		cart, _ := CartOf(database, user)
+		cart.Add(database, 1, 1)
+		anonymous, _ := NewCart(database)
+		anonymous.Add(database, 1, 1)
+		anonymous.Add(database, 2, 1)
+
+		// Act
+		exception := cart.Merge(database, anonymous)
+
+		// Assert
+		var carts int64
+		database.Model(&Cart{}).Count(&carts)
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{1: 2, 2: 1}, quantities(database, cart))
+		assert.Empty(quantities(database, anonymous))
+		assert.Equal(int64(1), carts)
+	})
+
+	test.Run("Should NOT add up more copies of the same book than the maximum", func(test *testing.T) {
+		// Arrange
+		database, user := setupCarts(test)
+		cart, _ := CartOf(database, user)
+		cart.Add(database, 1, 90)
+		anonymous, _ := NewCart(database)
+		anonymous.Add(database, 1, 90)
+
+		// Act
+		exception := cart.Merge(database, anonymous)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{1: MaxCartQuantity}, quantities(database, cart))
+	})
+}
+
+func TestCartPurge(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should remove the cart of the user purged", func(test *testing.T) {
+		// Arrange
+		database, user := setupCarts(test)
+		cart, _ := CartOf(database, user)
+		cart.Add(database, 1, 1)
+
+		// Act
+		exception := user.Purge(database)
+
+		// Assert
+		var carts, items int64
+		database.Model(&Cart{}).Count(&carts)
+		database.Model(&CartItem{}).Count(&items)
+		assert.Nil(exception)
+		assert.Zero(carts)
+		assert.Zero(items)
+	})
+
+	test.Run("Should take the book purged out of the carts", func(test *testing.T) {
+		// Arrange
+		database, _ := setupCarts(test)
+		cart, _ := NewCart(database)
+		cart.Add(database, 1, 1)
+		cart.Add(database, 2, 1)
+
+		// Act
+		exception := (&Book{ID: 1}).Purge(database)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{2: 1}, quantities(database, cart))
+	})
+
+	test.Run("Should remove the carts of the trash purged", func(test *testing.T) {
+		// Arrange
+		database, user := setupCarts(test)
+		cart, _ := CartOf(database, user)
+		cart.Add(database, 1, 1)
+		cart.Add(database, 2, 1)
+		database.Delete(&Book{}, 1)
+		database.Delete(user)
+
+		// Act
+		_, _, exception := PurgeDeleted(database, time.Now().Add(time.Minute))
+
+		// Assert
+		var carts, items int64
+		database.Model(&Cart{}).Count(&carts)
+		database.Model(&CartItem{}).Count(&items)
+		assert.Nil(exception)
+		assert.Zero(carts)
+		assert.Zero(items)
+	})
+}
@@ -1,12 +1,29 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
 
 type DataAccessInterface interface {
 	Create(interface{}) *gorm.DB
 	First(interface{}, ...interface{}) *gorm.DB
 }
 
+type contextual interface {
+	WithContext(context.Context) *gorm.DB
+}
+
+// WithContext scopes the statements to the given context when the data access supports it
+func WithContext(database DataAccessInterface, context context.Context) DataAccessInterface {
+	if scoped, ok := database.(contextual); ok {
+		return scoped.WithContext(context)
+	}
+
+	return database
+}
+
 type MockedDataAccessInterface interface {
 	gorm.DB
 	DataAccessInterface
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey keeps the answer of a write request to replay it when the client retries it with the same key
+type IdempotencyKey struct {
+	ID          uint `gorm:"primaryKey"`
+	UserID      int
+	Client      string
+	Key         string `gorm:"column:idempotency_key"`
+	Fingerprint string
+	Status      int
+	Headers     string
+	Body        string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed tells whether the request already has an answer, otherwise it is still being processed
+func (record *IdempotencyKey) Completed() bool {
+	return record.Status != 0
+}
+
+// PurgeExpiredIdempotencyKeys removes the keys whose window closed before the given time
+func PurgeExpiredIdempotencyKeys(database *gorm.DB, now time.Time) (int64, error) {
+	purge := database.Where("expires_at < ?", now).Delete(&IdempotencyKey{})
+	return purge.RowsAffected, purge.Error
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// LowStock is a book whose available copies are below its reorder point
+type LowStock struct {
+	BookID        uint
+	Title         string
+	Author        string
+	Available     int
+	ReorderPoint  int
+	ReorderTarget int
+}
+
+// Reorder gives the copies to buy to bring the book back to its target
+func (low *LowStock) Reorder() int {
+	return max(low.ReorderTarget-low.Available, 0)
+}
+
+// lowAfter tells whether taking the quantity from the available copies of the book takes them below its reorder
+// point, so it is only told once until the book is restocked
+func (book *Book) lowAfter(quantity int) (LowStock, bool) {
+	available := book.Available() - quantity
+	if book.ReorderPoint == 0 || book.Available() < book.ReorderPoint || available >= book.ReorderPoint {
+		return LowStock{}, false
+	}
+
+	return LowStock{
+		BookID:        book.ID,
+		Title:         book.Title,
+		Author:        book.Author,
+		Available:     available,
+		ReorderPoint:  book.ReorderPoint,
+		ReorderTarget: book.ReorderTarget,
+	}, true
+}
+
+// LowStockReport lists the books of the catalogue whose available copies are below their reorder point, the furthest
+// below first
+func LowStockReport(database *gorm.DB) ([]LowStock, error) {
+	report := []LowStock{}
+	exception := database.Model(&Book{}).
+		Select("id AS book_id, title, author, quantity - reserved AS available, reorder_point, reorder_target").
+		Where("reorder_point > 0 AND quantity - reserved < reorder_point").
+		Order("quantity - reserved - reorder_point, id").
+		Scan(&report).Error
+	return report, exception
+}
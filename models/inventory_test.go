@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLowStock(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should list the books the order took below their reorder point", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		require.Nil(test, database.Model(&Book{ID: 1}).Updates(map[string]any{"reorder_point": 2, "reorder_target": 6}).Error)
+		require.Nil(test, database.Model(&Book{ID: 2}).Updates(map[string]any{"reorder_point": 1, "reorder_target": 3}).Error)
+
+		// Act
+		order, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal([]LowStock{
+			{BookID: 1, Title: "Dune", Author: "Frank Herbert", Available: 1, ReorderPoint: 2, ReorderTarget: 6},
+			{BookID: 2, Title: "Emma", Author: "Jane Austen", Available: 0, ReorderPoint: 1, ReorderTarget: 3},
+		}, order.LowStock)
+	})
+
+	test.Run("Should NOT list the books which were already low or have no reorder point", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		require.Nil(test, database.Model(&Book{ID: 1}).Updates(map[string]any{"reorder_point": 3, "reorder_target": 6}).Error)
+
+		// Act
+		order, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Empty(order.LowStock)
+	})
+
+	test.Run("Should report the books below their reorder point, the furthest below first", func(test *testing.T) {
+		// Arrange
+		database, _ := setupOrders(test)
+		require.Nil(test, database.Model(&Book{ID: 1}).Updates(map[string]any{"reorder_point": 3, "reorder_target": 6, "reserved": 1}).Error)
+		require.Nil(test, database.Model(&Book{ID: 2}).Updates(map[string]any{"reorder_point": 5, "reorder_target": 8}).Error)
+		require.Nil(test, database.Model(&Book{ID: 3}).Updates(map[string]any{"reorder_point": 4, "reorder_target": 4}).Error)
+
+		// Act
+		report, exception := LowStockReport(database)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal([]LowStock{
+			{BookID: 2, Title: "Emma", Author: "Jane Austen", Available: 1, ReorderPoint: 5, ReorderTarget: 8},
+			{BookID: 1, Title: "Dune", Author: "Frank Herbert", Available: 1, ReorderPoint: 3, ReorderTarget: 6},
+		}, report)
+		assert.Equal(7, report[0].Reorder())
+		assert.Equal(5, report[1].Reorder())
+	})
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Location is a place keeping copies of the books, e.g. a shop or a warehouse. The oldest one is the primary location,
+// where the copies go when no other one is told
+type Location struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// StockLevel keeps the copies of a book at a location, the quantity and reserved copies of a book are the sum of the
+// ones of its locations
+type StockLevel struct {
+	ID         uint `gorm:"primaryKey"`
+	LocationID uint
+	BookID     uint
+	Quantity   int
+	Reserved   int
+}
+
+var (
+	ErrLocationUnavailable = errors.New("the location doesn't exist")
+	ErrSameLocation        = errors.New("the copies can't be transferred to the same location")
+)
+
+// Available gives the copies at the location which aren't held by any reservation
+func (level *StockLevel) Available() int {
+	return max(level.Quantity-level.Reserved, 0)
+}
+
+// PrimaryLocation gives the oldest location
+func PrimaryLocation(database *gorm.DB) (*Location, error) {
+	location := &Location{}
+	return location, database.Order("id").First(location).Error
+}
+
+// FindLocation looks for the location of the identifier, or the primary one when it is zero
+func FindLocation(database *gorm.DB, identifier uint) (*Location, error) {
+	if identifier == 0 {
+		return PrimaryLocation(database)
+	}
+
+	location := &Location{}
+	exception := database.First(location, identifier).Error
+	if errors.Is(exception, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrLocationUnavailable, identifier)
+	}
+
+	return location, exception
+}
+
+// AfterCreate keeps the copies of a new book at the primary location
+func (book *Book) AfterCreate(transaction *gorm.DB) error {
+	database := transaction.Session(&gorm.Session{NewDB: true})
+	location, exception := PrimaryLocation(database)
+	if errors.Is(exception, gorm.ErrRecordNotFound) {
+		return nil
+	} else if exception != nil {
+		return exception
+	}
+
+	return database.Create(&StockLevel{LocationID: location.ID, BookID: book.ID, Quantity: book.Quantity, Reserved: book.Reserved}).Error
+}
+
+// StockLevels gives the copies of the book at every location keeping them, by location
+func StockLevels(database *gorm.DB, book *Book) ([]StockLevel, error) {
+	levels := []StockLevel{}
+	exception := database.Where("book_id = ?", book.ID).Order("location_id").Find(&levels).Error
+	return levels, exception
+}
+
+// stockAt changes the copies of the book at the location by the quantity, which is the first time for a location not
+// keeping it yet, as long as the copies reserved there aren't taken out
+func stockAt(transaction *gorm.DB, bookID uint, locationID uint, quantity int) error {
+	level := &StockLevel{LocationID: locationID, BookID: bookID}
+	if exception := transaction.Clauses(clause.OnConflict{DoNothing: true}).Create(level).Error; exception != nil {
+		return exception
+	}
+
+	update := transaction.Model(&StockLevel{}).
+		Where("location_id = ? AND book_id = ? AND quantity + ? >= reserved", locationID, bookID, quantity).
+		Update("quantity", gorm.Expr("quantity + ?", quantity))
+	if update.Error != nil {
+		return update.Error
+	} else if update.RowsAffected == 0 {
+		return fmt.Errorf("%w: %d at the location %d", ErrInsufficientStock, bookID, locationID)
+	}
+
+	return nil
+}
+
+// allocate holds the copies of the book at the preferred location first and then at the other ones, the oldest first,
+// giving a reservation per location holding any
+func allocate(transaction *gorm.DB, book *Book, quantity int, preferred uint) ([]Reservation, error) {
+	levels := []StockLevel{}
+	if exception := transaction.Where("book_id = ? AND quantity > reserved", book.ID).Order("location_id").Find(&levels).Error; exception != nil {
+		return nil, exception
+	}
+
+	slices.SortStableFunc(levels, func(this StockLevel, that StockLevel) int {
+		switch {
+		case this.LocationID == that.LocationID:
+			return 0
+		case this.LocationID == preferred:
+			return -1
+		case that.LocationID == preferred:
+			return 1
+		}
+
+		return 0
+	})
+
+	reservations := []Reservation{}
+	for _, level := range levels {
+		if quantity == 0 {
+			break
+		}
+
+		held := min(level.Available(), quantity)
+		hold := transaction.Model(&level).Where("quantity - reserved >= ?", held).Update("reserved", gorm.Expr("reserved + ?", held))
+		if hold.Error != nil {
+			return nil, hold.Error
+		} else if hold.RowsAffected == 0 {
+			continue
+		}
+
+		quantity -= held
+		reservations = append(reservations, Reservation{BookID: book.ID, LocationID: level.LocationID, Quantity: held})
+	}
+
+	if quantity > 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInsufficientStock, book.Title)
+	}
+
+	return reservations, nil
+}
+
+// Transfer moves copies of the book available at a location to another one for the user, recording both sides in the
+// ledger. The book keeps its copies, so only its version changes
+func (book *Book) Transfer(database *gorm.DB, user *User, from uint, to uint, quantity int, reason string) error {
+	if from == to {
+		return ErrSameLocation
+	} else if quantity <= 0 {
+		return fmt.Errorf("%w: %s of %d", ErrInvalidMovement, StockTransfer, quantity)
+	}
+
+	return database.Transaction(func(transaction *gorm.DB) error {
+		for _, identifier := range []uint{from, to} {
+			if _, exception := FindLocation(transaction, identifier); exception != nil {
+				return exception
+			}
+		}
+
+		update := transaction.Model(&StockLevel{}).
+			Where("location_id = ? AND book_id = ? AND quantity - reserved >= ?", from, book.ID, quantity).
+			Update("quantity", gorm.Expr("quantity - ?", quantity))
+		if update.Error != nil {
+			return update.Error
+		} else if update.RowsAffected == 0 {
+			return fmt.Errorf("%w: %q at the location %d", ErrInsufficientStock, book.Title, from)
+		}
+
+		if exception := stockAt(transaction, book.ID, to, quantity); exception != nil {
+			return exception
+		}
+
+		if exception := transaction.Model(book).Update("version", gorm.Expr("version + 1")).Error; exception != nil {
+			return exception
+		}
+
+		return transaction.Create([]StockMovement{
+			{BookID: book.ID, LocationID: from, Kind: StockTransfer, Quantity: -quantity, UserID: movedBy(user), Reason: reason, Reference: fmt.Sprintf("location:%d", to)},
+			{BookID: book.ID, LocationID: to, Kind: StockTransfer, Quantity: quantity, UserID: movedBy(user), Reason: reason, Reference: fmt.Sprintf("location:%d", from)},
+		}).Error
+	})
+}
+
+// AvailableAt scopes a query of books to the ones with copies available at the location
+func AvailableAt(database *gorm.DB, location uint) *gorm.DB {
+	available := database.Session(&gorm.Session{NewDB: true}).Model(&StockLevel{}).Select("book_id").Where("location_id = ? AND quantity > reserved", location)
+	return database.Where("id IN (?)", available)
+}
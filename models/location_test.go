@@ -0,0 +1,196 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupLocations(test *testing.T) (*gorm.DB, *User) {
+	database, user := setupOrders(test)
+	require.Nil(test, database.Create(&Location{Name: "Branch"}).Error)
+	return database, user
+}
+
+// levels plucks the copies available of the book at every location keeping it
+func levels(database *gorm.DB, bookID uint) map[uint]int {
+	stored := []StockLevel{}
+	database.Where("book_id = ?", bookID).Find(&stored)
+	available := map[uint]int{}
+	for _, level := range stored {
+		available[level.LocationID] = level.Available()
+	}
+
+	return available
+}
+
+func TestLocations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should keep the copies of the new books at the primary location", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		book := &Book{Title: "Persuasion", Author: "Jane Austen", Quantity: 3}
+
+		// Act
+		exception := book.Create(database, user)
+
+		// Assert
+		movements, _ := StockHistory(database, book)
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{1: 3}, levels(database, book.ID))
+		require.Len(test, movements, 1)
+		assert.Equal(uint(1), movements[0].LocationID)
+	})
+
+	test.Run("Should transfer the copies available between the locations", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		book := &Book{}
+		database.First(book, 3)
+
+		// Act
+		exception := book.Transfer(database, user, 1, 2, 3, "Opening the branch")
+
+		// Assert
+		movements, _ := StockHistory(database, book)
+		database.First(book, 3)
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{1: 1, 2: 3}, levels(database, 3))
+		assert.Equal(4, book.Quantity)
+		require.Len(test, movements, 2)
+		assert.Equal(StockTransfer, movements[0].Kind)
+		assert.Equal(-3, movements[0].Quantity)
+		assert.Equal("location:2", movements[0].Reference)
+		assert.Equal(uint(2), movements[1].LocationID)
+		assert.Equal(3, movements[1].Quantity)
+	})
+
+	test.Run("Should NOT transfer the copies reserved, to the same location nor to unknown ones", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		_, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		require.Nil(test, exception)
+		book := &Book{}
+		database.First(book, 1)
+
+		// Act
+		reserved := book.Transfer(database, user, 1, 2, 2, "")
+		same := book.Transfer(database, user, 1, 1, 1, "")
+		unknown := book.Transfer(database, user, 1, 9, 1, "")
+		empty := book.Transfer(database, user, 1, 2, 0, "")
+
+		// Assert
+		assert.ErrorIs(reserved, ErrInsufficientStock)
+		assert.ErrorIs(same, ErrSameLocation)
+		assert.ErrorIs(unknown, ErrLocationUnavailable)
+		assert.ErrorIs(empty, ErrInvalidMovement)
+		assert.Equal(map[uint]int{1: 1}, levels(database, 1))
+	})
+
+	test.Run("Should reserve at the preferred location falling back to the other ones", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		book := &Book{}
+		database.First(book, 1)
+		require.Nil(test, book.Transfer(database, user, 1, 2, 1, ""))
+
+		// Act
+		preferred, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 2)
+		require.Nil(test, exception)
+		fallback, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 2)
+
+		// Assert
+		assert.Nil(exception)
+		require.Len(test, preferred.Reservations, 1)
+		assert.Equal(uint(2), preferred.Reservations[0].LocationID)
+		require.Len(test, fallback.Reservations, 1)
+		assert.Equal(uint(1), fallback.Reservations[0].LocationID)
+		assert.Equal(map[uint]int{1: 0, 2: 0}, levels(database, 1))
+	})
+
+	test.Run("Should split the copies of a line among the locations", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		book := &Book{}
+		database.First(book, 1)
+		require.Nil(test, book.Transfer(database, user, 1, 2, 1, ""))
+
+		// Act
+		order, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 2}}, time.Now().Add(time.Hour), 0)
+
+		// Assert
+		assert.Nil(exception)
+		require.Len(test, order.Reservations, 2)
+		assert.Equal(uint(1), order.Reservations[0].LocationID)
+		assert.Equal(uint(2), order.Reservations[1].LocationID)
+		assert.Equal([]int{0, 1, 4}, stock(database))
+	})
+
+	test.Run("Should sell from the locations reserved and return the copies there", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		book := &Book{}
+		database.First(book, 1)
+		require.Nil(test, book.Transfer(database, user, 1, 2, 1, ""))
+		order, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 2)
+		require.Nil(test, exception)
+
+		// Act
+		require.Nil(test, order.MoveTo(database, OrderPaid))
+		sold := levels(database, 1)
+		exception = order.MoveTo(database, OrderCancelled)
+
+		// Assert
+		movements, _ := StockHistory(database, book)
+		assert.Nil(exception)
+		assert.Equal(map[uint]int{1: 1, 2: 0}, sold)
+		assert.Equal(map[uint]int{1: 1, 2: 1}, levels(database, 1))
+		require.Len(test, movements, 4)
+		assert.Equal(StockSale, movements[2].Kind)
+		assert.Equal(uint(2), movements[2].LocationID)
+		assert.Equal(StockReturn, movements[3].Kind)
+		assert.Equal(uint(2), movements[3].LocationID)
+	})
+
+	test.Run("Should list the books available at a location", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		book := &Book{}
+		database.First(book, 3)
+		require.Nil(test, book.Transfer(database, user, 1, 2, 4, ""))
+
+		// Act
+		primary, branch := []uint{}, []uint{}
+		exception := AvailableAt(database, 1).Model(&Book{}).Order("id").Pluck("id", &primary).Error
+		require.Nil(test, exception)
+		exception = AvailableAt(database, 2).Model(&Book{}).Order("id").Pluck("id", &branch).Error
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal([]uint{1, 2}, primary)
+		assert.Equal([]uint{3}, branch)
+	})
+
+	test.Run("Should reconcile the copies at every location with the ledger", func(test *testing.T) {
+		// Arrange
+		database, user := setupLocations(test)
+		book := &Book{Title: "Persuasion", Author: "Jane Austen", Quantity: 3}
+		require.Nil(test, book.Create(database, user))
+		require.Nil(test, book.Transfer(database, user, 1, 2, 1, ""))
+		database.Model(&StockLevel{}).Where("book_id = ?", book.ID).Update("quantity", 7)
+		database.Model(book).Update("quantity", 14)
+
+		// Act
+		_, exception := Reconcile(database)
+
+		// Assert
+		database.First(book, book.ID)
+		assert.Nil(exception)
+		assert.Equal(3, book.Quantity)
+		assert.Equal(map[uint]int{1: 2, 2: 1}, levels(database, book.ID))
+	})
+}
@@ -0,0 +1,251 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zatarain/bookshop/money"
+	"gorm.io/gorm"
+)
+
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+)
+
+// The statuses every order can move to from its current one, the delivered and cancelled orders are final
+var orderTransitions = map[string][]string{
+	OrderPending: {OrderPaid, OrderCancelled},
+	OrderPaid:    {OrderShipped, OrderCancelled},
+	OrderShipped: {OrderDelivered},
+}
+
+// Order records who bought which books and the prices they had at the time
+type Order struct {
+	ID           uint `gorm:"primaryKey"`
+	UserID       int
+	Status       string
+	Total        money.Money `gorm:"embedded;embeddedPrefix:total_"`
+	Lines        []OrderLine
+	Payments     []Payment
+	Reservations []Reservation
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	// The books this order took below their reorder point when it was placed
+	LowStock []LowStock `gorm:"-"`
+}
+
+// OrderLine keeps a snapshot of the book bought, so the order doesn't change when the book does
+type OrderLine struct {
+	ID        uint `gorm:"primaryKey"`
+	OrderID   uint
+	BookID    uint
+	Title     string
+	Author    string
+	Quantity  int
+	UnitPrice money.Money `gorm:"embedded;embeddedPrefix:unit_price_"`
+}
+
+var (
+	ErrBookUnavailable   = errors.New("the book is no longer available")
+	ErrBookNotPriced     = errors.New("the book has no price in the currency")
+	ErrInsufficientStock = errors.New("there are not enough copies of the book")
+	ErrEmptyOrder        = errors.New("the order has no books")
+	ErrInvalidTransition = errors.New("the order can't move to that status")
+)
+
+func (line *OrderLine) Subtotal() money.Money {
+	subtotal, _ := line.UnitPrice.Multiply(int64(line.Quantity))
+	return subtotal
+}
+
+// PlaceOrder reserves the copies of the books of the lines until the expiry and records them in a pending order for
+// the user with their current prices in the currency, either everything is reserved or nothing is. The copies are held
+// at the preferred location as long as it has them and at the other ones otherwise, then they are taken out of the
+// stock when the order is paid. The books running low are listed in the order
+func PlaceOrder(database *gorm.DB, user *User, currency string, lines []OrderLine, expiry time.Time, preferred uint) (*Order, error) {
+	if len(lines) == 0 {
+		return nil, ErrEmptyOrder
+	}
+
+	order := &Order{UserID: user.ID, Status: OrderPending, Total: money.Money{Currency: currency}}
+	exception := database.Transaction(func(transaction *gorm.DB) error {
+		for _, line := range lines {
+			book := &Book{}
+			exception := transaction.Preload("Prices").First(book, line.BookID).Error
+			if errors.Is(exception, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %d", ErrBookUnavailable, line.BookID)
+			} else if exception != nil {
+				return exception
+			}
+
+			price, priced := book.PriceIn(currency)
+			if !priced {
+				return fmt.Errorf("%w: %q", ErrBookNotPriced, book.Title)
+			}
+
+			if exception := reserve(transaction, book, line.Quantity); exception != nil {
+				return exception
+			}
+
+			reservations, exception := allocate(transaction, book, line.Quantity, preferred)
+			if exception != nil {
+				return exception
+			}
+
+			if low, ok := book.lowAfter(line.Quantity); ok {
+				order.LowStock = append(order.LowStock, low)
+			}
+
+			line.Title, line.Author, line.UnitPrice = book.Title, book.Author, price
+			if order.Total, exception = order.Total.Add(line.Subtotal()); exception != nil {
+				return exception
+			}
+
+			order.Lines = append(order.Lines, line)
+			for _, reservation := range reservations {
+				reservation.ExpiresAt = expiry
+				order.Reservations = append(order.Reservations, reservation)
+			}
+		}
+
+		return transaction.Create(order).Error
+	})
+	if exception != nil {
+		return nil, exception
+	}
+
+	return order, nil
+}
+
+// CanMoveTo tells whether the order can move from its current status to the given one
+func (order *Order) CanMoveTo(status string) bool {
+	return slices.Contains(orderTransitions[order.Status], status)
+}
+
+// MoveTo changes the status of the order as long as nobody moved it since it was read. The copies reserved are taken out
+// of the stock when the order is paid or released when it is cancelled, and the ones of the paid orders cancelled go
+// back to the locations they were sold from, recording the sales and returns in the ledger
+func (order *Order) MoveTo(database *gorm.DB, status string) error {
+	if !order.CanMoveTo(status) {
+		return fmt.Errorf("%w: from %s to %s", ErrInvalidTransition, order.Status, status)
+	}
+
+	previous := order.Status
+	return database.Transaction(func(transaction *gorm.DB) error {
+		update := transaction.Model(order).Where("status = ?", previous).Update("status", status)
+		if update.Error != nil {
+			return update.Error
+		} else if update.RowsAffected == 0 {
+			return fmt.Errorf("%w: the order was moved by someone else", ErrInvalidTransition)
+		}
+
+		if previous == OrderPending {
+			return settleReservations(transaction, order, status == OrderPaid)
+		}
+
+		if status != OrderCancelled {
+			return nil
+		}
+
+		return returnSales(transaction, order)
+	})
+}
+
+// cancelPendingOrders cancels the pending orders of the books, e.g. the ones being purged, which releases every copy
+// they hold so none of them is left waiting for a book which is gone
+func cancelPendingOrders(transaction *gorm.DB, books any) error {
+	lines := transaction.Session(&gorm.Session{NewDB: true}).Model(&OrderLine{}).Select("order_id").Where("book_id IN (?)", books)
+	return cancelOrders(transaction, transaction.Where("status = ? AND id IN (?)", OrderPending, lines))
+}
+
+// cancelPendingOrdersOf cancels the pending orders of the users, e.g. the ones being purged, so the copies they hold
+// go back to the stock instead of waiting for their reservations to expire
+func cancelPendingOrdersOf(transaction *gorm.DB, users any) error {
+	return cancelOrders(transaction, transaction.Where("status = ? AND user_id IN (?)", OrderPending, users))
+}
+
+func cancelOrders(transaction *gorm.DB, query *gorm.DB) error {
+	orders := []Order{}
+	if exception := query.Find(&orders).Error; exception != nil {
+		return exception
+	}
+
+	for index := range orders {
+		if exception := orders[index].MoveTo(transaction, OrderCancelled); exception != nil {
+			return exception
+		}
+	}
+
+	return nil
+}
+
+// returnSales brings the copies of the lines of a paid order back to the locations they were sold from, which is the
+// primary one for the ones sold before the locations were recorded
+func returnSales(transaction *gorm.DB, order *Order) error {
+	lines := []OrderLine{}
+	if exception := transaction.Where("order_id = ?", order.ID).Find(&lines).Error; exception != nil {
+		return exception
+	}
+
+	sales := []StockMovement{}
+	exception := transaction.Where("reference = ? AND kind = ?", fmt.Sprintf("order:%d", order.ID), StockSale).Order("id").Find(&sales).Error
+	if exception != nil {
+		return exception
+	}
+
+	primary, exception := PrimaryLocation(transaction)
+	if exception != nil {
+		return exception
+	}
+
+	for _, line := range lines {
+		remaining := line.Quantity
+		for index := range sales {
+			if sales[index].BookID != line.BookID || sales[index].Quantity == 0 || remaining == 0 {
+				continue
+			}
+
+			returned := min(-sales[index].Quantity, remaining)
+			if exception := restock(transaction, order, line.BookID, sales[index].LocationID, returned); exception != nil {
+				return exception
+			}
+
+			sales[index].Quantity += returned
+			remaining -= returned
+		}
+
+		if remaining == 0 {
+			continue
+		}
+
+		if exception := restock(transaction, order, line.BookID, primary.ID, remaining); exception != nil {
+			return exception
+		}
+	}
+
+	return nil
+}
+
+// restock gives the copies of a book returned by an order back to the location, even when the book was deleted, so it
+// has them back if it is restored
+func restock(transaction *gorm.DB, order *Order, bookID uint, locationID uint, quantity int) error {
+	exception := transaction.Unscoped().Model(&Book{ID: bookID}).Updates(map[string]any{
+		"quantity": gorm.Expr("quantity + ?", quantity),
+		"version":  gorm.Expr("version + 1"),
+	}).Error
+	if exception != nil {
+		return exception
+	}
+
+	if exception := stockAt(transaction, bookID, locationID, quantity); exception != nil {
+		return exception
+	}
+
+	return orderMovement(transaction, order, bookID, locationID, StockReturn, quantity)
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/money"
+	"gorm.io/gorm"
+)
+
+func setupOrders(test *testing.T) (*gorm.DB, *User) {
+	database := setupBooks(test)
+	require.Nil(test, database.Create(&Book{Title: "Dune", Author: "Frank Herbert", Prices: []BookPrice{{Price: money.MustParse("9.99", "GBP")}}, Quantity: 2}).Error)
+	require.Nil(test, database.Create(&Book{Title: "Emma", Author: "Jane Austen", Prices: []BookPrice{{Price: money.MustParse("5.50", "GBP")}}, Quantity: 1}).Error)
+	require.Nil(test, database.Create(&Book{Title: "Ulysses", Author: "James Joyce", Quantity: 4}).Error)
+	user := &User{Nickname: "dummy-user", Password: "hash"}
+	require.Nil(test, database.Create(user).Error)
+	return database, user
+}
+
+func stock(database *gorm.DB) []int {
+	quantities := []int{}
+	database.Unscoped().Model(&Book{}).Order("id").Pluck("quantity - reserved", &quantities)
+	return quantities
+}
+
+func TestPlaceOrder(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should take the copies and record their current prices", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+
+		// Act
+		order, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 2}, {BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Assert
+		stored := &Order{}
+		database.Preload("Lines").First(stored, order.ID)
+		assert.Nil(exception)
+		assert.Equal(OrderPending, stored.Status)
+		assert.Equal(user.ID, stored.UserID)
+		assert.Equal(money.MustParse("25.48", "GBP"), stored.Total)
+		require.Len(test, stored.Lines, 2)
+		assert.Equal("Dune", stored.Lines[0].Title)
+		assert.Equal(money.MustParse("9.99", "GBP"), stored.Lines[0].UnitPrice)
+		assert.Equal(money.MustParse("19.98", "GBP"), stored.Lines[0].Subtotal())
+		assert.Equal([]int{0, 0, 4}, stock(database))
+	})
+
+	testcases := []struct {
+		description string
+		lines       []OrderLine
+		expected    error
+	}{
+		{"there are not enough copies", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 2}}, ErrInsufficientStock},
+		{"the book has no price", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 3, Quantity: 1}}, ErrBookNotPriced},
+		{"the book doesn't exist", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 9, Quantity: 1}}, ErrBookUnavailable},
+		{"there are no books", []OrderLine{}, ErrEmptyOrder},
+	}
+
+	for _, testcase := range testcases {
+		test.Run("Should NOT take any copy when "+testcase.description, func(test *testing.T) {
+			// Arrange
+			database, user := setupOrders(test)
+
+			// Act
+			order, exception := PlaceOrder(database, user, "GBP", testcase.lines, time.Now().Add(time.Hour), 0)
+
+			// Assert
+			var orders int64
+			database.Model(&Order{}).Count(&orders)
+			assert.Nil(order)
+			assert.ErrorIs(exception, testcase.expected)
+			assert.Zero(orders)
+			assert.Equal([]int{2, 1, 4}, stock(database))
+		})
+	}
+
+	test.Run("Should take every copy once and nothing else when the checkouts are concurrent", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		book := &Book{Title: "Persuasion", Author: "Jane Austen", Prices: []BookPrice{{Price: money.MustParse("7.50", "GBP")}}, Quantity: 5}
+		require.Nil(test, database.Create(book).Error)
+		failures := make(chan error, 20)
+		var checkouts sync.WaitGroup
+
+		// Act
+		for attempt := 0; attempt < 20; attempt++ {
+			checkouts.Add(1)
+			go func() {
+				defer checkouts.Done()
+				_, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: book.ID, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+				failures <- exception
+			}()
+		}
+		checkouts.Wait()
+		close(failures)
+
+		// Assert
+		placed := 0
+		for exception := range failures {
+			if exception == nil {
+				placed++
+				continue
+			}
+
+			assert.ErrorIs(exception, ErrInsufficientStock)
+		}
+		assert.Equal(5, placed)
+		assert.Equal([]int{2, 1, 4, 0}, stock(database))
+	})
+}
+
+func TestOrderMoveTo(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should move the order through its lifecycle", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		order, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Act
+		paid := order.MoveTo(database, OrderPaid)
+		shipped := order.MoveTo(database, OrderShipped)
+		delivered := order.MoveTo(database, OrderDelivered)
+
+		// Assert
+		stored := &Order{}
+		database.First(stored, order.ID)
+		assert.Nil(paid)
+		assert.Nil(shipped)
+		assert.Nil(delivered)
+		assert.Equal(OrderDelivered, stored.Status)
+		assert.Equal([]int{1, 1, 4}, stock(database))
+	})
+
+	test.Run("Should put the copies of the cancelled order back in the stock", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		order, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 2}, {BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		database.Delete(&Book{}, 2)
+
+		// Act
+		exception := order.MoveTo(database, OrderCancelled)
+
+		// Assert
+		book := &Book{}
+		database.First(book, 1)
+		assert.Nil(exception)
+		assert.Equal(OrderCancelled, order.Status)
+		assert.Equal([]int{2, 1, 4}, stock(database))
+		assert.Equal(uint(3), book.Version)
+	})
+
+	test.Run("Should NOT skip the steps of the lifecycle", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		order, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Act
+		exception := order.MoveTo(database, OrderShipped)
+
+		// Assert
+		assert.ErrorIs(exception, ErrInvalidTransition)
+		assert.Equal(OrderPending, order.Status)
+	})
+
+	test.Run("Should NOT cancel an order twice", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		order, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		stale := &Order{}
+		database.First(stale, order.ID)
+		require.Nil(test, order.MoveTo(database, OrderCancelled))
+
+		// Act
+		exception := stale.MoveTo(database, OrderCancelled)
+
+		// Assert
+		assert.ErrorIs(exception, ErrInvalidTransition)
+		assert.Equal([]int{2, 1, 4}, stock(database))
+	})
+}
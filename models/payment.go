@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/payments"
+)
+
+// Payment records a charge of an order at the payment provider and its status there
+type Payment struct {
+	ID        uint `gorm:"primaryKey"`
+	OrderID   uint
+	Provider  string
+	Reference string
+	Status    payments.Status
+	Amount    money.Money `gorm:"embedded"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The purchase orders are drafted, sent to their supplier and then received, maybe in several deliveries
+const (
+	PurchaseDraft             = "draft"
+	PurchaseSent              = "sent"
+	PurchasePartiallyReceived = "partially_received"
+	PurchaseReceived          = "received"
+)
+
+// Supplier sells the copies the shop restocks
+type Supplier struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Email     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// PurchaseOrder asks a supplier for copies of books, which are brought into the stock as they are received
+type PurchaseOrder struct {
+	ID         uint `gorm:"primaryKey"`
+	SupplierID uint
+	UserID     *int
+	Status     string
+	Lines      []PurchaseOrderLine
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// PurchaseOrderLine is how many copies of a book were ordered and how many of them were received so far
+type PurchaseOrderLine struct {
+	ID              uint `gorm:"primaryKey"`
+	PurchaseOrderID uint
+	BookID          uint
+	Quantity        int
+	Received        int
+}
+
+// Backlog is a supplier with its purchase orders still waiting for copies
+type Backlog struct {
+	Supplier Supplier
+	Orders   []PurchaseOrder
+}
+
+var (
+	ErrSupplierUnavailable = errors.New("the supplier doesn't exist")
+	ErrBookNotOrdered      = errors.New("the book is not in the purchase order")
+	ErrExcessReceipt       = errors.New("there are not that many copies outstanding")
+)
+
+// Outstanding gives the copies ordered which weren't received yet
+func (line *PurchaseOrderLine) Outstanding() int {
+	return line.Quantity - line.Received
+}
+
+// Outstanding gives the copies of every line of the purchase order which weren't received yet
+func (purchase *PurchaseOrder) Outstanding() int {
+	outstanding := 0
+	for index := range purchase.Lines {
+		outstanding += purchase.Lines[index].Outstanding()
+	}
+
+	return outstanding
+}
+
+// PlacePurchaseOrder drafts an order of the user to the supplier for the copies of the lines, the lines of the same
+// book are merged
+func PlacePurchaseOrder(database *gorm.DB, supplierID uint, user *User, lines []PurchaseOrderLine) (*PurchaseOrder, error) {
+	if len(lines) == 0 {
+		return nil, ErrEmptyOrder
+	}
+
+	purchase := &PurchaseOrder{SupplierID: supplierID, UserID: movedBy(user), Status: PurchaseDraft}
+	exception := database.Transaction(func(transaction *gorm.DB) error {
+		exception := transaction.First(&Supplier{}, supplierID).Error
+		if errors.Is(exception, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %d", ErrSupplierUnavailable, supplierID)
+		} else if exception != nil {
+			return exception
+		}
+
+		merged := map[uint]int{}
+		for _, line := range lines {
+			if index, found := merged[line.BookID]; found {
+				purchase.Lines[index].Quantity += line.Quantity
+				continue
+			}
+
+			exception := transaction.First(&Book{}, line.BookID).Error
+			if errors.Is(exception, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %d", ErrBookUnavailable, line.BookID)
+			} else if exception != nil {
+				return exception
+			}
+
+			merged[line.BookID] = len(purchase.Lines)
+			purchase.Lines = append(purchase.Lines, PurchaseOrderLine{BookID: line.BookID, Quantity: line.Quantity})
+		}
+
+		return transaction.Create(purchase).Error
+	})
+	if exception != nil {
+		return nil, exception
+	}
+
+	return purchase, nil
+}
+
+// Send tells the purchase order was sent to its supplier, so its copies can be received
+func (purchase *PurchaseOrder) Send(database *gorm.DB) error {
+	if purchase.Status != PurchaseDraft {
+		return fmt.Errorf("%w: from %s to %s", ErrInvalidTransition, purchase.Status, PurchaseSent)
+	}
+
+	update := database.Model(purchase).Where("status = ?", PurchaseDraft).Update("status", PurchaseSent)
+	if update.Error != nil {
+		return update.Error
+	} else if update.RowsAffected == 0 {
+		return fmt.Errorf("%w: the purchase order was moved by someone else", ErrInvalidTransition)
+	}
+
+	return nil
+}
+
+// Receive brings the copies delivered by the supplier into the stock of the location, the primary one when it is zero,
+// as receipts made by the user, as long as they are outstanding. The purchase order is received once every copy is,
+// either every delivery is received or none
+func (purchase *PurchaseOrder) Receive(database *gorm.DB, user *User, location uint, deliveries []PurchaseOrderLine) error {
+	if purchase.Status != PurchaseSent && purchase.Status != PurchasePartiallyReceived {
+		return fmt.Errorf("%w: the purchase order is %s", ErrInvalidTransition, purchase.Status)
+	} else if len(deliveries) == 0 {
+		return ErrEmptyOrder
+	}
+
+	return database.Transaction(func(transaction *gorm.DB) error {
+		supplier := &Supplier{}
+		if exception := transaction.First(supplier, purchase.SupplierID).Error; exception != nil {
+			return exception
+		}
+
+		for _, delivery := range deliveries {
+			line := &PurchaseOrderLine{}
+			exception := transaction.Where("purchase_order_id = ? AND book_id = ?", purchase.ID, delivery.BookID).First(line).Error
+			if errors.Is(exception, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %d", ErrBookNotOrdered, delivery.BookID)
+			} else if exception != nil {
+				return exception
+			}
+
+			// Only the copies outstanding are received, so concurrent deliveries can't receive more than ordered
+			update := transaction.Model(line).Where("received + ? <= quantity", delivery.Quantity).Update("received", gorm.Expr("received + ?", delivery.Quantity))
+			if update.Error != nil {
+				return update.Error
+			} else if update.RowsAffected == 0 {
+				return fmt.Errorf("%w: %d of the book %d", ErrExcessReceipt, delivery.Quantity, delivery.BookID)
+			}
+
+			book := &Book{}
+			exception = transaction.First(book, delivery.BookID).Error
+			if errors.Is(exception, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %d", ErrBookUnavailable, delivery.BookID)
+			} else if exception != nil {
+				return exception
+			}
+
+			exception = book.Record(transaction, &StockMovement{
+				LocationID: location,
+				Kind:       StockReceipt,
+				Quantity:   delivery.Quantity,
+				UserID:     movedBy(user),
+				Reason:     "Received from " + supplier.Name,
+				Reference:  fmt.Sprintf("purchase_order:%d", purchase.ID),
+			})
+			if exception != nil {
+				return exception
+			}
+		}
+
+		var outstanding int64
+		exception := transaction.Model(&PurchaseOrderLine{}).Where("purchase_order_id = ? AND received < quantity", purchase.ID).Count(&outstanding).Error
+		if exception != nil {
+			return exception
+		}
+
+		status := PurchaseReceived
+		if outstanding > 0 {
+			status = PurchasePartiallyReceived
+		}
+
+		return transaction.Model(purchase).Update("status", status).Error
+	})
+}
+
+// OutstandingPurchaseOrders gives the suppliers, by name, with their purchase orders sent and still waiting for copies
+func OutstandingPurchaseOrders(database *gorm.DB) ([]Backlog, error) {
+	purchases := []PurchaseOrder{}
+	exception := database.Preload("Lines", func(database *gorm.DB) *gorm.DB {
+		return database.Order("id")
+	}).Where("status IN ?", []string{PurchaseSent, PurchasePartiallyReceived}).Order("id").Find(&purchases).Error
+	if exception != nil {
+		return nil, exception
+	} else if len(purchases) == 0 {
+		return []Backlog{}, nil
+	}
+
+	identifiers := []uint{}
+	for _, purchase := range purchases {
+		identifiers = append(identifiers, purchase.SupplierID)
+	}
+
+	suppliers := []Supplier{}
+	if exception := database.Where("id IN ?", identifiers).Order("name").Find(&suppliers).Error; exception != nil {
+		return nil, exception
+	}
+
+	backlogs := make([]Backlog, len(suppliers))
+	for index, supplier := range suppliers {
+		backlogs[index].Supplier = supplier
+		for _, purchase := range purchases {
+			if purchase.SupplierID == supplier.ID {
+				backlogs[index].Orders = append(backlogs[index].Orders, purchase)
+			}
+		}
+	}
+
+	return backlogs, nil
+}
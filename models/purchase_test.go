@@ -0,0 +1,148 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupPurchases(test *testing.T) (*gorm.DB, *User) {
+	database, user := setupOrders(test)
+	require.Nil(test, database.Create(&Supplier{Name: "Penguin", Email: "orders@penguin.example"}).Error)
+	require.Nil(test, database.Create(&Supplier{Name: "Faber", Email: "trade@faber.example"}).Error)
+	return database, user
+}
+
+func TestPurchaseOrders(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should draft the purchase order merging the lines of the same book", func(test *testing.T) {
+		// Arrange
+		database, user := setupPurchases(test)
+
+		// Act
+		purchase, exception := PlacePurchaseOrder(database, 1, user, []PurchaseOrderLine{{BookID: 1, Quantity: 5}, {BookID: 2, Quantity: 2}, {BookID: 1, Quantity: 3}})
+
+		// Assert
+		stored := &PurchaseOrder{}
+		database.Preload("Lines").First(stored, purchase.ID)
+		assert.Nil(exception)
+		assert.Equal(PurchaseDraft, stored.Status)
+		assert.Equal(user.ID, *stored.UserID)
+		require.Len(test, stored.Lines, 2)
+		assert.Equal(8, stored.Lines[0].Quantity)
+		assert.Equal(2, stored.Lines[1].Quantity)
+		assert.Equal(10, stored.Outstanding())
+	})
+
+	test.Run("Should NOT draft purchase orders without lines, supplier or books", func(test *testing.T) {
+		// Arrange
+		database, user := setupPurchases(test)
+
+		// Act
+		_, empty := PlacePurchaseOrder(database, 1, user, nil)
+		_, unknownSupplier := PlacePurchaseOrder(database, 9, user, []PurchaseOrderLine{{BookID: 1, Quantity: 1}})
+		_, unknownBook := PlacePurchaseOrder(database, 1, user, []PurchaseOrderLine{{BookID: 9, Quantity: 1}})
+
+		// Assert
+		var purchases int64
+		database.Model(&PurchaseOrder{}).Count(&purchases)
+		assert.ErrorIs(empty, ErrEmptyOrder)
+		assert.ErrorIs(unknownSupplier, ErrSupplierUnavailable)
+		assert.ErrorIs(unknownBook, ErrBookUnavailable)
+		assert.Zero(purchases)
+	})
+
+	test.Run("Should only receive the purchase orders sent", func(test *testing.T) {
+		// Arrange
+		database, user := setupPurchases(test)
+		purchase, _ := PlacePurchaseOrder(database, 1, user, []PurchaseOrderLine{{BookID: 1, Quantity: 5}})
+
+		// Act
+		draft := purchase.Receive(database, user, 0, []PurchaseOrderLine{{BookID: 1, Quantity: 1}})
+		sending := purchase.Send(database)
+		again := purchase.Send(database)
+
+		// Assert
+		assert.ErrorIs(draft, ErrInvalidTransition)
+		assert.Nil(sending)
+		assert.Equal(PurchaseSent, purchase.Status)
+		assert.ErrorIs(again, ErrInvalidTransition)
+	})
+
+	test.Run("Should bring the copies received into the stock until every one is", func(test *testing.T) {
+		// Arrange
+		database, user := setupPurchases(test)
+		purchase, _ := PlacePurchaseOrder(database, 1, user, []PurchaseOrderLine{{BookID: 1, Quantity: 5}, {BookID: 2, Quantity: 2}})
+		require.Nil(test, purchase.Send(database))
+
+		// Act
+		partially := purchase.Receive(database, user, 0, []PurchaseOrderLine{{BookID: 1, Quantity: 3}})
+		status := purchase.Status
+		completely := purchase.Receive(database, user, 0, []PurchaseOrderLine{{BookID: 1, Quantity: 2}, {BookID: 2, Quantity: 2}})
+
+		// Assert
+		movements, _ := StockHistory(database, &Book{ID: 1})
+		assert.Nil(partially)
+		assert.Equal(PurchasePartiallyReceived, status)
+		assert.Nil(completely)
+		assert.Equal(PurchaseReceived, purchase.Status)
+		assert.Equal([]int{7, 3, 4}, stock(database))
+		require.Len(test, movements, 2)
+		assert.Equal(StockReceipt, movements[1].Kind)
+		assert.Equal(2, movements[1].Quantity)
+		assert.Equal("Received from Penguin", movements[1].Reason)
+		assert.Equal("purchase_order:1", movements[1].Reference)
+	})
+
+	test.Run("Should NOT receive more copies than outstanding nor books not ordered", func(test *testing.T) {
+		// Arrange
+		database, user := setupPurchases(test)
+		purchase, _ := PlacePurchaseOrder(database, 1, user, []PurchaseOrderLine{{BookID: 1, Quantity: 5}})
+		require.Nil(test, purchase.Send(database))
+
+		// Act
+		excess := purchase.Receive(database, user, 0, []PurchaseOrderLine{{BookID: 1, Quantity: 4}, {BookID: 1, Quantity: 2}})
+		unordered := purchase.Receive(database, user, 0, []PurchaseOrderLine{{BookID: 2, Quantity: 1}})
+
+		// Assert
+		line := &PurchaseOrderLine{}
+		database.First(line)
+		assert.ErrorIs(excess, ErrExcessReceipt)
+		assert.ErrorIs(unordered, ErrBookNotOrdered)
+		assert.Zero(line.Received)
+		assert.Equal(PurchaseSent, purchase.Status)
+		assert.Equal([]int{2, 1, 4}, stock(database))
+	})
+
+	test.Run("Should list the purchase orders outstanding by supplier", func(test *testing.T) {
+		// Arrange
+		database, user := setupPurchases(test)
+		_, exception := PlacePurchaseOrder(database, 1, user, []PurchaseOrderLine{{BookID: 1, Quantity: 1}})
+		require.Nil(test, exception)
+		penguin, _ := PlacePurchaseOrder(database, 1, user, []PurchaseOrderLine{{BookID: 1, Quantity: 4}})
+		faber, _ := PlacePurchaseOrder(database, 2, user, []PurchaseOrderLine{{BookID: 2, Quantity: 3}})
+		received, _ := PlacePurchaseOrder(database, 2, user, []PurchaseOrderLine{{BookID: 2, Quantity: 1}})
+		for _, purchase := range []*PurchaseOrder{penguin, faber, received} {
+			require.Nil(test, purchase.Send(database))
+		}
+		require.Nil(test, faber.Receive(database, user, 0, []PurchaseOrderLine{{BookID: 2, Quantity: 1}}))
+		require.Nil(test, received.Receive(database, user, 0, []PurchaseOrderLine{{BookID: 2, Quantity: 1}}))
+
+		// Act
+		backlogs, exception := OutstandingPurchaseOrders(database)
+
+		// Assert
+		assert.Nil(exception)
+		require.Len(test, backlogs, 2)
+		assert.Equal("Faber", backlogs[0].Supplier.Name)
+		require.Len(test, backlogs[0].Orders, 1)
+		assert.Equal(faber.ID, backlogs[0].Orders[0].ID)
+		assert.Equal(2, backlogs[0].Orders[0].Outstanding())
+		assert.Equal("Penguin", backlogs[1].Supplier.Name)
+		require.Len(test, backlogs[1].Orders, 1)
+		assert.Equal(penguin.ID, backlogs[1].Orders[0].ID)
+	})
+}
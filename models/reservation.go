@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reservation holds copies of a book at a location for a pending order until it expires, so they can't be sold to
+// anyone else while the order is paid
+type Reservation struct {
+	ID         uint `gorm:"primaryKey"`
+	OrderID    uint
+	BookID     uint
+	LocationID uint
+	Quantity   int
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
+
+// Available gives the copies of the book which aren't held by any reservation
+func (book *Book) Available() int {
+	return max(book.Quantity-book.Reserved, 0)
+}
+
+// reserve holds the copies of the book only when there are enough of them available, so concurrent orders can't
+// oversell
+func reserve(transaction *gorm.DB, book *Book, quantity int) error {
+	hold := transaction.Model(book).Where("quantity - reserved >= ?", quantity).Updates(map[string]any{
+		"reserved": gorm.Expr("reserved + ?", quantity),
+		"version":  gorm.Expr("version + 1"),
+	})
+	if hold.Error != nil {
+		return hold.Error
+	} else if hold.RowsAffected == 0 {
+		return fmt.Errorf("%w: %q", ErrInsufficientStock, book.Title)
+	}
+
+	return nil
+}
+
+// settleReservations drops the reservations of the order, taking their copies out of the stock as sales when they are
+// sold or giving them back to the available ones otherwise
+func settleReservations(transaction *gorm.DB, order *Order, sold bool) error {
+	reservations := []Reservation{}
+	if exception := transaction.Where("order_id = ?", order.ID).Find(&reservations).Error; exception != nil {
+		return exception
+	}
+
+	for _, reservation := range reservations {
+		changes := map[string]any{
+			"reserved": gorm.Expr("reserved - ?", reservation.Quantity),
+			"version":  gorm.Expr("version + 1"),
+		}
+		if sold {
+			changes["quantity"] = gorm.Expr("quantity - ?", reservation.Quantity)
+		}
+
+		// Even the deleted books, so they have the right stock if they are restored
+		if exception := transaction.Unscoped().Model(&Book{ID: reservation.BookID}).Updates(changes).Error; exception != nil {
+			return exception
+		}
+
+		delete(changes, "version")
+		exception := transaction.Model(&StockLevel{}).
+			Where("location_id = ? AND book_id = ?", reservation.LocationID, reservation.BookID).
+			Updates(changes).Error
+		if exception != nil {
+			return exception
+		}
+
+		if !sold {
+			continue
+		}
+
+		if exception := orderMovement(transaction, order, reservation.BookID, reservation.LocationID, StockSale, -reservation.Quantity); exception != nil {
+			return exception
+		}
+	}
+
+	return transaction.Where("order_id = ?", order.ID).Delete(&Reservation{}).Error
+}
+
+// ReleaseExpiredReservations cancels the pending orders whose reservations expired before the given time, which gives
+// their copies back. The orders paid meanwhile are left alone, the reservations of the orders which no longer exist
+// are dropped and the orders failing are skipped, so one of them can't stop the sweep of the others
+func ReleaseExpiredReservations(database *gorm.DB, before time.Time) (int64, error) {
+	identifiers := []uint{}
+	exception := database.Model(&Reservation{}).Distinct("order_id").Where("expires_at < ?", before).Pluck("order_id", &identifiers).Error
+	if exception != nil {
+		return 0, exception
+	}
+
+	var released int64
+	failures := []error{}
+	for _, identifier := range identifiers {
+		order := &Order{}
+		exception := database.First(order, identifier).Error
+		if errors.Is(exception, gorm.ErrRecordNotFound) {
+			exception = database.Transaction(func(transaction *gorm.DB) error {
+				return settleReservations(transaction, &Order{ID: identifier}, false)
+			})
+		} else if exception == nil {
+			exception = order.MoveTo(database, OrderCancelled)
+			if exception == nil {
+				released++
+			}
+		}
+
+		if exception != nil && !errors.Is(exception, ErrInvalidTransition) {
+			failures = append(failures, fmt.Errorf("order %d: %w", identifier, exception))
+		}
+	}
+
+	return released, errors.Join(failures...)
+}
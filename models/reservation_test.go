@@ -0,0 +1,176 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should hold the copies until the order is paid", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		order, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 2}}, time.Now().Add(time.Hour), 0)
+		require.Nil(test, exception)
+		held := &Book{}
+		database.First(held, 1)
+		_, unavailable := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Act
+		exception = order.MoveTo(database, OrderPaid)
+
+		// Assert
+		sold := &Book{}
+		database.First(sold, 1)
+		var reservations int64
+		database.Model(&Reservation{}).Count(&reservations)
+		assert.Equal(2, held.Quantity)
+		assert.Equal(2, held.Reserved)
+		assert.Equal(0, held.Available())
+		assert.ErrorIs(unavailable, ErrInsufficientStock)
+		assert.Nil(exception)
+		assert.Equal(0, sold.Quantity)
+		assert.Equal(0, sold.Reserved)
+		assert.Zero(reservations)
+	})
+
+	test.Run("Should cancel the pending orders whose reservations expired", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		expired, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}, time.Now().Add(-time.Minute), 0)
+		held, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Act
+		released, exception := ReleaseExpiredReservations(database, time.Now())
+
+		// Assert
+		database.First(expired, expired.ID)
+		database.First(held, held.ID)
+		assert.Nil(exception)
+		assert.Equal(int64(1), released)
+		assert.Equal(OrderCancelled, expired.Status)
+		assert.Equal(OrderPending, held.Status)
+		assert.Equal([]int{1, 1, 4}, stock(database))
+	})
+
+	test.Run("Should drop the expired reservations of the orders which no longer exist and sweep the others", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		orphaned, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(-time.Minute), 0)
+		expired, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 2, Quantity: 1}}, time.Now().Add(-time.Minute), 0)
+		database.Delete(&Order{}, orphaned.ID)
+
+		// Act
+		released, exception := ReleaseExpiredReservations(database, time.Now())
+
+		// Assert
+		database.First(expired, expired.ID)
+		var reservations int64
+		database.Model(&Reservation{}).Count(&reservations)
+		assert.Nil(exception)
+		assert.Equal(int64(1), released)
+		assert.Equal(OrderCancelled, expired.Status)
+		assert.Zero(reservations)
+		assert.Equal([]int{2, 1, 4}, stock(database))
+	})
+
+	test.Run("Should give back the copies when a pending order is cancelled", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		order, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+
+		// Act
+		exception := order.MoveTo(database, OrderCancelled)
+
+		// Assert
+		book := &Book{}
+		database.First(book, 2)
+		released, _ := ReleaseExpiredReservations(database, time.Now().Add(2*time.Hour))
+		assert.Nil(exception)
+		assert.Equal(1, book.Quantity)
+		assert.Equal(0, book.Reserved)
+		assert.Zero(released)
+	})
+
+	test.Run("Should cancel the pending orders of the books purged", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		pending, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		paid, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		require.Nil(test, paid.MoveTo(database, OrderPaid))
+
+		// Act
+		exception := (&Book{ID: 1}).Purge(database)
+
+		// Assert
+		database.First(pending, pending.ID)
+		database.First(paid, paid.ID)
+		var reservations int64
+		database.Model(&Reservation{}).Count(&reservations)
+		assert.Nil(exception)
+		assert.Equal(OrderCancelled, pending.Status)
+		assert.Equal(OrderPaid, paid.Status)
+		assert.Zero(reservations)
+		assert.Equal([]int{1, 4}, stock(database))
+	})
+
+	test.Run("Should cancel the pending orders of the users purged", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		pending, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		paid, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		require.Nil(test, paid.MoveTo(database, OrderPaid))
+
+		// Act
+		exception := user.Purge(database)
+
+		// Assert
+		database.First(pending, pending.ID)
+		database.First(paid, paid.ID)
+		var reservations int64
+		database.Model(&Reservation{}).Count(&reservations)
+		assert.Nil(exception)
+		assert.Equal(OrderCancelled, pending.Status)
+		assert.Equal(OrderPaid, paid.Status)
+		assert.Zero(reservations)
+		assert.Equal([]int{1, 1, 4}, stock(database))
+	})
+
+	test.Run("Should cancel the pending orders of the users purged from the trash", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		pending, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		database.Unscoped().Model(&User{}).Where("id = ?", user.ID).Update("deleted_at", time.Now().Add(-48*time.Hour))
+
+		// Act
+		_, users, exception := PurgeDeleted(database, time.Now().Add(-24*time.Hour))
+
+		// Assert
+		database.First(pending, pending.ID)
+		assert.Nil(exception)
+		assert.Equal(int64(1), users)
+		assert.Equal(OrderCancelled, pending.Status)
+		assert.Equal([]int{2, 1, 4}, stock(database))
+	})
+
+	test.Run("Should cancel the pending orders of the books purged from the trash", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		pending, _ := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		database.Unscoped().Model(&Book{}).Where("id = ?", 2).Update("deleted_at", time.Now().Add(-48*time.Hour))
+
+		// Act
+		books, _, exception := PurgeDeleted(database, time.Now().Add(-24*time.Hour))
+
+		// Assert
+		database.First(pending, pending.ID)
+		assert.Nil(exception)
+		assert.Equal(int64(1), books)
+		assert.Equal(OrderCancelled, pending.Status)
+		assert.Equal([]int{2, 4}, stock(database))
+	})
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The kinds of the stock movements, the receipts and returns bring copies in while the sales and damages take them out
+// and the transfers move them between the locations
+const (
+	StockReceipt    = "receipt"
+	StockSale       = "sale"
+	StockReturn     = "return"
+	StockAdjustment = "adjustment"
+	StockDamage     = "damage"
+	StockTransfer   = "transfer"
+)
+
+// StockMovement is an entry of the append-only ledger of the copies of a book at a location, the quantity of a book is
+// the sum of the quantities of its movements
+type StockMovement struct {
+	ID         uint `gorm:"primaryKey"`
+	BookID     uint
+	LocationID uint
+	Kind       string
+	Quantity   int
+	UserID     *int
+	Reason     string
+	Reference  string
+	CreatedAt  time.Time
+}
+
+// StockDiscrepancy is a book whose quantity doesn't match the balance of its ledger or the sum of the copies at its
+// locations, or with a location whose copies don't match the balance of its movements there
+type StockDiscrepancy struct {
+	BookID    uint
+	Title     string
+	Quantity  int
+	Balance   int
+	Levels    int
+	Locations []LevelDiscrepancy `gorm:"-"`
+}
+
+// LevelDiscrepancy is a location whose copies of a book don't match the balance of the movements of the book there
+type LevelDiscrepancy struct {
+	LocationID uint
+	Quantity   int
+	Balance    int
+}
+
+var ErrInvalidMovement = errors.New("the movement doesn't fit its kind")
+
+// Valid tells whether the sign of the quantity fits the kind of the movement, only the adjustments can go either way
+func (movement *StockMovement) Valid() bool {
+	switch movement.Kind {
+	case StockReceipt, StockReturn:
+		return movement.Quantity > 0
+	case StockSale, StockDamage:
+		return movement.Quantity < 0
+	case StockAdjustment, StockTransfer:
+		return movement.Quantity != 0
+	}
+
+	return false
+}
+
+// movedBy gives the identifier of the user who made the movement, if any
+func movedBy(user *User) *int {
+	if user == nil {
+		return nil
+	}
+
+	return &user.ID
+}
+
+// Create adds the book to the catalogue and records its initial copies at the primary location as a receipt by the user
+func (book *Book) Create(database *gorm.DB, user *User) error {
+	return database.Transaction(func(transaction *gorm.DB) error {
+		if exception := transaction.Create(book).Error; exception != nil || book.Quantity == 0 {
+			return exception
+		}
+
+		location, exception := PrimaryLocation(transaction)
+		if exception != nil {
+			return exception
+		}
+
+		return transaction.Create(&StockMovement{
+			BookID:     book.ID,
+			LocationID: location.ID,
+			Kind:       StockReceipt,
+			Quantity:   book.Quantity,
+			UserID:     movedBy(user),
+			Reason:     "Initial stock",
+		}).Error
+	})
+}
+
+// Record changes the copies of the book at the location of the movement, the primary one when it has none, by its
+// quantity and appends it to the ledger, the copies reserved for the pending orders can't be taken out
+func (book *Book) Record(database *gorm.DB, movement *StockMovement) error {
+	if !movement.Valid() {
+		return fmt.Errorf("%w: %s of %d", ErrInvalidMovement, movement.Kind, movement.Quantity)
+	}
+
+	movement.BookID = book.ID
+	return database.Transaction(func(transaction *gorm.DB) error {
+		location, exception := FindLocation(transaction, movement.LocationID)
+		if exception != nil {
+			return exception
+		}
+
+		movement.LocationID = location.ID
+		if exception := stockAt(transaction, book.ID, location.ID, movement.Quantity); exception != nil {
+			return exception
+		}
+
+		update := transaction.Model(book).Where("quantity + ? >= reserved", movement.Quantity).Updates(map[string]any{
+			"quantity": gorm.Expr("quantity + ?", movement.Quantity),
+			"version":  gorm.Expr("version + 1"),
+		})
+		if update.Error != nil {
+			return update.Error
+		} else if update.RowsAffected == 0 {
+			return fmt.Errorf("%w: %q", ErrInsufficientStock, book.Title)
+		}
+
+		return transaction.Create(movement).Error
+	})
+}
+
+// StockHistory gives the movements of the book, oldest first
+func StockHistory(database *gorm.DB, book *Book) ([]StockMovement, error) {
+	movements := []StockMovement{}
+	exception := database.Where("book_id = ?", book.ID).Order("id").Find(&movements).Error
+	return movements, exception
+}
+
+// StockDiscrepancies looks for the books, even the deleted ones, whose quantity isn't the balance of their ledger nor
+// the sum of the copies at their locations, or whose copies at a location aren't the balance of their movements there
+func StockDiscrepancies(database *gorm.DB) ([]StockDiscrepancy, error) {
+	books := []StockDiscrepancy{}
+	exception := database.Table("books").
+		Select("books.id AS book_id, books.title, books.quantity, " +
+			"COALESCE((SELECT SUM(stock_movements.quantity) FROM stock_movements WHERE stock_movements.book_id = books.id), 0) AS balance, " +
+			"COALESCE((SELECT SUM(stock_levels.quantity) FROM stock_levels WHERE stock_levels.book_id = books.id), 0) AS levels").
+		Order("books.id").
+		Scan(&books).Error
+	if exception != nil {
+		return nil, exception
+	}
+
+	locations, exception := levelDiscrepancies(database)
+	if exception != nil {
+		return nil, exception
+	}
+
+	discrepancies := []StockDiscrepancy{}
+	for _, book := range books {
+		book.Locations = locations[book.BookID]
+		if book.Quantity != book.Balance || book.Quantity != book.Levels || len(book.Locations) > 0 {
+			discrepancies = append(discrepancies, book)
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// levelDiscrepancies compares the copies of the books at each location with the balance of their movements there,
+// giving the locations which don't match by book
+func levelDiscrepancies(database *gorm.DB) (map[uint][]LevelDiscrepancy, error) {
+	levels := []StockLevel{}
+	if exception := database.Find(&levels).Error; exception != nil {
+		return nil, exception
+	}
+
+	balances := []StockLevel{}
+	exception := database.Model(&StockMovement{}).
+		Select("book_id, location_id, SUM(quantity) AS quantity").
+		Group("book_id, location_id").
+		Scan(&balances).Error
+	if exception != nil {
+		return nil, exception
+	}
+
+	// The locations keeping a book without any movement of it there, or the other way round, count as zero
+	type place struct{ book, location uint }
+	compared := map[place]*LevelDiscrepancy{}
+	for _, level := range levels {
+		compared[place{level.BookID, level.LocationID}] = &LevelDiscrepancy{LocationID: level.LocationID, Quantity: level.Quantity}
+	}
+
+	for _, balance := range balances {
+		key := place{balance.BookID, balance.LocationID}
+		if _, found := compared[key]; !found {
+			compared[key] = &LevelDiscrepancy{LocationID: balance.LocationID}
+		}
+
+		compared[key].Balance = balance.Quantity
+	}
+
+	discrepancies := map[uint][]LevelDiscrepancy{}
+	for key, level := range compared {
+		if level.Quantity != level.Balance {
+			discrepancies[key.book] = append(discrepancies[key.book], *level)
+		}
+	}
+
+	for _, locations := range discrepancies {
+		slices.SortFunc(locations, func(this LevelDiscrepancy, that LevelDiscrepancy) int {
+			return cmp.Compare(this.LocationID, that.LocationID)
+		})
+	}
+
+	return discrepancies, nil
+}
+
+// Reconcile sets the quantity of the books with a discrepancy to the balance of their ledger, which is trusted over
+// the quantity, along with the copies at each location to the balance of their movements there, and gives the
+// discrepancies fixed
+func Reconcile(database *gorm.DB) ([]StockDiscrepancy, error) {
+	var discrepancies []StockDiscrepancy
+	exception := database.Transaction(func(transaction *gorm.DB) (exception error) {
+		if discrepancies, exception = StockDiscrepancies(transaction); exception != nil {
+			return exception
+		}
+
+		for _, discrepancy := range discrepancies {
+			exception := transaction.Unscoped().Model(&Book{ID: discrepancy.BookID}).Updates(map[string]any{
+				"quantity": discrepancy.Balance,
+				"version":  gorm.Expr("version + 1"),
+			}).Error
+			if exception != nil {
+				return exception
+			}
+
+			if exception := reconcileLevels(transaction, discrepancy.BookID); exception != nil {
+				return exception
+			}
+		}
+
+		return nil
+	})
+
+	return discrepancies, exception
+}
+
+// reconcileLevels sets the copies of the book at each location to the balance of its movements there
+func reconcileLevels(transaction *gorm.DB, bookID uint) error {
+	balances := []StockLevel{}
+	exception := transaction.Model(&StockMovement{}).
+		Select("location_id, SUM(quantity) AS quantity").
+		Where("book_id = ?", bookID).
+		Group("location_id").
+		Scan(&balances).Error
+	if exception != nil {
+		return exception
+	}
+
+	if exception := transaction.Model(&StockLevel{}).Where("book_id = ?", bookID).Update("quantity", 0).Error; exception != nil {
+		return exception
+	}
+
+	for _, balance := range balances {
+		level := &StockLevel{LocationID: balance.LocationID, BookID: bookID}
+		if exception := transaction.Clauses(clause.OnConflict{DoNothing: true}).Create(level).Error; exception != nil {
+			return exception
+		}
+
+		exception := transaction.Model(&StockLevel{}).
+			Where("location_id = ? AND book_id = ?", balance.LocationID, bookID).
+			Update("quantity", balance.Quantity).Error
+		if exception != nil {
+			return exception
+		}
+	}
+
+	return nil
+}
+
+// orderMovement appends to the ledger a movement of the copies of a book at a location made by an order of its user
+func orderMovement(transaction *gorm.DB, order *Order, bookID uint, locationID uint, kind string, quantity int) error {
+	return transaction.Create(&StockMovement{
+		BookID:     bookID,
+		LocationID: locationID,
+		Kind:       kind,
+		Quantity:   quantity,
+		UserID:     &order.UserID,
+		Reference:  fmt.Sprintf("order:%d", order.ID),
+	}).Error
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/money"
+)
+
+func kinds(movements []StockMovement) []string {
+	names := make([]string, len(movements))
+	for index, movement := range movements {
+		names[index] = movement.Kind
+	}
+
+	return names
+}
+
+func TestStockMovements(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should record the sales and returns of the orders in the ledger", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		book := &Book{Title: "Persuasion", Author: "Jane Austen", Prices: []BookPrice{{Price: money.MustParse("7.50", "GBP")}}, Quantity: 3}
+		require.Nil(test, book.Create(database, user))
+		order, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: book.ID, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		require.Nil(test, exception)
+
+		// Act
+		require.Nil(test, order.MoveTo(database, OrderPaid))
+		exception = order.MoveTo(database, OrderCancelled)
+
+		// Assert
+		movements, _ := StockHistory(database, book)
+		discrepancies, _ := StockDiscrepancies(database)
+		assert.Nil(exception)
+		assert.Equal([]string{StockReceipt, StockSale, StockReturn}, kinds(movements))
+		assert.Equal(&user.ID, movements[1].UserID)
+		assert.Equal("order:1", movements[2].Reference)
+		// The books of the setup were created without their ledger
+		assert.Len(discrepancies, 3)
+		assert.NotContains(discrepancies, StockDiscrepancy{BookID: book.ID, Title: "Persuasion", Quantity: 3, Balance: 3, Levels: 3})
+	})
+
+	test.Run("Should audit and reconcile the copies of a location drifting from its ledger", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		book := &Book{Title: "Persuasion", Author: "Jane Austen", Quantity: 3}
+		require.Nil(test, book.Create(database, user))
+		warehouse := &Location{Name: "Warehouse"}
+		require.Nil(test, database.Create(warehouse).Error)
+		require.Nil(test, book.Transfer(database, user, 1, warehouse.ID, 1, "Restock"))
+		database.Model(&StockLevel{}).Where("book_id = ? AND location_id = ?", book.ID, warehouse.ID).Update("quantity", 4)
+
+		// Act
+		audited, auditing := StockDiscrepancies(database)
+		reconciled, reconciling := Reconcile(database)
+
+		// Assert
+		after, _ := StockDiscrepancies(database)
+		levels, _ := StockLevels(database, book)
+		drift := StockDiscrepancy{
+			BookID:    book.ID,
+			Title:     "Persuasion",
+			Quantity:  3,
+			Balance:   3,
+			Levels:    6,
+			Locations: []LevelDiscrepancy{{LocationID: warehouse.ID, Quantity: 4, Balance: 1}},
+		}
+		assert.Nil(auditing)
+		assert.Contains(audited, drift)
+		assert.Nil(reconciling)
+		assert.Contains(reconciled, drift)
+		assert.Empty(after)
+		require.Len(test, levels, 2)
+		assert.Equal(2, levels[0].Quantity)
+		assert.Equal(1, levels[1].Quantity)
+	})
+
+	test.Run("Should NOT take out the copies reserved", func(test *testing.T) {
+		// Arrange
+		database, user := setupOrders(test)
+		_, exception := PlaceOrder(database, user, "GBP", []OrderLine{{BookID: 1, Quantity: 1}}, time.Now().Add(time.Hour), 0)
+		require.Nil(test, exception)
+		book := &Book{}
+		database.First(book, 1)
+
+		// Act
+		excess := book.Record(database, &StockMovement{Kind: StockDamage, Quantity: -2, Reason: "Flood"})
+		invalid := book.Record(database, &StockMovement{Kind: StockDamage, Quantity: 1, Reason: "Flood"})
+		exception = book.Record(database, &StockMovement{Kind: StockDamage, Quantity: -1, Reason: "Flood"})
+
+		// Assert
+		database.First(book, 1)
+		assert.ErrorIs(excess, ErrInsufficientStock)
+		assert.ErrorIs(invalid, ErrInvalidMovement)
+		assert.Nil(exception)
+		assert.Equal(1, book.Quantity)
+		assert.Equal(0, book.Available())
+	})
+}
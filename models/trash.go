@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidDeletedFilter = errors.New(`the deleted filter must be either "include" or "only"`)
+
+// Deleted scopes a query by the soft-deleted records: they are left out by default, "include" adds them and "only" lists them alone
+func Deleted(database *gorm.DB, filter string) (*gorm.DB, error) {
+	switch filter {
+	case "":
+		return database, nil
+	case "include":
+		return database.Unscoped(), nil
+	case "only":
+		return database.Unscoped().Where("deleted_at IS NOT NULL"), nil
+	}
+
+	return nil, ErrInvalidDeletedFilter
+}
+
+// PurgeDeleted permanently removes the books, along with their prices, cart items and copies at every location, and the
+// users, along with their carts, soft-deleted before the given time. The pending orders of both are cancelled
+func PurgeDeleted(database *gorm.DB, before time.Time) (books int64, users int64, exception error) {
+	exception = database.Transaction(func(transaction *gorm.DB) error {
+		expired := transaction.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Book{}).Select("id").Where("deleted_at < ?", before)
+		if exception := cancelPendingOrders(transaction, expired); exception != nil {
+			return exception
+		}
+
+		if exception := transaction.Where("book_id IN (?)", expired).Delete(&BookPrice{}).Error; exception != nil {
+			return exception
+		}
+
+		if exception := transaction.Where("book_id IN (?)", expired).Delete(&CartItem{}).Error; exception != nil {
+			return exception
+		}
+
+		if exception := transaction.Where("book_id IN (?)", expired).Delete(&StockLevel{}).Error; exception != nil {
+			return exception
+		}
+
+		purge := transaction.Unscoped().Where("deleted_at < ?", before).Delete(&Book{})
+		if purge.Error != nil {
+			return purge.Error
+		}
+
+		books = purge.RowsAffected
+		gone := transaction.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&User{}).Select("id").Where("deleted_at < ?", before)
+		if exception := cancelPendingOrdersOf(transaction, gone); exception != nil {
+			return exception
+		}
+
+		if exception := purgeCarts(transaction, gone); exception != nil {
+			return exception
+		}
+
+		purge = transaction.Unscoped().Where("deleted_at < ?", before).Delete(&User{})
+		users = purge.RowsAffected
+		return purge.Error
+	})
+
+	return books, users, exception
+}
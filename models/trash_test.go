@@ -0,0 +1,148 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/money"
+	"gorm.io/gorm"
+)
+
+func TestDeleted(test *testing.T) {
+	assert := assert.New(test)
+	database := setupBooks(test)
+	database.Create(&Book{Title: "Dune", Author: "Frank Herbert"})
+	database.Create(&Book{Title: "Emma", Author: "Jane Austen"})
+	database.Delete(&Book{}, 2)
+
+	testcases := []struct {
+		filter   string
+		expected []string
+	}{
+		{"", []string{"Dune"}},
+		{"include", []string{"Dune", "Emma"}},
+		{"only", []string{"Emma"}},
+	}
+
+	for _, testcase := range testcases {
+		test.Run("Should list the books with the filter '"+testcase.filter+"'", func(test *testing.T) {
+			// Act
+			scope, exception := Deleted(database, testcase.filter)
+			titles := []string{}
+			scope.Model(&Book{}).Order("id").Pluck("title", &titles)
+
+			// Assert
+			assert.Nil(exception)
+			assert.Equal(testcase.expected, titles)
+		})
+	}
+
+	test.Run("Should reject an unknown filter", func(test *testing.T) {
+		// Act
+		_, exception := Deleted(database, "all")
+
+		// Assert
+		assert.ErrorIs(exception, ErrInvalidDeletedFilter)
+	})
+}
+
+func TestBookRestore(test *testing.T) {
+	assert := assert.New(test)
+	database := setupBooks(test)
+	book := &Book{Title: "Dune", Author: "Frank Herbert"}
+	database.Create(book)
+	database.Delete(book)
+
+	test.Run("Should bring the book back as a new version", func(test *testing.T) {
+		// Act
+		exception := book.Restore(database)
+
+		// Assert
+		restored := &Book{}
+		assert.Nil(exception)
+		assert.Nil(database.First(restored, book.ID).Error)
+		assert.Equal(uint(2), restored.Version)
+	})
+}
+
+func TestBookPurge(test *testing.T) {
+	assert := assert.New(test)
+	database := setupBooks(test)
+	book := &Book{Title: "Dune", Author: "Frank Herbert", Prices: []BookPrice{{Price: money.MustParse("9.99", "GBP")}}}
+	database.Create(book)
+
+	test.Run("Should remove the book and its prices permanently", func(test *testing.T) {
+		// Act
+		exception := book.Purge(database)
+
+		// Assert
+		var books, prices int64
+		database.Unscoped().Model(&Book{}).Count(&books)
+		database.Model(&BookPrice{}).Count(&prices)
+		assert.Nil(exception)
+		assert.Zero(books)
+		assert.Zero(prices)
+	})
+}
+
+func TestUserTrash(test *testing.T) {
+	assert := assert.New(test)
+	database := setupBooks(test)
+	user := &User{Nickname: "dummy-user", Password: "hash"}
+	database.Create(user)
+	database.Delete(user)
+
+	test.Run("Should let the user back in", func(test *testing.T) {
+		// Act
+		exception := user.Restore(database)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Nil(database.First(&User{}, user.ID).Error)
+	})
+
+	test.Run("Should remove the user permanently", func(test *testing.T) {
+		// Act
+		exception := user.Purge(database)
+
+		// Assert
+		assert.Nil(exception)
+		assert.ErrorIs(database.Unscoped().First(&User{}, user.ID).Error, gorm.ErrRecordNotFound)
+	})
+}
+
+func TestPurgeDeleted(test *testing.T) {
+	assert := assert.New(test)
+	database := setupBooks(test)
+	now := time.Now()
+	price := func() []BookPrice { return []BookPrice{{Price: money.MustParse("1", "GBP")}} }
+	require.Nil(test, database.Create(&Book{Title: "Kept", Prices: price()}).Error)
+	require.Nil(test, database.Create(&Book{Title: "Recent", Prices: price()}).Error)
+	require.Nil(test, database.Create(&Book{Title: "Expired", Prices: price()}).Error)
+	require.Nil(test, database.Create(&User{Nickname: "recent"}).Error)
+	require.Nil(test, database.Create(&User{Nickname: "expired"}).Error)
+	database.Unscoped().Model(&Book{}).Where("title = ?", "Recent").Update("deleted_at", now.Add(-time.Hour))
+	database.Unscoped().Model(&Book{}).Where("title = ?", "Expired").Update("deleted_at", now.Add(-48*time.Hour))
+	database.Unscoped().Model(&User{}).Where("nickname = ?", "recent").Update("deleted_at", now.Add(-time.Hour))
+	database.Unscoped().Model(&User{}).Where("nickname = ?", "expired").Update("deleted_at", now.Add(-48*time.Hour))
+
+	test.Run("Should only purge the records deleted before the given time", func(test *testing.T) {
+		// Act
+		books, users, exception := PurgeDeleted(database, now.Add(-24*time.Hour))
+
+		// Assert
+		titles, nicknames := []string{}, []string{}
+		var prices int64
+		database.Unscoped().Model(&Book{}).Order("id").Pluck("title", &titles)
+		database.Unscoped().Model(&User{}).Order("id").Pluck("nickname", &nicknames)
+		database.Model(&BookPrice{}).Count(&prices)
+		assert.Nil(exception)
+		assert.Equal(int64(1), books)
+		assert.Equal(int64(1), users)
+		assert.Equal([]string{"Kept", "Recent"}, titles)
+		assert.Equal([]string{"recent"}, nicknames)
+		assert.Equal(int64(2), prices)
+	})
+}
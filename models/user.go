@@ -8,12 +8,12 @@ import (
 )
 
 type User struct {
-	gorm.Model
-	ID        int       `json:"id" gorm:"primary_key"`
-	Nickname  string    `json:"nickname" gorm:"unique"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Nickname  string         `gorm:"unique"`
+	Password  string         `json:"-"`
 }
 
 func (user *User) String() string {
@@ -25,3 +25,23 @@ func (user *User) String() string {
 		user.UpdatedAt.Format(time.RFC1123),
 	)
 }
+
+// Restore lets a soft-deleted user log in again
+func (user *User) Restore(database *gorm.DB) error {
+	return database.Unscoped().Model(user).Where("deleted_at IS NOT NULL").Update("deleted_at", nil).Error
+}
+
+// Purge removes the user and its cart permanently, even when it wasn't soft-deleted, and cancels its pending orders
+func (user *User) Purge(database *gorm.DB) error {
+	return database.Transaction(func(transaction *gorm.DB) error {
+		if exception := cancelPendingOrdersOf(transaction, []int{user.ID}); exception != nil {
+			return exception
+		}
+
+		if exception := purgeCarts(transaction, []int{user.ID}); exception != nil {
+			return exception
+		}
+
+		return transaction.Unscoped().Delete(user).Error
+	})
+}
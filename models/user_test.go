@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
@@ -32,3 +33,17 @@ func TestString(test *testing.T) {
 	// Assert
 	assert.Equal(expected, actual)
 }
+
+func TestUserJSON(test *testing.T) {
+	// Arrange
+	assert := assert.New(test)
+	user := &User{ID: 1, Nickname: "dummy-user", Password: "top-secret"}
+
+	// Act
+	body, exception := json.Marshal(user)
+
+	// Assert
+	assert.Nil(exception)
+	assert.NotContains(string(body), "Password")
+	assert.NotContains(string(body), "top-secret")
+}
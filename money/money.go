@@ -0,0 +1,210 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount overflow")
+)
+
+// Number of decimal places of the minor unit of every supported ISO 4217 currency
+var exponents = map[string]int{
+	"AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "INR": 2, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "SEK": 2,
+	"SGD": 2, "USD": 2, "ZAR": 2,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+var decimal = regexp.MustCompile(`^(-?)(\d+)(?:\.(\d+))?$`)
+
+// Money is an exact amount in the minor unit of its currency, e.g. 1250 GBP is £12.50
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func Exponent(currency string) (int, error) {
+	exponent, supported := exponents[currency]
+	if !supported {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	return exponent, nil
+}
+
+func New(amount int64, currency string) (Money, error) {
+	if _, exception := Exponent(currency); exception != nil {
+		return Money{}, exception
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Parse reads a decimal amount like "12.50" which can't be more precise than the minor unit of the currency
+func Parse(amount string, currency string) (Money, error) {
+	exponent, exception := Exponent(currency)
+	if exception != nil {
+		return Money{}, exception
+	}
+
+	parts := decimal.FindStringSubmatch(amount)
+	if parts == nil || len(parts[3]) > exponent {
+		return Money{}, fmt.Errorf("%w: %q in %s", ErrInvalidAmount, amount, currency)
+	}
+
+	digits := parts[1] + parts[2] + parts[3] + strings.Repeat("0", exponent-len(parts[3]))
+	minor, exception := strconv.ParseInt(digits, 10, 64)
+	if exception != nil {
+		return Money{}, fmt.Errorf("%w: %q in %s", ErrOverflow, amount, currency)
+	}
+
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+func MustParse(amount string, currency string) Money {
+	money, exception := Parse(amount, currency)
+	if exception != nil {
+		panic(exception)
+	}
+
+	return money
+}
+
+// Decimal formats the amount with the decimal places of the currency, e.g. "12.50"
+func (money Money) Decimal() string {
+	exponent := exponents[money.Currency]
+	sign := ""
+	magnitude := strconv.FormatInt(money.Amount, 10)
+	if money.Amount < 0 {
+		sign, magnitude = "-", magnitude[1:]
+	}
+
+	if exponent == 0 {
+		return sign + magnitude
+	}
+
+	magnitude = strings.Repeat("0", max(0, exponent+1-len(magnitude))) + magnitude
+	point := len(magnitude) - exponent
+	return sign + magnitude[:point] + "." + magnitude[point:]
+}
+
+func (money Money) String() string {
+	return money.Decimal() + " " + money.Currency
+}
+
+func (money Money) IsZero() bool {
+	return money.Amount == 0
+}
+
+func (money Money) IsPositive() bool {
+	return money.Amount > 0
+}
+
+func (money Money) same(other Money) error {
+	if money.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, money.Currency, other.Currency)
+	}
+
+	return nil
+}
+
+func (money Money) Add(other Money) (Money, error) {
+	if exception := money.same(other); exception != nil {
+		return Money{}, exception
+	}
+
+	sum := money.Amount + other.Amount
+	if (other.Amount > 0 && sum < money.Amount) || (other.Amount < 0 && sum > money.Amount) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: sum, Currency: money.Currency}, nil
+}
+
+func (money Money) Subtract(other Money) (Money, error) {
+	return money.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+func (money Money) Multiply(quantity int64) (Money, error) {
+	if quantity != 0 && (money.Amount*quantity)/quantity != money.Amount {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: money.Amount * quantity, Currency: money.Currency}, nil
+}
+
+// Percentage takes the given basis points of the amount rounding half away from zero, e.g. 1250 is 12.5%
+func (money Money) Percentage(basisPoints int64) Money {
+	share := math.Round(float64(money.Amount) * float64(basisPoints) / 10000)
+	return Money{Amount: int64(share), Currency: money.Currency}
+}
+
+// Allocate splits the amount in the given number of parts without losing any minor unit
+func (money Money) Allocate(parts int) []Money {
+	if parts <= 0 {
+		return nil
+	}
+
+	shares := make([]Money, parts)
+	quotient, remainder := money.Amount/int64(parts), money.Amount%int64(parts)
+	for index := range shares {
+		shares[index] = Money{Amount: quotient, Currency: money.Currency}
+		if int64(index) < remainder {
+			shares[index].Amount++
+		} else if int64(index) < -remainder {
+			shares[index].Amount--
+		}
+	}
+
+	return shares
+}
+
+func (money Money) Compare(other Money) (int, error) {
+	if exception := money.same(other); exception != nil {
+		return 0, exception
+	}
+
+	switch {
+	case money.Amount < other.Amount:
+		return -1, nil
+	case money.Amount > other.Amount:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+type representation struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (money Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(representation{Amount: money.Decimal(), Currency: money.Currency})
+}
+
+func (money *Money) UnmarshalJSON(data []byte) error {
+	var value representation
+	if exception := json.Unmarshal(data, &value); exception != nil {
+		return exception
+	}
+
+	parsed, exception := Parse(value.Amount, strings.ToUpper(value.Currency))
+	if exception != nil {
+		return exception
+	}
+
+	*money = parsed
+	return nil
+}
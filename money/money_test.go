@@ -0,0 +1,213 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(test *testing.T) {
+	assert := assert.New(test)
+
+	testcases := []struct {
+		description string
+		amount      string
+		currency    string
+		expected    Money
+		exception   error
+	}{
+		{"Should parse an amount with all the decimals", "12.50", "GBP", Money{1250, "GBP"}, nil},
+		{"Should parse an amount with less decimals", "12.5", "EUR", Money{1250, "EUR"}, nil},
+		{"Should parse an amount without decimals", "12", "USD", Money{1200, "USD"}, nil},
+		{"Should parse a negative amount", "-0.05", "GBP", Money{-5, "GBP"}, nil},
+		{"Should parse currencies without minor unit", "1500", "JPY", Money{1500, "JPY"}, nil},
+		{"Should parse currencies with three decimals", "1.234", "KWD", Money{1234, "KWD"}, nil},
+		{"Should NOT parse more decimals than the minor unit", "12.505", "GBP", Money{}, ErrInvalidAmount},
+		{"Should NOT parse decimals of currencies without minor unit", "1500.5", "JPY", Money{}, ErrInvalidAmount},
+		{"Should NOT parse something which is not a number", "twelve", "GBP", Money{}, ErrInvalidAmount},
+		{"Should NOT parse the scientific notation", "1e3", "GBP", Money{}, ErrInvalidAmount},
+		{"Should NOT parse an unknown currency", "12.50", "XYZ", Money{}, ErrUnknownCurrency},
+		{"Should NOT parse amounts which don't fit", "92233720368547758.08", "GBP", Money{}, ErrOverflow},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Act
+			actual, exception := Parse(testcase.amount, testcase.currency)
+
+			// Assert
+			assert.ErrorIs(exception, testcase.exception)
+			assert.Equal(testcase.expected, actual)
+		})
+	}
+
+	test.Run("Should panic when it must parse an invalid amount", func(test *testing.T) {
+		assert.Panics(func() { MustParse("free", "GBP") })
+	})
+}
+
+func TestNew(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should create an amount in minor units", func(test *testing.T) {
+		// Act
+		actual, exception := New(999, "GBP")
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal("9.99 GBP", actual.String())
+	})
+
+	test.Run("Should NOT create an amount in an unknown currency", func(test *testing.T) {
+		// Act
+		_, exception := New(999, "ABC")
+
+		// Assert
+		assert.ErrorIs(exception, ErrUnknownCurrency)
+	})
+}
+
+func TestDecimal(test *testing.T) {
+	assert := assert.New(test)
+
+	testcases := []struct {
+		money    Money
+		expected string
+	}{
+		{Money{1250, "GBP"}, "12.50"},
+		{Money{5, "GBP"}, "0.05"},
+		{Money{0, "GBP"}, "0.00"},
+		{Money{-5, "EUR"}, "-0.05"},
+		{Money{-1250, "EUR"}, "-12.50"},
+		{Money{1500, "JPY"}, "1500"},
+		{Money{1, "KWD"}, "0.001"},
+		{Money{math.MinInt64, "USD"}, "-92233720368547758.08"},
+	}
+
+	for _, testcase := range testcases {
+		test.Run("Should format "+testcase.expected, func(test *testing.T) {
+			assert.Equal(testcase.expected, testcase.money.Decimal())
+		})
+	}
+}
+
+func TestArithmetic(test *testing.T) {
+	assert := assert.New(test)
+	price := MustParse("9.99", "GBP")
+
+	test.Run("Should add and subtract amounts of the same currency", func(test *testing.T) {
+		// Act
+		sum, added := price.Add(MustParse("0.02", "GBP"))
+		difference, subtracted := price.Subtract(MustParse("10", "GBP"))
+
+		// Assert
+		assert.Nil(added)
+		assert.Nil(subtracted)
+		assert.Equal(MustParse("10.01", "GBP"), sum)
+		assert.Equal(MustParse("-0.01", "GBP"), difference)
+	})
+
+	test.Run("Should NOT mix currencies", func(test *testing.T) {
+		// Act
+		_, added := price.Add(MustParse("1", "EUR"))
+		_, subtracted := price.Subtract(MustParse("1", "EUR"))
+		_, compared := price.Compare(MustParse("1", "EUR"))
+
+		// Assert
+		assert.ErrorIs(added, ErrCurrencyMismatch)
+		assert.ErrorIs(subtracted, ErrCurrencyMismatch)
+		assert.ErrorIs(compared, ErrCurrencyMismatch)
+	})
+
+	test.Run("Should multiply by a quantity without rounding errors", func(test *testing.T) {
+		// Act
+		total, exception := MustParse("0.10", "GBP").Multiply(3)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal("0.30", total.Decimal())
+	})
+
+	test.Run("Should detect the overflows", func(test *testing.T) {
+		// Arrange
+		huge := Money{math.MaxInt64, "GBP"}
+
+		// Act
+		_, added := huge.Add(Money{1, "GBP"})
+		_, subtracted := Money{math.MinInt64, "GBP"}.Subtract(Money{1, "GBP"})
+		_, multiplied := huge.Multiply(2)
+
+		// Assert
+		assert.ErrorIs(added, ErrOverflow)
+		assert.ErrorIs(subtracted, ErrOverflow)
+		assert.ErrorIs(multiplied, ErrOverflow)
+	})
+
+	test.Run("Should take percentages rounding half away from zero", func(test *testing.T) {
+		assert.Equal(MustParse("1.25", "GBP"), MustParse("12.50", "GBP").Percentage(1000))
+		assert.Equal(MustParse("0.03", "GBP"), MustParse("0.05", "GBP").Percentage(5000))
+		assert.Equal(MustParse("-0.03", "GBP"), MustParse("-0.05", "GBP").Percentage(5000))
+	})
+
+	test.Run("Should allocate the amount without losing pennies", func(test *testing.T) {
+		assert.Equal([]Money{{34, "GBP"}, {33, "GBP"}, {33, "GBP"}}, MustParse("1", "GBP").Allocate(3))
+		assert.Equal([]Money{{-34, "GBP"}, {-33, "GBP"}, {-33, "GBP"}}, MustParse("-1", "GBP").Allocate(3))
+		assert.Nil(price.Allocate(0))
+	})
+
+	test.Run("Should compare amounts of the same currency", func(test *testing.T) {
+		less, _ := price.Compare(MustParse("10", "GBP"))
+		equal, _ := price.Compare(MustParse("9.99", "GBP"))
+		greater, _ := price.Compare(MustParse("9.98", "GBP"))
+
+		assert.Equal(-1, less)
+		assert.Equal(0, equal)
+		assert.Equal(1, greater)
+		assert.True(price.IsPositive())
+		assert.False(price.IsZero())
+		assert.True(Money{0, "GBP"}.IsZero())
+	})
+}
+
+func TestJSON(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should serialise the amount as a decimal string", func(test *testing.T) {
+		// Act
+		body, exception := json.Marshal(MustParse("12.5", "GBP"))
+
+		// Assert
+		assert.Nil(exception)
+		assert.JSONEq(`{"amount": "12.50", "currency": "GBP"}`, string(body))
+	})
+
+	test.Run("Should deserialise the decimal string", func(test *testing.T) {
+		// Arrange
+		var money Money
+
+		// Act
+		exception := json.Unmarshal([]byte(`{"amount": "12.50", "currency": "gbp"}`), &money)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(Money{1250, "GBP"}, money)
+	})
+
+	test.Run("Should NOT deserialise invalid amounts", func(test *testing.T) {
+		// Arrange
+		var money Money
+
+		// Act
+		invalid := json.Unmarshal([]byte(`{"amount": 12.5, "currency": "GBP"}`), &money)
+		imprecise := json.Unmarshal([]byte(`{"amount": "12.505", "currency": "GBP"}`), &money)
+		unknown := json.Unmarshal([]byte(`{"amount": "12.50", "currency": "XYZ"}`), &money)
+
+		// Assert
+		assert.NotNil(invalid)
+		assert.ErrorIs(imprecise, ErrInvalidAmount)
+		assert.ErrorIs(unknown, ErrUnknownCurrency)
+		assert.Equal(Money{}, money)
+	})
+}
@@ -0,0 +1,164 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The type of the events emitted when a checkout takes the available copies of a book below its reorder point
+const LowStock = "stock.low"
+
+// Event is something the staff should know about, e.g. a book running low, with a summary for the humans and the data
+// for the machines
+type Event struct {
+	Type      string    `json:"type"`
+	Subject   string    `json:"subject"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notifier tells the staff about the events through some channel
+type Notifier interface {
+	Notify(context.Context, Event) error
+}
+
+var (
+	_ Notifier = (*Log)(nil)
+	_ Notifier = (*Email)(nil)
+	_ Notifier = (*Webhook)(nil)
+	_ Notifier = Many(nil)
+	_ Notifier = (*Recorder)(nil)
+)
+
+// Log writes the events as warnings of the given logger, or the default one
+type Log struct {
+	Logger *slog.Logger
+}
+
+func (notifier *Log) Notify(context context.Context, event Event) error {
+	logger := notifier.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger.WarnContext(context, event.Subject, "event", event.Type, "data", event.Data)
+	return nil
+}
+
+// Message is an email telling about an event
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Email sends the events by email with the given function, it only logs the messages when there is none as the shop
+// has no mail server yet
+type Email struct {
+	From string
+	To   []string
+	Send func(context.Context, Message) error
+}
+
+// Compose writes the email about the event, its body is the data of the event as indented JSON
+func (notifier *Email) Compose(event Event) (Message, error) {
+	body, exception := json.MarshalIndent(event.Data, "", "  ")
+	if exception != nil {
+		return Message{}, exception
+	}
+
+	return Message{From: notifier.From, To: notifier.To, Subject: event.Subject, Body: string(body)}, nil
+}
+
+func (notifier *Email) Notify(context context.Context, event Event) error {
+	if len(notifier.To) == 0 {
+		return nil
+	}
+
+	message, exception := notifier.Compose(event)
+	if exception != nil {
+		return exception
+	}
+
+	if notifier.Send == nil {
+		slog.InfoContext(context, "Email not sent, there is no mail server", "from", message.From, "to", message.To, "subject", message.Subject)
+		return nil
+	}
+
+	return notifier.Send(context, message)
+}
+
+// Webhook posts the events as JSON to the given URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (notifier *Webhook) Notify(context context.Context, event Event) error {
+	body, exception := json.Marshal(event)
+	if exception != nil {
+		return exception
+	}
+
+	request, exception := http.NewRequestWithContext(context, http.MethodPost, notifier.URL, bytes.NewReader(body))
+	if exception != nil {
+		return exception
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	response, exception := notifier.Client.Do(request)
+	if exception != nil {
+		return exception
+	}
+
+	response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("the webhook was answered with %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// Many tells every notifier about the events, even when some of them fail
+type Many []Notifier
+
+func (notifiers Many) Notify(context context.Context, event Event) error {
+	exceptions := make([]error, len(notifiers))
+	for index, notifier := range notifiers {
+		exceptions[index] = notifier.Notify(context, event)
+	}
+
+	return errors.Join(exceptions...)
+}
+
+// Recorder keeps the events in memory, so they can be checked
+type Recorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (recorder *Recorder) Notify(_ context.Context, event Event) error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.events = append(recorder.events, event)
+	return nil
+}
+
+// Events gives a copy of the events notified so far
+func (recorder *Recorder) Events() []Event {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return append([]Event{}, recorder.events...)
+}
@@ -0,0 +1,133 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failing struct{}
+
+func (failing) Notify(context.Context, Event) error {
+	return errors.New("dummy failure")
+}
+
+func TestNotifiers(test *testing.T) {
+	assert := assert.New(test)
+	background := context.Background()
+	event := Event{
+		Type:      LowStock,
+		Subject:   "Dune is running low",
+		Data:      map[string]int{"book_id": 1, "available": 1},
+		CreatedAt: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+
+	test.Run("Should log the event as a warning", func(test *testing.T) {
+		// Arrange
+		output := &bytes.Buffer{}
+		notifier := &Log{Logger: slog.New(slog.NewTextHandler(output, nil))}
+
+		// Act
+		exception := notifier.Notify(background, event)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Contains(output.String(), "level=WARN")
+		assert.Contains(output.String(), `msg="Dune is running low"`)
+		assert.Contains(output.String(), "event=stock.low")
+	})
+
+	test.Run("Should send the email composed with the event to the recipients", func(test *testing.T) {
+		// Arrange
+		sent := []Message{}
+		notifier := &Email{From: "shop@example.com", To: []string{"buyers@example.com"}, Send: func(_ context.Context, message Message) error {
+			sent = append(sent, message)
+			return nil
+		}}
+
+		// Act
+		exception := notifier.Notify(background, event)
+
+		// Assert
+		assert.Nil(exception)
+		require.Len(test, sent, 1)
+		assert.Equal("shop@example.com", sent[0].From)
+		assert.Equal([]string{"buyers@example.com"}, sent[0].To)
+		assert.Equal("Dune is running low", sent[0].Subject)
+		assert.JSONEq(`{"book_id": 1, "available": 1}`, sent[0].Body)
+	})
+
+	test.Run("Should NOT send any email without recipients", func(test *testing.T) {
+		// Arrange
+		sent := 0
+		notifier := &Email{Send: func(context.Context, Message) error {
+			sent++
+			return nil
+		}}
+
+		// Act
+		exception := notifier.Notify(background, event)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Zero(sent)
+	})
+
+	test.Run("Should post the event to the webhook", func(test *testing.T) {
+		// Arrange
+		var received Event
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			body, _ := io.ReadAll(request.Body)
+			assert.Equal("application/json", request.Header.Get("Content-Type"))
+			assert.Nil(json.Unmarshal(body, &received))
+			writer.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		// Act
+		exception := NewWebhook(server.URL).Notify(background, event)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(LowStock, received.Type)
+		assert.Equal("Dune is running low", received.Subject)
+		assert.Equal(event.CreatedAt, received.CreatedAt)
+	})
+
+	test.Run("Should fail when the webhook isn't accepted", func(test *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		// Act
+		exception := NewWebhook(server.URL).Notify(background, event)
+
+		// Assert
+		assert.ErrorContains(exception, "500")
+	})
+
+	test.Run("Should tell every notifier even when some of them fail", func(test *testing.T) {
+		// Arrange
+		first, second := &Recorder{}, &Recorder{}
+
+		// Act
+		exception := Many{first, failing{}, second}.Notify(background, event)
+
+		// Assert
+		assert.ErrorContains(exception, "dummy failure")
+		assert.Equal([]Event{event}, first.Events())
+		assert.Equal([]Event{event}, second.Events())
+	})
+}
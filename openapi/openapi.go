@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var Specification []byte
+
+//go:embed docs.html
+var Documentation []byte
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var parameter = regexp.MustCompile(`:(\w+)|\*(\w+)`)
+
+// Route converts a gin path like /books/:id to its OpenAPI template /books/{id}
+func Route(path string) string {
+	return parameter.ReplaceAllString(path, "{$1$2}")
+}
+
+// Operations lists the method and path of every operation in the specification, e.g. "GET /books"
+func Operations(specification []byte) ([]string, error) {
+	var document struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if exception := json.Unmarshal(specification, &document); exception != nil {
+		return nil, exception
+	}
+
+	operations := []string{}
+	for path, item := range document.Paths {
+		for _, method := range methods {
+			if _, exists := item[method]; exists {
+				operations = append(operations, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(operations)
+	return operations, nil
+}
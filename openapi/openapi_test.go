@@ -0,0 +1,86 @@
+package openapi
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecification(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should be an OpenAPI 3.1 document", func(test *testing.T) {
+		// Act
+		var document map[string]any
+		exception := json.Unmarshal(Specification, &document)
+
+		// Assert
+		require.Nil(test, exception)
+		assert.Equal("3.1.0", document["openapi"])
+		assert.Contains(document, "info")
+		assert.Contains(document, "paths")
+	})
+
+	test.Run("Should resolve all the references", func(test *testing.T) {
+		// Arrange
+		var document map[string]any
+		json.Unmarshal(Specification, &document)
+		references := regexp.MustCompile(`"\$ref":\s*"#/([^"]+)"`).FindAllStringSubmatch(string(Specification), -1)
+		require.NotEmpty(test, references)
+
+		for _, reference := range references {
+			// Act
+			var node any = document
+			for _, segment := range strings.Split(reference[1], "/") {
+				object, _ := node.(map[string]any)
+				node = object[segment]
+			}
+
+			// Assert
+			assert.NotNil(node, reference[1])
+		}
+	})
+}
+
+func TestRoute(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should convert the gin parameters to OpenAPI templates", func(test *testing.T) {
+		assert.Equal("/books", Route("/books"))
+		assert.Equal("/books/{id}", Route("/books/:id"))
+		assert.Equal("/users/{user}/books/{book}", Route("/users/:user/books/:book"))
+		assert.Equal("/files/{path}", Route("/files/*path"))
+	})
+}
+
+func TestOperations(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should list the operations of every path", func(test *testing.T) {
+		// Arrange
+		specification := []byte(`{"paths": {
+			"/books": {"get": {}, "post": {}, "parameters": []},
+			"/books/{id}": {"get": {}, "delete": {}}
+		}}`)
+
+		// Act
+		operations, exception := Operations(specification)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal([]string{"DELETE /books/{id}", "GET /books", "GET /books/{id}", "POST /books"}, operations)
+	})
+
+	test.Run("Should fail when the specification is NOT valid JSON", func(test *testing.T) {
+		// Act
+		operations, exception := Operations([]byte("openapi: 3.1.0"))
+
+		// Assert
+		assert.NotNil(exception)
+		assert.Nil(operations)
+	})
+}
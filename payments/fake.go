@@ -0,0 +1,246 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/money"
+)
+
+// The payment methods of the fake gateway and how it answers them
+const (
+	FakeApprove        = "fake_approve"
+	FakeDecline        = "fake_decline"
+	FakeDelayed        = "fake_delayed"
+	FakeDelayedDecline = "fake_delayed_decline"
+)
+
+var _ PaymentProvider = (*Fake)(nil)
+
+// Deliver sends a signed webhook to the shop
+type Deliver func(body []byte, signature string) error
+
+type fakePayment struct {
+	amount   money.Money
+	status   Status
+	outcome  Status
+	refunded int64
+}
+
+// Fake is a local payment gateway which approves or declines the charges by their method, and settles the delayed ones
+// after a while with a signed webhook, so the payments can be tried without any external service. A negative delay
+// leaves them pending until they are confirmed by hand. Its payments only live in memory and are lost on restart, so
+// it must never take real payments
+type Fake struct {
+	Secret    string
+	Delay     time.Duration
+	Deliver   Deliver
+	Lifecycle *lifecycle.Lifecycle
+	mutex     sync.Mutex
+	payments  map[string]*fakePayment
+	sequence  int
+}
+
+func NewFake(secret string, delay time.Duration, deliver Deliver, lifecycle *lifecycle.Lifecycle) *Fake {
+	return &Fake{Secret: secret, Delay: delay, Deliver: deliver, Lifecycle: lifecycle, payments: map[string]*fakePayment{}}
+}
+
+// PostTo delivers the webhooks with a POST request to the given URL
+func PostTo(url string) Deliver {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(body []byte, signature string) error {
+		request, exception := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if exception != nil {
+			return exception
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(SignatureHeader, signature)
+		response, exception := client.Do(request)
+		if exception != nil {
+			return exception
+		}
+
+		response.Body.Close()
+		if response.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("the webhook was answered with %d", response.StatusCode)
+		}
+
+		return nil
+	}
+}
+
+func (fake *Fake) Name() string {
+	return "fake"
+}
+
+func (fake *Fake) Authorise(_ context.Context, charge Charge) (Authorisation, error) {
+	if !charge.Amount.IsPositive() {
+		return Authorisation{}, ErrInvalidAmount
+	}
+
+	payment := &fakePayment{amount: charge.Amount}
+	switch charge.Method {
+	case FakeApprove:
+		payment.status = Authorised
+	case FakeDecline:
+		payment.status = Declined
+	case FakeDelayed:
+		payment.status, payment.outcome = Pending, Authorised
+	case FakeDelayedDecline:
+		payment.status, payment.outcome = Pending, Declined
+	default:
+		return Authorisation{}, fmt.Errorf("%w: %q", ErrUnknownMethod, charge.Method)
+	}
+
+	status := payment.status
+	fake.mutex.Lock()
+	fake.sequence++
+	reference := fmt.Sprintf("fake_%d_%d", charge.OrderID, fake.sequence)
+	fake.payments[reference] = payment
+	fake.mutex.Unlock()
+
+	if status == Pending && fake.Delay >= 0 {
+		fake.schedule(reference)
+	}
+
+	return Authorisation{Reference: reference, Status: status}, nil
+}
+
+// schedule confirms the pending payment after the delay as a background worker of the lifecycle, so the shutdown
+// waits for the webhooks being delivered and drops the ones still waiting
+func (fake *Fake) schedule(reference string) {
+	fake.Lifecycle.Go("fake payment "+reference, func(context context.Context) {
+		timer := time.NewTimer(fake.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-context.Done():
+			return
+		case <-timer.C:
+		}
+
+		if exception := fake.Confirm(reference); exception != nil {
+			slog.Error("Failed to confirm the fake payment", "reference", reference, "error", exception.Error())
+		}
+	})
+}
+
+// Confirm settles a pending payment with its planned outcome and delivers the signed webhook telling so
+func (fake *Fake) Confirm(reference string) error {
+	fake.mutex.Lock()
+	payment, found := fake.payments[reference]
+	if !found {
+		fake.mutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownPayment, reference)
+	}
+
+	if payment.status != Pending {
+		fake.mutex.Unlock()
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, reference, payment.status)
+	}
+
+	payment.status = payment.outcome
+	fake.sequence++
+	event := Event{
+		ID:        fmt.Sprintf("event_%d", fake.sequence),
+		Type:      "payment." + string(payment.status),
+		Reference: reference,
+		Status:    payment.status,
+		CreatedAt: time.Now().UTC(),
+	}
+	fake.mutex.Unlock()
+
+	body, exception := json.Marshal(event)
+	if exception != nil {
+		return exception
+	}
+
+	if fake.Deliver == nil {
+		return nil
+	}
+
+	return fake.Deliver(body, Sign(fake.Secret, time.Now(), body))
+}
+
+func (fake *Fake) Capture(_ context.Context, reference string, amount money.Money) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	payment, found := fake.payments[reference]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownPayment, reference)
+	}
+
+	if payment.status != Authorised {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, reference, payment.status)
+	}
+
+	if comparison, exception := amount.Compare(payment.amount); exception != nil || comparison > 0 || !amount.IsPositive() {
+		return fmt.Errorf("%w: can't capture %s of %s", ErrInvalidAmount, amount, payment.amount)
+	}
+
+	payment.status, payment.amount = Captured, amount
+	return nil
+}
+
+func (fake *Fake) Refund(_ context.Context, reference string, amount money.Money) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	payment, found := fake.payments[reference]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownPayment, reference)
+	}
+
+	if payment.status != Captured {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, reference, payment.status)
+	}
+
+	left := payment.amount.Amount - payment.refunded
+	if amount.Currency != payment.amount.Currency || !amount.IsPositive() || amount.Amount > left {
+		return fmt.Errorf("%w: can't refund %s of %s", ErrInvalidAmount, amount, payment.amount)
+	}
+
+	payment.refunded += amount.Amount
+	if payment.refunded == payment.amount.Amount {
+		payment.status = Refunded
+	}
+
+	return nil
+}
+
+// Void releases an authorised payment which won't be captured, so nothing is taken from the customer
+func (fake *Fake) Void(_ context.Context, reference string) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	payment, found := fake.payments[reference]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownPayment, reference)
+	}
+
+	if payment.status != Authorised {
+		return fmt.Errorf("%w: %s is %s", ErrInvalidState, reference, payment.status)
+	}
+
+	payment.status = Voided
+	return nil
+}
+
+func (fake *Fake) VerifyWebhook(header http.Header, body []byte) (Event, error) {
+	event := Event{}
+	if exception := Verify(fake.Secret, header.Get(SignatureHeader), body, time.Now()); exception != nil {
+		return event, exception
+	}
+
+	exception := json.Unmarshal(body, &event)
+	return event, exception
+}
@@ -0,0 +1,157 @@
+package payments
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zatarain/bookshop/lifecycle"
+	"github.com/zatarain/bookshop/money"
+)
+
+func TestFake(test *testing.T) {
+	assert := assert.New(test)
+	background := context.Background()
+	amount := money.MustParse("12.50", "GBP")
+
+	test.Run("Should approve, capture and refund the payment", func(test *testing.T) {
+		// Arrange
+		fake := NewFake("dummy-secret", -1, nil, nil)
+
+		// Act
+		authorisation, authorising := fake.Authorise(background, Charge{OrderID: 1, Amount: amount, Method: FakeApprove})
+		capturing := fake.Capture(background, authorisation.Reference, amount)
+		partial := fake.Refund(background, authorisation.Reference, money.MustParse("2.50", "GBP"))
+		excess := fake.Refund(background, authorisation.Reference, amount)
+		rest := fake.Refund(background, authorisation.Reference, money.MustParse("10", "GBP"))
+
+		// Assert
+		assert.Nil(authorising)
+		assert.Equal(Authorised, authorisation.Status)
+		assert.NotEmpty(authorisation.Reference)
+		assert.Nil(capturing)
+		assert.Nil(partial)
+		assert.ErrorIs(excess, ErrInvalidAmount)
+		assert.Nil(rest)
+		assert.Equal(Refunded, fake.payments[authorisation.Reference].status)
+	})
+
+	test.Run("Should void the authorised payment so it can't be captured", func(test *testing.T) {
+		// Arrange
+		fake := NewFake("dummy-secret", -1, nil, nil)
+		authorisation, _ := fake.Authorise(background, Charge{OrderID: 1, Amount: amount, Method: FakeApprove})
+
+		// Act
+		voiding := fake.Void(background, authorisation.Reference)
+		again := fake.Void(background, authorisation.Reference)
+		capturing := fake.Capture(background, authorisation.Reference, amount)
+
+		// Assert
+		assert.Nil(voiding)
+		assert.ErrorIs(again, ErrInvalidState)
+		assert.ErrorIs(capturing, ErrInvalidState)
+		assert.Equal(Voided, fake.payments[authorisation.Reference].status)
+	})
+
+	test.Run("Should decline the payment", func(test *testing.T) {
+		// Arrange
+		fake := NewFake("dummy-secret", -1, nil, nil)
+
+		// Act
+		authorisation, exception := fake.Authorise(background, Charge{OrderID: 1, Amount: amount, Method: FakeDecline})
+		capturing := fake.Capture(background, authorisation.Reference, amount)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(Declined, authorisation.Status)
+		assert.ErrorIs(capturing, ErrInvalidState)
+	})
+
+	test.Run("Should NOT take unknown methods nor amounts which aren't positive", func(test *testing.T) {
+		// Arrange
+		fake := NewFake("dummy-secret", -1, nil, nil)
+
+		// Act
+		_, method := fake.Authorise(background, Charge{OrderID: 1, Amount: amount, Method: "cash"})
+		_, zero := fake.Authorise(background, Charge{OrderID: 1, Amount: money.Money{Currency: "GBP"}, Method: FakeApprove})
+
+		// Assert
+		assert.ErrorIs(method, ErrUnknownMethod)
+		assert.ErrorIs(zero, ErrInvalidAmount)
+	})
+
+	for method, expected := range map[string]Status{FakeDelayed: Authorised, FakeDelayedDecline: Declined} {
+		test.Run("Should confirm the delayed payment "+method+" with a signed webhook", func(test *testing.T) {
+			// Arrange
+			events := make(chan Event, 1)
+			fake := NewFake("dummy-secret", time.Millisecond, nil, lifecycle.New())
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				body, _ := io.ReadAll(request.Body)
+				event, exception := fake.VerifyWebhook(request.Header, body)
+				if exception != nil {
+					writer.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				events <- event
+				writer.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+			fake.Deliver = PostTo(server.URL)
+
+			// Act
+			authorisation, exception := fake.Authorise(background, Charge{OrderID: 1, Amount: amount, Method: method})
+
+			// Assert
+			assert.Nil(exception)
+			assert.Equal(Pending, authorisation.Status)
+			select {
+			case event := <-events:
+				assert.Equal(authorisation.Reference, event.Reference)
+				assert.Equal(expected, event.Status)
+				assert.Equal("payment."+string(expected), event.Type)
+			case <-time.After(5 * time.Second):
+				require.Fail(test, "the webhook was not delivered")
+			}
+		})
+	}
+
+	test.Run("Should drop the delayed payments still waiting when the lifecycle drains", func(test *testing.T) {
+		// Arrange
+		delivered := false
+		lifecycle := lifecycle.New()
+		fake := NewFake("dummy-secret", time.Hour, func(body []byte, signature string) error {
+			delivered = true
+			return nil
+		}, lifecycle)
+		authorisation, _ := fake.Authorise(background, Charge{OrderID: 1, Amount: amount, Method: FakeDelayed})
+
+		// Act
+		lifecycle.Drain()
+		exception := lifecycle.Wait(background)
+
+		// Assert
+		assert.Nil(exception)
+		assert.False(delivered)
+		assert.Equal(Pending, authorisation.Status)
+	})
+
+	test.Run("Should NOT verify a webhook signed with another secret", func(test *testing.T) {
+		// Arrange
+		fake := NewFake("dummy-secret", -1, nil, nil)
+		body := []byte(`{"reference": "fake_1_1", "status": "authorised"}`)
+		header := http.Header{}
+		header.Set(SignatureHeader, Sign("other-secret", time.Now(), body))
+
+		// Act
+		_, exception := fake.VerifyWebhook(header, body)
+
+		// Assert
+		assert.ErrorIs(exception, ErrInvalidSignature)
+	})
+}
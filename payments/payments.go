@@ -0,0 +1,115 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zatarain/bookshop/money"
+)
+
+// Status of a payment at the provider
+type Status string
+
+const (
+	Pending    Status = "pending"
+	Authorised Status = "authorised"
+	Declined   Status = "declined"
+	Captured   Status = "captured"
+	Refunded   Status = "refunded"
+	Voided     Status = "voided"
+)
+
+// The header carrying the signature of the webhooks, e.g. t=1792368000,v1=5257a869...
+const SignatureHeader = "Payment-Signature"
+
+// The webhooks signed longer than this ago are rejected, so they can't be replayed later
+const SignatureTolerance = 5 * time.Minute
+
+var (
+	ErrUnknownPayment   = errors.New("unknown payment")
+	ErrUnknownMethod    = errors.New("unknown payment method")
+	ErrInvalidAmount    = errors.New("the amount must be positive")
+	ErrInvalidState     = errors.New("the payment can't do that in its current status")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Charge is what the shop asks the provider to take from the customer
+type Charge struct {
+	OrderID uint
+	Amount  money.Money
+	Method  string
+}
+
+// Authorisation is the answer of the provider to a charge, the pending ones are confirmed later by a webhook
+type Authorisation struct {
+	Reference string
+	Status    Status
+}
+
+// Event is the payload of the webhooks sent by the provider when a pending payment is settled
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Reference string    `json:"reference"`
+	Status    Status    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PaymentProvider takes the payments of the orders from a payment gateway
+type PaymentProvider interface {
+	Name() string
+	Authorise(context.Context, Charge) (Authorisation, error)
+	Capture(context.Context, string, money.Money) error
+	Refund(context.Context, string, money.Money) error
+	Void(context.Context, string) error
+	VerifyWebhook(http.Header, []byte) (Event, error)
+}
+
+// Sign gives the signature of the body sent at the given time, an HMAC-SHA256 of the timestamp and the body
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), digest(secret, timestamp.Unix(), body))
+}
+
+// Verify checks the signature of the body was made with the secret within the tolerance
+func Verify(secret string, signature string, body []byte, now time.Time) error {
+	var timestamp int64
+	var given string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			given = value
+		}
+	}
+
+	if timestamp == 0 || given == "" {
+		return fmt.Errorf("%w: malformed", ErrInvalidSignature)
+	}
+
+	if age := now.Sub(time.Unix(timestamp, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return fmt.Errorf("%w: expired", ErrInvalidSignature)
+	}
+
+	if !hmac.Equal([]byte(given), []byte(digest(secret, timestamp, body))) {
+		return fmt.Errorf("%w: mismatch", ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+func digest(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,48 @@
+package payments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(test *testing.T) {
+	assert := assert.New(test)
+	now := time.Unix(1792368000, 0)
+	body := []byte(`{"reference": "fake_1_1"}`)
+
+	test.Run("Should verify the body signed with the secret", func(test *testing.T) {
+		// Arrange
+		signature := Sign("dummy-secret", now, body)
+
+		// Act
+		exception := Verify("dummy-secret", signature, body, now.Add(time.Minute))
+
+		// Assert
+		assert.Nil(exception)
+		assert.Regexp(`^t=1792368000,v1=[0-9a-f]{64}$`, signature)
+	})
+
+	testcases := []struct {
+		description string
+		signature   string
+		body        string
+		now         time.Time
+	}{
+		{"another secret", Sign("other-secret", now, body), string(body), now},
+		{"another body", Sign("dummy-secret", now, body), `{"reference": "fake_1_2"}`, now},
+		{"an old signature", Sign("dummy-secret", now, body), string(body), now.Add(SignatureTolerance + time.Second)},
+		{"a malformed signature", "v1=5257a869", string(body), now},
+	}
+
+	for _, testcase := range testcases {
+		test.Run("Should NOT verify "+testcase.description, func(test *testing.T) {
+			// Act
+			exception := Verify("dummy-secret", testcase.signature, []byte(testcase.body), testcase.now)
+
+			// Assert
+			assert.ErrorIs(exception, ErrInvalidSignature)
+		})
+	}
+}
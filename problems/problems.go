@@ -0,0 +1,178 @@
+package problems
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/zatarain/bookshop/money"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
+)
+
+const ContentType = "application/problem+json"
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Problem is an error answered to the clients as RFC 7807 problem details with a stable code
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Current   any          `json:"current,omitempty"`
+	cause     error
+}
+
+var (
+	ErrMalformedRequest   = New(http.StatusBadRequest, "malformed_request", "The request body could not be read")
+	ErrValidationFailed   = New(http.StatusBadRequest, "validation_failed", "The request has invalid fields")
+	ErrInvalidCredentials = New(http.StatusBadRequest, "invalid_credentials", "Invalid nickname or password")
+	ErrUnauthorised       = New(http.StatusUnauthorized, "unauthorised", "A valid authentication is required")
+	ErrPaymentDeclined    = New(http.StatusPaymentRequired, "payment_declined", "The payment was declined")
+	ErrForbidden          = New(http.StatusForbidden, "forbidden", "The user is not allowed to do this")
+	ErrNotFound           = New(http.StatusNotFound, "not_found", "The resource was not found")
+	ErrConflict           = New(http.StatusConflict, "conflict", "The resource already exists")
+	ErrOutOfStock         = New(http.StatusConflict, "out_of_stock", "The book is out of stock")
+	ErrVersionConflict    = New(http.StatusConflict, "version_conflict", "The resource was modified by someone else")
+	ErrInvalidTransition  = New(http.StatusConflict, "invalid_transition", "The order can't move to that status")
+	ErrIdempotencyBusy    = New(http.StatusConflict, "idempotency_in_progress", "A request with the same idempotency key is still being processed")
+	ErrIdempotencyReused  = New(http.StatusUnprocessableEntity, "idempotency_key_reused", "The idempotency key was used with another request")
+	ErrPreconditionFailed = New(http.StatusPreconditionFailed, "precondition_failed", "The resource was modified since it was read")
+	ErrInternal           = New(http.StatusInternalServerError, "internal_error", "An unexpected error occurred")
+	ErrPaymentFailed      = New(http.StatusBadGateway, "payment_failed", "The payment provider failed")
+	ErrPaymentsDisabled   = New(http.StatusServiceUnavailable, "payments_disabled", "There is no payment provider to take the payments")
+)
+
+func New(status int, code string, detail string) *Problem {
+	return &Problem{
+		Type:   "urn:bookshop:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (problem *Problem) Error() string {
+	if problem.cause != nil {
+		return fmt.Sprintf("%s: %s", problem.Code, problem.cause.Error())
+	}
+
+	return fmt.Sprintf("%s: %s", problem.Code, problem.Detail)
+}
+
+func (problem *Problem) Unwrap() error {
+	return problem.cause
+}
+
+// Is matches the problems by code, so the copies made by Wrap and With still match their sentinel
+func (problem *Problem) Is(target error) bool {
+	other, ok := target.(*Problem)
+	return ok && other.Code == problem.Code
+}
+
+// Wrap keeps the internal cause to be logged without showing it to the client
+func (problem *Problem) Wrap(cause error) *Problem {
+	copy := *problem
+	copy.cause = cause
+	return &copy
+}
+
+func (problem *Problem) WithDetail(detail string) *Problem {
+	copy := *problem
+	copy.Detail = detail
+	return &copy
+}
+
+// WithCurrent attaches the current representation of the resource, so the client can merge its changes
+func (problem *Problem) WithCurrent(current any) *Problem {
+	copy := *problem
+	copy.Current = current
+	return &copy
+}
+
+func Duplicated(exception error) bool {
+	if errors.Is(exception, gorm.ErrDuplicatedKey) {
+		return true
+	}
+
+	// Messages of the SQLite, PostgreSQL and MySQL drivers respectively
+	message := exception.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") ||
+		strings.Contains(message, "duplicate key value") ||
+		strings.Contains(message, "Duplicate entry")
+}
+
+// From maps any error to the problem answered to the client, unknown errors become internal ones
+func From(exception error) *Problem {
+	var problem *Problem
+	var invalid validator.ValidationErrors
+	var unknown *validation.UnknownFieldError
+	var syntax *json.SyntaxError
+	var mismatch *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(exception, &problem):
+		return problem
+	case errors.As(exception, &invalid):
+		problem = ErrValidationFailed.Wrap(exception)
+		for _, field := range invalid {
+			// Nested fields keep their path without the type name of the root structure
+			path := field.Namespace()
+			root, _, _ := strings.Cut(field.StructNamespace(), ".")
+			if rest, found := strings.CutPrefix(path, root+"."); found {
+				path = rest
+			}
+			problem.Errors = append(problem.Errors, FieldError{
+				Field:   path,
+				Rule:    field.Tag(),
+				Message: validation.Message(field),
+			})
+		}
+		return problem
+	case errors.As(exception, &unknown):
+		problem = ErrValidationFailed.Wrap(exception)
+		problem.Errors = []FieldError{{Field: unknown.Field, Rule: "unknown", Message: "is not allowed"}}
+		return problem
+	case errors.As(exception, &syntax), errors.As(exception, &mismatch),
+		errors.Is(exception, io.EOF), errors.Is(exception, io.ErrUnexpectedEOF):
+		return ErrMalformedRequest.Wrap(exception)
+	case errors.Is(exception, money.ErrInvalidAmount), errors.Is(exception, money.ErrUnknownCurrency), errors.Is(exception, money.ErrOverflow):
+		return ErrValidationFailed.WithDetail(exception.Error()).Wrap(exception)
+	case errors.Is(exception, gorm.ErrRecordNotFound):
+		return ErrNotFound.Wrap(exception)
+	case Duplicated(exception):
+		return ErrConflict.Wrap(exception)
+	}
+
+	return ErrInternal.Wrap(exception)
+}
+
+// Abort records the error to be answered by the problems middleware and stops the handlers chain
+func Abort(context *gin.Context, exception error) {
+	context.Error(exception)
+	context.Abort()
+}
+
+// Respond writes the problem for the current request including the correlation ID
+func Respond(context *gin.Context, problem *Problem) {
+	answer := *problem
+	answer.Instance = context.Request.URL.Path
+	answer.RequestID = context.GetString("request_id")
+	context.Header("Content-Type", ContentType)
+	context.AbortWithStatusJSON(answer.Status, answer)
+}
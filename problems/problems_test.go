@@ -0,0 +1,111 @@
+package problems
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zatarain/bookshop/validation"
+	"gorm.io/gorm"
+)
+
+func TestFrom(test *testing.T) {
+	assert := assert.New(test)
+
+	testcases := []struct {
+		description string
+		exception   error
+		code        string
+		status      int
+	}{
+		{"Should keep the problems as they are", ErrInvalidCredentials, "invalid_credentials", http.StatusBadRequest},
+		{"Should find the problems wrapped by other errors", fmt.Errorf("login: %w", ErrUnauthorised), "unauthorised", http.StatusUnauthorized},
+		{"Should map the JSON syntax errors", json.Unmarshal([]byte("{"), &struct{}{}), "malformed_request", http.StatusBadRequest},
+		{"Should map the JSON type errors", json.Unmarshal([]byte(`{"Name":1}`), &struct{ Name string }{}), "malformed_request", http.StatusBadRequest},
+		{"Should map the empty bodies", io.EOF, "malformed_request", http.StatusBadRequest},
+		{"Should map the records not found", gorm.ErrRecordNotFound, "not_found", http.StatusNotFound},
+		{"Should map the duplicated keys of SQLite", errors.New("UNIQUE constraint failed: users.nickname"), "conflict", http.StatusConflict},
+		{"Should map the duplicated keys of PostgreSQL", errors.New(`ERROR: duplicate key value violates unique constraint "users_nickname_key"`), "conflict", http.StatusConflict},
+		{"Should map the duplicated keys of MySQL", errors.New("Error 1062: Duplicate entry 'dummy' for key 'nickname'"), "conflict", http.StatusConflict},
+		{"Should map the unknown errors as internal ones", errors.New("disk I/O error"), "internal_error", http.StatusInternalServerError},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Act
+			problem := From(testcase.exception)
+
+			// Assert
+			assert.Equal(testcase.code, problem.Code)
+			assert.Equal(testcase.status, problem.Status)
+			assert.Equal("urn:bookshop:problem:"+testcase.code, problem.Type)
+		})
+	}
+
+	test.Run("Should detail the fields that failed the validation", func(test *testing.T) {
+		// Arrange
+		input := struct {
+			Nickname string `json:"nickname" binding:"required"`
+			Password string `json:"password" binding:"required,min=8"`
+			Address  struct {
+				City string `json:"city" binding:"required"`
+			} `json:"address"`
+		}{Password: "short"}
+		exception := validation.Engine().Struct(&input)
+
+		// Act
+		problem := From(exception)
+
+		// Assert
+		assert.Equal("validation_failed", problem.Code)
+		assert.Equal([]FieldError{
+			{Field: "nickname", Rule: "required", Message: "is required"},
+			{Field: "password", Rule: "min", Message: "must be at least 8 characters long"},
+			{Field: "address.city", Rule: "required", Message: "is required"},
+		}, problem.Errors)
+	})
+
+	test.Run("Should detail the unknown fields", func(test *testing.T) {
+		// Arrange
+		exception := &validation.UnknownFieldError{Field: "admin"}
+
+		// Act
+		problem := From(exception)
+
+		// Assert
+		assert.Equal("validation_failed", problem.Code)
+		assert.Equal([]FieldError{{Field: "admin", Rule: "unknown", Message: "is not allowed"}}, problem.Errors)
+	})
+}
+
+func TestProblem(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should match its sentinel after being wrapped or detailed", func(test *testing.T) {
+		// Arrange
+		cause := errors.New("token is expired")
+
+		// Act
+		problem := ErrUnauthorised.WithDetail("Session expired").Wrap(cause)
+
+		// Assert
+		assert.ErrorIs(problem, ErrUnauthorised)
+		assert.ErrorIs(problem, cause)
+		assert.NotErrorIs(problem, ErrNotFound)
+		assert.Equal("A valid authentication is required", ErrUnauthorised.Detail)
+		assert.Equal("unauthorised: token is expired", problem.Error())
+		assert.Equal("not_found: The resource was not found", ErrNotFound.Error())
+	})
+
+	test.Run("Should NOT serialise the internal cause", func(test *testing.T) {
+		// Act
+		body, _ := json.Marshal(ErrInternal.Wrap(errors.New("secret connection string")))
+
+		// Assert
+		assert.NotContains(string(body), "secret connection string")
+	})
+}
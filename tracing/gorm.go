@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func before(operation string) func(*gorm.DB) {
+	return func(database *gorm.DB) {
+		_, span := Tracer().Start(
+			database.Statement.Context,
+			"gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemKey.String(database.Dialector.Name())),
+		)
+		database.InstanceSet(spanKey, span)
+	}
+}
+
+func after(database *gorm.DB) {
+	value, exists := database.InstanceGet(spanKey)
+	span, ok := value.(trace.Span)
+	if !exists || !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBSQLTable(database.Statement.Table),
+		semconv.DBStatement(database.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", database.RowsAffected),
+	)
+
+	if database.Error != nil && !errors.Is(database.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(database.Error)
+		span.SetStatus(codes.Error, database.Error.Error())
+	}
+}
+
+// Initialize opens a child span of the statement context for every statement executed through the GORM callbacks
+func (GormPlugin) Initialize(database *gorm.DB) error {
+	callbacks := database.Callback()
+	type register func(string, func(*gorm.DB)) error
+	registrations := map[string][2]register{
+		"create": {callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		"query":  {callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		"update": {callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		"delete": {callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		"row":    {callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		"raw":    {callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+
+	for operation, registration := range registrations {
+		if exception := registration[0]("tracing:before_"+operation, before(operation)); exception != nil {
+			return exception
+		}
+
+		if exception := registration[1]("tracing:after_"+operation, after); exception != nil {
+			return exception
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type shelf struct {
+	ID    uint
+	Label string
+}
+
+func TestGormPlugin(test *testing.T) {
+	assert := assert.New(test)
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	filename := filepath.Join(test.TempDir(), "test.db")
+	database, exception := gorm.Open(sqlite.Open(filename), &gorm.Config{
+		Plugins: map[string]gorm.Plugin{"tracing": GormPlugin{}},
+	})
+	require.Nil(test, exception)
+	database.AutoMigrate(&shelf{})
+
+	test.Run("Should create a child span for every statement", func(test *testing.T) {
+		// Arrange
+		exporter.Reset()
+		parent, span := Tracer().Start(context.Background(), "request")
+
+		// Act
+		database.WithContext(parent).Create(&shelf{Label: "fiction"})
+		database.WithContext(parent).First(&shelf{}, "label = ?", "missing")
+		span.End()
+
+		// Assert
+		spans := exporter.GetSpans()
+		require.Len(test, spans, 3)
+		assert.Equal("gorm.create", spans[0].Name)
+		assert.Equal("gorm.query", spans[1].Name)
+		for _, child := range spans[:2] {
+			assert.Equal(span.SpanContext().TraceID(), child.SpanContext.TraceID())
+			assert.Equal(span.SpanContext().SpanID(), child.Parent.SpanID())
+			assert.Equal(codes.Unset, child.Status.Code)
+		}
+		attributes := map[string]string{}
+		for _, attribute := range spans[0].Attributes {
+			attributes[string(attribute.Key)] = attribute.Value.Emit()
+		}
+		assert.Equal("sqlite", attributes["db.system"])
+		assert.Equal("shelves", attributes["db.sql.table"])
+		assert.Contains(attributes["db.statement"], "INSERT INTO `shelves`")
+	})
+
+	test.Run("Should flag the statements that failed", func(test *testing.T) {
+		// Arrange
+		exporter.Reset()
+
+		// Act
+		database.Exec("THIS IS NOT SQL")
+
+		// Assert
+		spans := exporter.GetSpans()
+		require.Len(test, spans, 1)
+		assert.Equal("gorm.raw", spans[0].Name)
+		assert.Equal(codes.Error, spans[0].Status.Code)
+		assert.Len(spans[0].Events, 1)
+	})
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zatarain/bookshop/health"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const Name = "github.com/zatarain/bookshop"
+
+var ErrUnknownExporter = errors.New("unknown tracing exporter")
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
+
+// NewExporter creates the span exporter by name, there is none when tracing is disabled
+func NewExporter(context context.Context, name string, output io.Writer) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(output))
+	case "otlp":
+		// Endpoint, headers and protocol options are read from the OTEL_EXPORTER_OTLP_* variables
+		return otlptracehttp.New(context)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownExporter, name)
+}
+
+// Setup installs the W3C propagators and the tracer provider for the exporter given by TRACING_EXPORTER
+func Setup(parent context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	shutdown := func(context.Context) error { return nil }
+	exporter, exception := NewExporter(parent, os.Getenv("TRACING_EXPORTER"), os.Stdout)
+	if exception != nil || exporter == nil {
+		return shutdown, exception
+	}
+
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "bookshop"
+	}
+
+	description, exception := resource.New(parent,
+		resource.WithFromEnv(),
+		resource.WithAttributes(semconv.ServiceName(service), semconv.ServiceVersion(health.Version)),
+	)
+	if exception != nil {
+		return shutdown, exception
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(description),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestNewExporter(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should NOT create an exporter when tracing is disabled", func(test *testing.T) {
+		for _, name := range []string{"", "none"} {
+			// Act
+			exporter, exception := NewExporter(context.Background(), name, os.Stdout)
+
+			// Assert
+			assert.Nil(exception)
+			assert.Nil(exporter)
+		}
+	})
+
+	test.Run("Should write the spans to the given output with stdout exporter", func(test *testing.T) {
+		// Arrange
+		var output bytes.Buffer
+		exporter, exception := NewExporter(context.Background(), "stdout", &output)
+		assert.Nil(exception)
+
+		// Act
+		exception = exporter.ExportSpans(context.Background(), nil)
+
+		// Assert
+		assert.Nil(exception)
+		assert.NotNil(exporter)
+	})
+
+	test.Run("Should create the OTLP exporter", func(test *testing.T) {
+		// Act
+		exporter, exception := NewExporter(context.Background(), "OTLP", os.Stdout)
+
+		// Assert
+		assert.Nil(exception)
+		assert.NotNil(exporter)
+	})
+
+	test.Run("Should fail with an unknown exporter", func(test *testing.T) {
+		// Act
+		exporter, exception := NewExporter(context.Background(), "carrier-pigeon", os.Stdout)
+
+		// Assert
+		assert.ErrorIs(exception, ErrUnknownExporter)
+		assert.Nil(exporter)
+	})
+}
+
+func TestSetup(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should install the W3C propagators even when tracing is disabled", func(test *testing.T) {
+		// Arrange
+		test.Setenv("TRACING_EXPORTER", "none")
+
+		// Act
+		shutdown, exception := Setup(context.Background())
+
+		// Assert
+		assert.Nil(exception)
+		assert.Nil(shutdown(context.Background()))
+		assert.Contains(otel.GetTextMapPropagator().Fields(), "traceparent")
+		assert.Contains(otel.GetTextMapPropagator().Fields(), "baggage")
+	})
+
+	test.Run("Should install the tracer provider for the configured exporter", func(test *testing.T) {
+		// Arrange
+		test.Setenv("TRACING_EXPORTER", "stdout")
+		defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+		// Act
+		shutdown, exception := Setup(context.Background())
+
+		// Assert
+		assert.Nil(exception)
+		_, span := Tracer().Start(context.Background(), "dummy")
+		assert.True(span.SpanContext().IsValid())
+		span.End()
+		assert.Nil(shutdown(context.Background()))
+	})
+
+	test.Run("Should fail with an unknown exporter", func(test *testing.T) {
+		// Arrange
+		test.Setenv("TRACING_EXPORTER", "carrier-pigeon")
+
+		// Act
+		shutdown, exception := Setup(context.Background())
+
+		// Assert
+		assert.ErrorIs(exception, ErrUnknownExporter)
+		assert.NotNil(shutdown)
+	})
+}
@@ -0,0 +1,130 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/zatarain/bookshop/money"
+)
+
+type UnknownFieldError struct {
+	Field string
+}
+
+func (exception *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", exception.Field)
+}
+
+var once sync.Once
+
+// Engine customises the validator used by gin to report the JSON names of the fields and to know our own rules
+func Engine() *validator.Validate {
+	engine := binding.Validator.Engine().(*validator.Validate)
+	once.Do(func() {
+		engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || name == "" {
+				return field.Name
+			}
+			return name
+		})
+		engine.RegisterValidation("notblank", func(field validator.FieldLevel) bool {
+			return strings.TrimSpace(field.Field().String()) != ""
+		})
+
+		// Amounts of money are compared by their minor units, e.g. gt=0 for positive prices
+		engine.RegisterCustomTypeFunc(func(value reflect.Value) any {
+			return value.Interface().(money.Money).Amount
+		}, money.Money{})
+	})
+
+	return engine
+}
+
+// BindJSON decodes the request body rejecting unknown fields and then checks the validation rules
+func BindJSON(body io.Reader, target any) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if exception := decoder.Decode(target); exception != nil {
+		if field, found := strings.CutPrefix(exception.Error(), "json: unknown field "); found {
+			return &UnknownFieldError{Field: strings.Trim(field, `"`)}
+		}
+		return exception
+	}
+
+	return Engine().Struct(target)
+}
+
+// Message describes the rule that a field failed for the clients
+func Message(field validator.FieldError) string {
+	switch field.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "min":
+		return limit("at least", field)
+	case "max":
+		return limit("at most", field)
+	case "len":
+		return limit("exactly", field)
+	case "gt":
+		return "must be greater than " + field.Param()
+	case "gte":
+		return "must be greater than or equal to " + field.Param()
+	case "lt":
+		return "must be less than " + field.Param()
+	case "lte":
+		return "must be less than or equal to " + field.Param()
+	case "gtefield":
+		return "must be greater than or equal to " + snakeCase(field.Param())
+	case "nefield":
+		return "must be different from " + snakeCase(field.Param())
+	case "unique":
+		return "must not have duplicated items"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(field.Param(), " ", ", ")
+	case "email":
+		return "must be a valid email address"
+	}
+
+	return fmt.Sprintf("failed on the '%s' rule", field.Tag())
+}
+
+// snakeCase names the other fields of the cross-field rules as the clients know them, e.g. reorder_point for ReorderPoint
+// or from_location_id for FromLocationID
+func snakeCase(name string) string {
+	var builder strings.Builder
+	previous := 'A'
+	for _, character := range name {
+		if unicode.IsUpper(character) {
+			if !unicode.IsUpper(previous) {
+				builder.WriteByte('_')
+			}
+			builder.WriteRune(unicode.ToLower(character))
+		} else {
+			builder.WriteRune(character)
+		}
+		previous = character
+	}
+
+	return builder.String()
+}
+
+func limit(comparison string, field validator.FieldError) string {
+	switch field.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("must be %s %s characters long", comparison, field.Param())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("must have %s %s items", comparison, field.Param())
+	}
+
+	return fmt.Sprintf("must be %s %s", comparison, field.Param())
+}
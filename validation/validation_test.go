@@ -0,0 +1,148 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+type dummy struct {
+	Name     string   `json:"name" binding:"required,notblank,max=5"`
+	Quantity int      `json:"quantity" binding:"gte=0,lte=10"`
+	Price    float32  `json:"price" binding:"gt=0"`
+	Tags     []string `json:"tags" binding:"max=2"`
+	Colour   string   `json:"colour" binding:"omitempty,oneof=red green"`
+	Internal string   `json:"-"`
+}
+
+func TestBindJSON(test *testing.T) {
+	assert := assert.New(test)
+
+	test.Run("Should decode and validate the body", func(test *testing.T) {
+		// Arrange
+		var target dummy
+		body := strings.NewReader(`{"name": "Book", "quantity": 3, "price": 9.99, "tags": ["new"]}`)
+
+		// Act
+		exception := BindJSON(body, &target)
+
+		// Assert
+		assert.Nil(exception)
+		assert.Equal(dummy{Name: "Book", Quantity: 3, Price: 9.99, Tags: []string{"new"}}, target)
+	})
+
+	test.Run("Should reject the unknown fields", func(test *testing.T) {
+		// Arrange
+		var target dummy
+		body := strings.NewReader(`{"name": "Book", "price": 1, "Internal": "hacked"}`)
+
+		// Act
+		exception := BindJSON(body, &target)
+
+		// Assert
+		var unknown *UnknownFieldError
+		assert.ErrorAs(exception, &unknown)
+		assert.Equal("Internal", unknown.Field)
+		assert.Equal(`unknown field "Internal"`, exception.Error())
+	})
+
+	test.Run("Should return the malformed body errors as they are", func(test *testing.T) {
+		// Arrange
+		var target dummy
+
+		// Act
+		exception := BindJSON(strings.NewReader(`{"name": `), &target)
+
+		// Assert
+		assert.NotNil(exception)
+		assert.False(errors.As(exception, new(validator.ValidationErrors)))
+	})
+
+	test.Run("Should report the failed rules by JSON field name", func(test *testing.T) {
+		// Arrange
+		var target dummy
+		body := strings.NewReader(`{"name": "  ", "quantity": 11, "price": 0, "tags": ["a", "b", "c"], "colour": "blue"}`)
+		expected := map[string]string{
+			"name":     "must not be blank",
+			"quantity": "must be less than or equal to 10",
+			"price":    "must be greater than 0",
+			"tags":     "must have at most 2 items",
+			"colour":   "must be one of: red, green",
+		}
+
+		// Act
+		exception := BindJSON(body, &target)
+
+		// Assert
+		var invalid validator.ValidationErrors
+		assert.ErrorAs(exception, &invalid)
+		messages := map[string]string{}
+		for _, field := range invalid {
+			messages[field.Field()] = Message(field)
+		}
+		assert.Equal(expected, messages)
+	})
+}
+
+func TestMessage(test *testing.T) {
+	assert := assert.New(test)
+
+	testcases := []struct {
+		description string
+		input       any
+		expected    string
+	}{
+		{"Should describe the required fields", &struct {
+			Name string `binding:"required"`
+		}{}, "is required"},
+		{"Should describe the minimum length of texts", &struct {
+			Name string `binding:"min=3"`
+		}{Name: "a"}, "must be at least 3 characters long"},
+		{"Should describe the maximum length of texts", &struct {
+			Name string `binding:"max=3"`
+		}{Name: "abcd"}, "must be at most 3 characters long"},
+		{"Should describe the minimum of numbers", &struct {
+			Age int `binding:"min=18"`
+		}{Age: 7}, "must be at least 18"},
+		{"Should describe the exact length", &struct {
+			Code string `binding:"len=2"`
+		}{Code: "abc"}, "must be exactly 2 characters long"},
+		{"Should describe the upper bounds", &struct {
+			Age int `binding:"lt=100"`
+		}{Age: 100}, "must be less than 100"},
+		{"Should describe the lower bounds", &struct {
+			Age int `binding:"gte=1"`
+		}{Age: 0}, "must be greater than or equal to 1"},
+		{"Should describe the lower bounds by other fields", &struct {
+			ReorderPoint  int
+			ReorderTarget int `binding:"gtefield=ReorderPoint"`
+		}{ReorderPoint: 5, ReorderTarget: 2}, "must be greater than or equal to reorder_point"},
+		{"Should describe the differences from other fields", &struct {
+			FromLocationID uint
+			ToLocationID   uint `binding:"nefield=FromLocationID"`
+		}{FromLocationID: 1, ToLocationID: 1}, "must be different from from_location_id"},
+		{"Should describe the emails", &struct {
+			Email string `binding:"email"`
+		}{Email: "nope"}, "must be a valid email address"},
+		{"Should name the other rules", &struct {
+			Website string `binding:"url"`
+		}{Website: "nope"}, "failed on the 'url' rule"},
+	}
+
+	for _, testcase := range testcases {
+		test.Run(testcase.description, func(test *testing.T) {
+			// Arrange
+			var invalid validator.ValidationErrors
+			errors.As(Engine().Struct(testcase.input), &invalid)
+
+			// Act
+			message := Message(invalid[0])
+
+			// Assert
+			assert.Equal(testcase.expected, message)
+		})
+	}
+}